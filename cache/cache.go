@@ -1,50 +1,266 @@
 package cache
 
 import (
+	"container/list"
+	"log"
+	"strings"
 	"sync"
 	"time"
 )
 
+type Options struct {
+	// MaxEntries and MaxBytes limit cache size, zero means no limit
+	MaxEntries int
+	MaxBytes   int
+	// Store persists items, items missing in memory are loaded from it. Optional.
+	Store IStore
+}
+
 type cacheItem struct {
-	createdAt time.Time
-	ttl       time.Duration
+	key       string
 	value     string
+	expiresAt time.Time
 }
 
+func (this *cacheItem) size() int {
+	return len(this.key) + len(this.value)
+}
+
+// Cache is a LRU cache of strings with per item ttl. Least recently used items
+// are evicted when MaxEntries or MaxBytes is exceeded, expired items are removed by Sweep.
 type Cache struct {
-	lock sync.RWMutex
-	data map[string]cacheItem
+	options Options
+	now     func() time.Time
+
+	lock  sync.Mutex
+	order *list.List
+	data  map[string]*list.Element
+	bytes int
+	stats Stats
+	// removals counts deletes and purges, an item loaded meanwhile from the store may be stale
+	removals uint64
 }
 
-func NewCache(capacity int) *Cache {
+func NewCache(options Options) *Cache {
 	return &Cache{
-		data: make(map[string]cacheItem, capacity),
+		options: options,
+		now:     time.Now,
+		order:   list.New(),
+		data:    make(map[string]*list.Element, options.MaxEntries),
 	}
 }
 
+// Get returns item from memory or loads it from the store. The store is read without lock,
+// an item set meanwhile wins over the loaded one.
 func (this *Cache) Get(key string) (string, bool) {
-	this.lock.RLock()
-	item, found := this.data[key]
-	this.lock.RUnlock()
+	this.lock.Lock()
+	element, found := this.data[key]
+	if found || this.options.Store == nil {
+		defer this.lock.Unlock()
+		return this.get(element, found)
+	}
+	removals := this.removals
+	this.lock.Unlock()
+
+	item := this.load(key)
 
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if element, found = this.data[key]; !found && item != nil && removals == this.removals {
+		element, found = this.add(item)
+	}
+	return this.get(element, found)
+}
+
+// get counts lookup and returns value of element unless it's expired. Must be called with lock held.
+func (this *Cache) get(element *list.Element, found bool) (string, bool) {
 	if !found {
+		this.stats.Misses++
 		return "", false
 	}
 
-	if item.createdAt.Add(item.ttl).Before(time.Now()) {
+	item := element.Value.(*cacheItem)
+	if !item.expiresAt.After(this.now()) {
+		this.remove(element)
+		this.stats.Expirations++
+		this.stats.Misses++
 		return "", false
 	}
 
+	this.order.MoveToFront(element)
+	this.stats.Hits++
 	return item.value, true
 }
 
 func (this *Cache) Set(key string, value string, ttl time.Duration) {
-	now := time.Now()
-	this.lock.Lock()
-	this.data[key] = cacheItem{
-		createdAt: now,
-		ttl:       ttl,
+	item := &cacheItem{
+		key:       key,
 		value:     value,
+		expiresAt: this.now().Add(ttl),
+	}
+
+	if this.options.Store != nil {
+		if err := this.options.Store.Save(key, value, item.expiresAt); err != nil {
+			log.Printf("error save cache item %q: %s", key, err)
+		}
+	}
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if element, found := this.data[key]; found {
+		this.remove(element)
+	}
+	this.add(item)
+}
+
+// Delete removes item by key and reports if it was found
+func (this *Cache) Delete(key string) bool {
+	this.lock.Lock()
+	element, found := this.data[key]
+	if found {
+		this.remove(element)
+	}
+	this.removals++
+	this.lock.Unlock()
+
+	if this.options.Store != nil {
+		if err := this.options.Store.Delete(key); err != nil {
+			log.Printf("error delete cache item %q: %s", key, err)
+		}
 	}
+	return found
+}
+
+// Purge removes all items and returns their count
+func (this *Cache) Purge() int {
+	this.lock.Lock()
+	purged := this.order.Len()
+	this.order.Init()
+	this.data = make(map[string]*list.Element, this.options.MaxEntries)
+	this.bytes = 0
+	this.removals++
 	this.lock.Unlock()
+
+	if this.options.Store != nil {
+		if err := this.options.Store.Purge(); err != nil {
+			log.Printf("error purge cache store: %s", err)
+		}
+	}
+	return purged
+}
+
+// DeletePrefix removes items with keys starting with prefix, including items evicted
+// from memory but still kept in the store, and returns their count
+func (this *Cache) DeletePrefix(prefix string) int {
+	this.lock.Lock()
+	purged := 0
+	for key, element := range this.data {
+		if strings.HasPrefix(key, prefix) {
+			this.remove(element)
+			purged++
+		}
+	}
+	this.removals++
+	this.lock.Unlock()
+
+	if this.options.Store != nil {
+		stored, err := this.options.Store.DeletePrefix(prefix)
+		if err != nil {
+			log.Printf("error delete cache items with prefix %q: %s", prefix, err)
+		} else if stored > purged {
+			purged = stored
+		}
+	}
+	return purged
+}
+
+// Sweep removes expired items and returns their count
+func (this *Cache) Sweep() int {
+	now := this.now()
+
+	this.lock.Lock()
+	removed := 0
+	for element := this.order.Back(); element != nil; {
+		prev := element.Prev()
+		if !element.Value.(*cacheItem).expiresAt.After(now) {
+			this.remove(element)
+			removed++
+		}
+		element = prev
+	}
+	this.stats.Expirations += uint64(removed)
+	this.lock.Unlock()
+
+	if this.options.Store != nil {
+		if _, err := this.options.Store.Sweep(now); err != nil {
+			log.Printf("error sweep cache store: %s", err)
+		}
+	}
+	return removed
+}
+
+// Run sweeps expired items every interval
+func (this *Cache) Run(interval time.Duration) {
+	for range time.Tick(interval) {
+		if removed := this.Sweep(); removed > 0 {
+			log.Printf("cache: swept %d expired items", removed)
+		}
+	}
+}
+
+// Close closes the store
+func (this *Cache) Close() error {
+	if this.options.Store == nil {
+		return nil
+	}
+	return this.options.Store.Close()
+}
+
+// load reads item missing in memory from the store, nil if there is none
+func (this *Cache) load(key string) *cacheItem {
+	value, expiresAt, found, err := this.options.Store.Load(key)
+	if err != nil {
+		log.Printf("error load cache item %q: %s", key, err)
+		return nil
+	}
+
+	if !found {
+		return nil
+	}
+	return &cacheItem{key: key, value: value, expiresAt: expiresAt}
+}
+
+// add inserts item in memory evicting least recently used items. Must be called with lock held.
+func (this *Cache) add(item *cacheItem) (*list.Element, bool) {
+	if this.options.MaxBytes > 0 && item.size() > this.options.MaxBytes {
+		log.Printf("cache item %q of %d bytes exceeds cache size", item.key, item.size())
+		return nil, false
+	}
+
+	element := this.order.PushFront(item)
+	this.data[item.key] = element
+	this.bytes += item.size()
+
+	for this.exceeded() {
+		this.remove(this.order.Back())
+		this.stats.Evictions++
+	}
+	return element, true
+}
+
+func (this *Cache) exceeded() bool {
+	if this.order.Len() == 0 {
+		return false
+	}
+	return (this.options.MaxEntries > 0 && this.order.Len() > this.options.MaxEntries) ||
+		(this.options.MaxBytes > 0 && this.bytes > this.options.MaxBytes)
+}
+
+// remove must be called with lock held
+func (this *Cache) remove(element *list.Element) {
+	item := this.order.Remove(element).(*cacheItem)
+	delete(this.data, item.key)
+	this.bytes -= item.size()
 }
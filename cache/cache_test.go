@@ -0,0 +1,242 @@
+package cache
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+type CacheTestSuite struct {
+	now time.Time
+}
+
+var _ = Suite(&CacheTestSuite{})
+
+func (suite *CacheTestSuite) newCache(options Options) *Cache {
+	cache := NewCache(options)
+	cache.now = func() time.Time { return suite.now }
+	return cache
+}
+
+func (suite *CacheTestSuite) SetUpTest(c *C) {
+	suite.now = time.Date(2020, 3, 10, 12, 0, 0, 0, time.UTC)
+}
+
+func (suite *CacheTestSuite) TestEvictLeastRecentlyUsed(c *C) {
+	cache := suite.newCache(Options{MaxEntries: 2})
+	cache.Set("a", "1", time.Minute)
+	cache.Set("b", "2", time.Minute)
+
+	_, found := cache.Get("a")
+	c.Assert(found, Equals, true)
+
+	cache.Set("c", "3", time.Minute)
+	_, found = cache.Get("b")
+	c.Assert(found, Equals, false)
+
+	value, found := cache.Get("a")
+	c.Assert(found, Equals, true)
+	c.Assert(value, Equals, "1")
+
+	stats := cache.Stats()
+	c.Assert(stats.Entries, Equals, 2)
+	c.Assert(stats.Evictions, Equals, uint64(1))
+	c.Assert(stats.Hits, Equals, uint64(2))
+	c.Assert(stats.Misses, Equals, uint64(1))
+}
+
+func (suite *CacheTestSuite) TestMaxBytes(c *C) {
+	cache := suite.newCache(Options{MaxBytes: 10})
+	cache.Set("a", "1234", time.Minute)
+	cache.Set("b", "1234", time.Minute)
+	c.Assert(cache.Stats().Bytes, Equals, 10)
+
+	cache.Set("a", "12345", time.Minute)
+	c.Assert(cache.Keys(), DeepEquals, []KeyInfo{{Key: "a", Bytes: 6, ExpiresAt: suite.now.Add(time.Minute)}})
+
+	cache.Set("c", "too long value", time.Minute)
+	_, found := cache.Get("c")
+	c.Assert(found, Equals, false)
+}
+
+func (suite *CacheTestSuite) TestSweep(c *C) {
+	cache := suite.newCache(Options{})
+	cache.Set("a", "1", time.Minute)
+	cache.Set("b", "2", time.Hour)
+	cache.Set("c", "3", time.Minute)
+
+	suite.now = suite.now.Add(2 * time.Minute)
+	c.Assert(cache.Sweep(), Equals, 2)
+	c.Assert(cache.Keys(), HasLen, 1)
+	c.Assert(cache.Stats().Expirations, Equals, uint64(2))
+}
+
+func (suite *CacheTestSuite) TestServeHTTP(c *C) {
+	cache := suite.newCache(Options{})
+	cache.Set("duty:show:a", "1", time.Minute)
+	cache.Set("duty:show:b", "2", time.Minute)
+	cache.Set("timelogs:show:a", "3", time.Minute)
+
+	recorder := httptest.NewRecorder()
+	cache.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/admin/cache?prefix=duty:", nil))
+	c.Assert(recorder.Body.String(), Equals, "{\"purged\":2}\n")
+
+	recorder = httptest.NewRecorder()
+	cache.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin/cache", nil))
+	var response struct {
+		Entries int       `json:"entries"`
+		Keys    []KeyInfo `json:"keys"`
+	}
+	c.Assert(json.NewDecoder(recorder.Body).Decode(&response), IsNil)
+	c.Assert(response.Entries, Equals, 1)
+	c.Assert(response.Keys[0].Key, Equals, "timelogs:show:a")
+
+	recorder = httptest.NewRecorder()
+	cache.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/admin/cache", nil))
+	c.Assert(recorder.Body.String(), Equals, "{\"purged\":1}\n")
+}
+
+func (suite *CacheTestSuite) TestBoltStore(c *C) {
+	filename := filepath.Join(c.MkDir(), "cache.db")
+	store, err := NewBoltStore(filename)
+	c.Assert(err, IsNil)
+
+	cache := suite.newCache(Options{MaxEntries: 1, Store: store})
+	cache.Set("a", "1", time.Minute)
+	cache.Set("b", "2", time.Hour)
+	c.Assert(cache.Close(), IsNil)
+
+	store, err = NewBoltStore(filename)
+	c.Assert(err, IsNil)
+	cache = suite.newCache(Options{MaxEntries: 1, Store: store})
+	defer cache.Close()
+
+	value, found := cache.Get("a")
+	c.Assert(found, Equals, true)
+	c.Assert(value, Equals, "1")
+
+	suite.now = suite.now.Add(2 * time.Minute)
+	_, found = cache.Get("a")
+	c.Assert(found, Equals, false)
+
+	c.Assert(cache.Sweep(), Equals, 0)
+	_, _, found, err = store.Load("a")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, false)
+
+	value, found = cache.Get("b")
+	c.Assert(found, Equals, true)
+	c.Assert(value, Equals, "2")
+}
+
+func (suite *CacheTestSuite) TestPurgePrefixEvicted(c *C) {
+	store, err := NewBoltStore(filepath.Join(c.MkDir(), "cache.db"))
+	c.Assert(err, IsNil)
+	cache := suite.newCache(Options{MaxEntries: 1, Store: store})
+	defer cache.Close()
+
+	cache.Set("duty:show:a", "1", time.Minute)
+	cache.Set("timelogs:show:a", "2", time.Minute)
+	c.Assert(cache.Keys(), HasLen, 1)
+
+	recorder := httptest.NewRecorder()
+	cache.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/admin/cache?prefix=duty:", nil))
+	c.Assert(recorder.Body.String(), Equals, "{\"purged\":1}\n")
+
+	_, found := cache.Get("duty:show:a")
+	c.Assert(found, Equals, false)
+	value, found := cache.Get("timelogs:show:a")
+	c.Assert(found, Equals, true)
+	c.Assert(value, Equals, "2")
+}
+
+// slowStore blocks loads until released
+type slowStore struct {
+	loading chan bool
+	release chan bool
+}
+
+func (this *slowStore) Load(key string) (string, time.Time, bool, error) {
+	this.loading <- true
+	<-this.release
+	return "stored", time.Now().Add(time.Hour), true, nil
+}
+
+func (this *slowStore) Save(key, value string, expiresAt time.Time) error { return nil }
+func (this *slowStore) Delete(key string) error                           { return nil }
+func (this *slowStore) Purge() error                                      { return nil }
+func (this *slowStore) DeletePrefix(prefix string) (int, error)           { return 0, nil }
+func (this *slowStore) Sweep(now time.Time) (int, error)                  { return 0, nil }
+func (this *slowStore) Close() error                                      { return nil }
+
+func (suite *CacheTestSuite) TestStoreReadWithoutLock(c *C) {
+	store := &slowStore{loading: make(chan bool), release: make(chan bool)}
+	cache := suite.newCache(Options{Store: store})
+
+	loaded := make(chan string)
+	go func() {
+		value, _ := cache.Get("a")
+		loaded <- value
+	}()
+	<-store.loading
+
+	// cache is usable while the store is slow
+	cache.Set("b", "2", time.Minute)
+	value, found := cache.Get("b")
+	c.Assert(found, Equals, true)
+	c.Assert(value, Equals, "2")
+
+	// item set while loading wins over the stored one
+	cache.Set("a", "1", time.Minute)
+	close(store.release)
+	c.Assert(<-loaded, Equals, "1")
+}
+
+func (suite *CacheTestSuite) TestBoltLease(c *C) {
+	// every instance has its own store of the shared file
+	filename := filepath.Join(c.MkDir(), "lease.db")
+	storeA, storeB := NewBoltLeaseStore(filename), NewBoltLeaseStore(filename)
+
+	now := time.Date(2020, 3, 2, 9, 0, 0, 0, time.UTC)
+	acquired, err := storeA.AcquireLease("scheduler", "a", now, 30*time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(acquired, Equals, true)
+
+	acquired, err = storeB.AcquireLease("scheduler", "b", now.Add(20*time.Second), 30*time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(acquired, Equals, false)
+
+	// renewed lease
+	acquired, err = storeA.AcquireLease("scheduler", "a", now.Add(20*time.Second), 30*time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(acquired, Equals, true)
+
+	acquired, err = storeB.AcquireLease("scheduler", "b", now.Add(40*time.Second), 30*time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(acquired, Equals, false)
+
+	// expired lease
+	acquired, err = storeB.AcquireLease("scheduler", "b", now.Add(51*time.Second), 30*time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(acquired, Equals, true)
+
+	c.Assert(storeA.ReleaseLease("scheduler", "a"), IsNil)
+	acquired, err = storeA.AcquireLease("scheduler", "a", now.Add(52*time.Second), 30*time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(acquired, Equals, false)
+
+	c.Assert(storeB.ReleaseLease("scheduler", "b"), IsNil)
+	acquired, err = storeA.AcquireLease("scheduler", "a", now.Add(52*time.Second), 30*time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(acquired, Equals, true)
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// leaseOpenTimeout is how long a lease operation waits for another instance to close the file
+const leaseOpenTimeout = time.Second
+
+var leaseBucket = []byte("leases")
+
+// BoltLeaseStore keeps leases in a bolt database file shared by instances. Bolt locks the file
+// exclusively while it is open, so the file is opened for a single lease operation only.
+// Leases are stored as 8 bytes of expiration unix nano time followed by the owner.
+type BoltLeaseStore struct {
+	filename string
+}
+
+func NewBoltLeaseStore(filename string) *BoltLeaseStore {
+	return &BoltLeaseStore{filename: filename}
+}
+
+// AcquireLease takes or renews lease till now+ttl for owner if the lease is free, expired or owned by owner
+func (this *BoltLeaseStore) AcquireLease(name, owner string, now time.Time, ttl time.Duration) (bool, error) {
+	acquired := false
+	err := this.update(func(bucket *bolt.Bucket) error {
+		data := bucket.Get([]byte(name))
+		if len(data) >= 8 && string(data[8:]) != owner && decodeExpiresAt(data).After(now) {
+			return nil
+		}
+
+		lease := make([]byte, 8+len(owner))
+		binary.BigEndian.PutUint64(lease, uint64(now.Add(ttl).UnixNano()))
+		copy(lease[8:], owner)
+		acquired = true
+		return bucket.Put([]byte(name), lease)
+	})
+	return acquired && err == nil, err
+}
+
+// ReleaseLease frees lease if it is owned by owner
+func (this *BoltLeaseStore) ReleaseLease(name, owner string) error {
+	return this.update(func(bucket *bolt.Bucket) error {
+		data := bucket.Get([]byte(name))
+		if len(data) < 8 || string(data[8:]) != owner {
+			return nil
+		}
+		return bucket.Delete([]byte(name))
+	})
+}
+
+func (this *BoltLeaseStore) update(fn func(bucket *bolt.Bucket) error) error {
+	db, err := bolt.Open(this.filename, 0600, &bolt.Options{Timeout: leaseOpenTimeout})
+	if err != nil {
+		return fmt.Errorf("open lease file %q: %s", this.filename, err)
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(leaseBucket)
+		if err != nil {
+			return err
+		}
+		return fn(bucket)
+	})
+}
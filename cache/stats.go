@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type Stats struct {
+	Entries     int    `json:"entries"`
+	Bytes       int    `json:"bytes"`
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+	Evictions   uint64 `json:"evictions"`
+	Expirations uint64 `json:"expirations"`
+}
+
+type KeyInfo struct {
+	Key       string    `json:"key"`
+	Bytes     int       `json:"bytes"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Stats returns a snapshot of cache counters
+func (this *Cache) Stats() Stats {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	stats := this.stats
+	stats.Entries = this.order.Len()
+	stats.Bytes = this.bytes
+	return stats
+}
+
+// Keys lists items from the most to the least recently used
+func (this *Cache) Keys() []KeyInfo {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	keys := make([]KeyInfo, 0, this.order.Len())
+	for element := this.order.Front(); element != nil; element = element.Next() {
+		item := element.Value.(*cacheItem)
+		keys = append(keys, KeyInfo{
+			Key:       item.key,
+			Bytes:     item.size(),
+			ExpiresAt: item.expiresAt,
+		})
+	}
+	return keys
+}
+
+// ServeHTTP writes cache stats and keys as json on GET. DELETE purges the key
+// from "key" query parameter, keys starting with "prefix" or the whole cache.
+func (this *Cache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		this.writeJSON(w, struct {
+			Stats
+			Keys []KeyInfo `json:"keys"`
+		}{this.Stats(), this.Keys()})
+	case http.MethodDelete:
+		this.writeJSON(w, struct {
+			Purged int `json:"purged"`
+		}{this.purge(r.URL.Query().Get("key"), r.URL.Query().Get("prefix"))})
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (this *Cache) purge(key, prefix string) int {
+	if len(key) > 0 {
+		if this.Delete(key) {
+			return 1
+		}
+		return 0
+	}
+
+	if len(prefix) == 0 {
+		return this.Purge()
+	}
+
+	return this.DeletePrefix(prefix)
+}
+
+func (this *Cache) writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
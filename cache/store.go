@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// IStore persists cache items, so cached results survive restarts
+type IStore interface {
+	Load(key string) (value string, expiresAt time.Time, found bool, err error)
+	Save(key, value string, expiresAt time.Time) error
+	Delete(key string) error
+	Purge() error
+	DeletePrefix(prefix string) (int, error)
+	Sweep(now time.Time) (int, error)
+	Close() error
+}
+
+var boltBucket = []byte("cache")
+
+// BoltStore keeps cache items in a bolt database file.
+// Values are stored as 8 bytes of expiration unix nano time followed by the value.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+func NewBoltStore(filename string) (*BoltStore, error) {
+	db, err := bolt.Open(filename, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open cache file %q: %s", filename, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (this *BoltStore) Load(key string) (value string, expiresAt time.Time, found bool, err error) {
+	err = this.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltBucket).Get([]byte(key))
+		if len(data) < 8 {
+			return nil
+		}
+
+		expiresAt = decodeExpiresAt(data)
+		value, found = string(data[8:]), true
+		return nil
+	})
+	return
+}
+
+func (this *BoltStore) Save(key, value string, expiresAt time.Time) error {
+	data := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(data, uint64(expiresAt.UnixNano()))
+	copy(data[8:], value)
+
+	return this.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put([]byte(key), data)
+	})
+}
+
+func (this *BoltStore) Delete(key string) error {
+	return this.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Delete([]byte(key))
+	})
+}
+
+func (this *BoltStore) Purge() error {
+	return this.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(boltBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(boltBucket)
+		return err
+	})
+}
+
+// DeletePrefix removes items with keys starting with prefix and returns their count
+func (this *BoltStore) DeletePrefix(prefix string) (int, error) {
+	var matched [][]byte
+	err := this.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		cursor := bucket.Cursor()
+		for key, _ := cursor.Seek([]byte(prefix)); key != nil && bytes.HasPrefix(key, []byte(prefix)); key, _ = cursor.Next() {
+			matched = append(matched, append([]byte(nil), key...))
+		}
+
+		for _, key := range matched {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return len(matched), err
+}
+
+func (this *BoltStore) Sweep(now time.Time) (int, error) {
+	var expired [][]byte
+	err := this.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		err := bucket.ForEach(func(key, data []byte) error {
+			if len(data) < 8 || !decodeExpiresAt(data).After(now) {
+				expired = append(expired, append([]byte(nil), key...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// deleting keys during iteration makes bolt cursor skip items
+		for _, key := range expired {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return len(expired), err
+}
+
+func (this *BoltStore) Close() error {
+	return this.db.Close()
+}
+
+func decodeExpiresAt(data []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(data)))
+}
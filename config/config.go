@@ -3,23 +3,80 @@ package config
 import (
 	"fmt"
 	"io/ioutil"
-	"strconv"
+	"reflect"
+	"strings"
 	"time"
 
+	"bobby/cron"
 	"bobby/utils"
 
 	"gopkg.in/yaml.v2"
 )
 
+const (
+	defaultUsersRefreshInterval  = time.Hour
+	defaultOnCallRefreshInterval = 10 * time.Minute
+	defaultDeliveryConcurrency   = 4
+	defaultDeliveryMaxAttempts   = 5
+	defaultCacheMaxEntries       = 256
+	defaultCacheMaxBytes         = 4 << 20
+	defaultCacheSweepInterval    = time.Minute
+	defaultCacheFile             = "bobby_cache.db"
+	defaultCachePrewarmLead      = 5 * time.Minute
+	defaultStaleCacheTTL         = 24 * time.Hour
+	defaultErrorCacheTTL         = 30 * time.Second
+	defaultSchedulerCatchUpGrace = time.Hour
+	defaultSchedulerLockFile     = "bobby.lock"
+	defaultSchedulerLockInterval = 10 * time.Second
+	defaultSchedulerLeaseFile    = "bobby_lease.db"
+	defaultSchedulerLeaseTTL     = 30 * time.Second
+	defaultRosterRefreshInterval = time.Hour
+	defaultShutdownTimeout       = 30 * time.Second
+	defaultLDAPMemberFilter      = "(memberOf=%s)"
+	defaultLDAPNameAttribute     = "cn"
+	defaultLDAPEmailAttribute    = "mail"
+	defaultEmailWeeklySchedule   = "0 9 * * MON"
+
+	TransportHTTP       = "http"
+	TransportSocketMode = "socket-mode"
+
+	NotifierSlack      = "slack"
+	NotifierMattermost = "mattermost"
+	NotifierMSTeams    = "msteams"
+
+	CacheBackendMemory = "memory"
+	CacheBackendFile   = "file"
+
+	LeaderElectionNone  = "none"
+	LeaderElectionFile  = "file"
+	LeaderElectionStore = "store"
+)
+
 type Config struct {
 	Main struct {
 		Host string `yaml:"host"`
 		Port string `yaml:"port"`
+		// ShutdownTimeout bounds waiting for commands, jobs and outbound messages on SIGTERM
+		ShutdownTimeout time.Duration `yaml:"shutdown-timeout"`
 	} `yaml:"main"`
+	Admin struct {
+		Token string `yaml:"token"`
+	} `yaml:"admin"`
 	Slack struct {
-		Token   string `yaml:"token"`
-		Channel string `yaml:"channel"`
+		Token                string        `yaml:"token"`
+		Channel              string        `yaml:"channel"`
+		UsersRefreshInterval time.Duration `yaml:"users-refresh-interval"`
+		Transport            string        `yaml:"transport"`
+		AppToken             string        `yaml:"app-token"`
 	} `yaml:"slack"`
+	Notifier   string `yaml:"notifier"`
+	Mattermost struct {
+		WebhookURL string `yaml:"webhook-url"`
+		Channel    string `yaml:"channel"`
+	} `yaml:"mattermost"`
+	MSTeams struct {
+		WebhookURL string `yaml:"webhook-url"`
+	} `yaml:"msteams"`
 	Jira struct {
 		Token string `yaml:"token"`
 	} `yaml:"jira"`
@@ -27,24 +84,95 @@ type Config struct {
 		Token string `yaml:"token"`
 	} `yaml:"opsgenie"`
 	DutyCommand struct {
-		Enable                 bool          `yaml:"enable"`
-		Name                   string        `yaml:"name"`
-		Token                  string        `yaml:"token"`
-		ScheduleID             string        `yaml:"schedule-id"`
-		CacheTTL               time.Duration `yaml:"cache-ttl"`
-		DailyMessageTimeString string        `yaml:"daily-message-time"`
-		DailyMessageTime       utils.DayTime `yaml:"-"`
+		Enable                 bool           `yaml:"enable"`
+		Name                   string         `yaml:"name"`
+		Token                  string         `yaml:"token"`
+		ScheduleID             string         `yaml:"schedule-id"`
+		CacheTTL               time.Duration  `yaml:"cache-ttl"`
+		StaleCacheTTL          time.Duration  `yaml:"stale-cache-ttl"`
+		ErrorCacheTTL          time.Duration  `yaml:"error-cache-ttl"`
+		DailyMessageTimeString string         `yaml:"daily-message-time"`
+		DailyMessageTime       utils.DayTime  `yaml:"-"`
+		Schedule               string         `yaml:"schedule"`
+		Timezone               string         `yaml:"timezone"`
+		DailyMessageSchedule   *cron.Schedule `yaml:"-"`
 	} `yaml:"duty-command"`
 	TimelogsCommand struct {
-		Enable                 bool          `yaml:"enable"`
-		Name                   string        `yaml:"name"`
-		Token                  string        `yaml:"token"`
-		Team                   []User        `yaml:"team"`
-		MinimumTimeSpent       time.Duration `yaml:"minimum-time-logged"`
-		CacheTTL               time.Duration `yaml:"cache-ttl"`
-		DailyMessageTimeString string        `yaml:"daily-message-time"`
-		DailyMessageTime       utils.DayTime `yaml:"-"`
+		Enable                 bool           `yaml:"enable"`
+		Name                   string         `yaml:"name"`
+		Token                  string         `yaml:"token"`
+		Team                   []User         `yaml:"team"`
+		MinimumTimeSpent       time.Duration  `yaml:"minimum-time-logged"`
+		CacheTTL               time.Duration  `yaml:"cache-ttl"`
+		StaleCacheTTL          time.Duration  `yaml:"stale-cache-ttl"`
+		ErrorCacheTTL          time.Duration  `yaml:"error-cache-ttl"`
+		DailyMessageTimeString string         `yaml:"daily-message-time"`
+		DailyMessageTime       utils.DayTime  `yaml:"-"`
+		Schedule               string         `yaml:"schedule"`
+		Timezone               string         `yaml:"timezone"`
+		DailyMessageSchedule   *cron.Schedule `yaml:"-"`
+		Roster                 RosterGroups   `yaml:"roster"`
 	} `yaml:"timelogs-command"`
+	Teams []Team `yaml:"teams"`
+	Email struct {
+		Enable             bool           `yaml:"enable"`
+		Host               string         `yaml:"host"`
+		Port               string         `yaml:"port"`
+		Username           string         `yaml:"username"`
+		Password           string         `yaml:"password"`
+		From               string         `yaml:"from"`
+		StartTLS           bool           `yaml:"starttls"`
+		SubjectPrefix      string         `yaml:"subject-prefix"`
+		Recipients         []string       `yaml:"recipients"`
+		WeeklySchedule     string         `yaml:"weekly-schedule"`
+		Timezone           string         `yaml:"timezone"`
+		WeeklyCronSchedule *cron.Schedule `yaml:"-"`
+	} `yaml:"email"`
+	Delivery struct {
+		Concurrency    int    `yaml:"concurrency"`
+		MaxAttempts    int    `yaml:"max-attempts"`
+		DeadLetterFile string `yaml:"dead-letter-file"`
+	} `yaml:"delivery"`
+	Cache struct {
+		Backend       string        `yaml:"backend"`
+		File          string        `yaml:"file"`
+		MaxEntries    int           `yaml:"max-entries"`
+		MaxBytes      int           `yaml:"max-bytes"`
+		SweepInterval time.Duration `yaml:"sweep-interval"`
+		Prewarm       bool          `yaml:"prewarm"`
+		PrewarmLead   time.Duration `yaml:"prewarm-lead"`
+	} `yaml:"cache"`
+	Scheduler struct {
+		HistoryFile    string        `yaml:"history-file"`
+		CatchUpGrace   time.Duration `yaml:"catch-up-grace"`
+		LeaderElection string        `yaml:"leader-election"`
+		LockFile       string        `yaml:"lock-file"`
+		LockInterval   time.Duration `yaml:"lock-interval"`
+		LeaseFile      string        `yaml:"lease-file"`
+		LeaseTTL       time.Duration `yaml:"lease-ttl"`
+		InstanceID     string        `yaml:"instance-id"`
+	} `yaml:"scheduler"`
+	RosterSync struct {
+		Enable          bool          `yaml:"enable"`
+		RefreshInterval time.Duration `yaml:"refresh-interval"`
+	} `yaml:"roster-sync"`
+	LDAP struct {
+		URL            string `yaml:"url"`
+		BindDN         string `yaml:"bind-dn"`
+		BindPassword   string `yaml:"bind-password"`
+		BaseDN         string `yaml:"base-dn"`
+		MemberFilter   string `yaml:"member-filter"`
+		NameAttribute  string `yaml:"name-attribute"`
+		EmailAttribute string `yaml:"email-attribute"`
+	} `yaml:"ldap"`
+	OnCallSync struct {
+		Enable          bool          `yaml:"enable"`
+		Team            string        `yaml:"team"`
+		UserGroupID     string        `yaml:"usergroup-id"`
+		TopicChannelID  string        `yaml:"topic-channel-id"`
+		AlertChannel    string        `yaml:"alert-channel"`
+		RefreshInterval time.Duration `yaml:"refresh-interval"`
+	} `yaml:"oncall-sync"`
 }
 
 func ParseConfig(filename string) (*Config, error) {
@@ -53,87 +181,132 @@ func ParseConfig(filename string) (*Config, error) {
 		return nil, err
 	}
 
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, err
-	}
-
-	if err := validate(&cfg); err != nil {
-		return nil, err
+	if strings.HasSuffix(filename, EncryptedSuffix) {
+		if data, err = Decrypt(data); err != nil {
+			return nil, err
+		}
 	}
 
-	return &cfg, nil
+	return parseConfig(data)
 }
 
-func validate(cfg *Config) error {
-	port, err := strconv.Atoi(cfg.Main.Port)
-	if err != nil {
-		return err
+// parseConfig decodes and validates config, all problems are reported at once as ValidationError
+func parseConfig(data []byte) (*Config, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
 	}
 
-	if port <= 0 || port >= 60000 {
-		return fmt.Errorf("port value must be positive and less then 60000")
-	}
+	validator := &validator{}
+	checkUnknownKeys(validator, "", raw, reflect.TypeOf(Config{}))
 
-	if len(cfg.Slack.Token) == 0 {
-		return fmt.Errorf("slack token must be non empty")
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		typeErr, ok := err.(*yaml.TypeError)
+		if !ok {
+			return nil, err
+		}
+		for _, problem := range typeErr.Errors {
+			validator.problems = append(validator.problems, problem)
+		}
 	}
 
-	if len(cfg.Slack.Channel) == 0 {
-		return fmt.Errorf("slack channel must be non empty")
-	}
+	resolveSecrets(validator, &cfg)
 
-	if len(cfg.Jira.Token) == 0 {
-		return fmt.Errorf("jira token must be non empty")
+	validate(validator, &cfg)
+	if err := validator.err(); err != nil {
+		return nil, err
 	}
+	return &cfg, nil
+}
 
-	if len(cfg.DutyCommand.DailyMessageTimeString) == 0 {
-		return fmt.Errorf("duty daily message time must be non empty")
+// UsesSlack reports whether slack web api is needed: for notifications, socket mode, on-call or roster sync
+func (this *Config) UsesSlack() bool {
+	if this.Slack.Transport == TransportSocketMode || this.OnCallSync.Enable {
+		return true
 	}
 
-	if len(cfg.DutyCommand.Name) == 0 {
-		return fmt.Errorf("empty duty command name")
+	for _, team := range this.GetTeams() {
+		if team.SendsDailyMessages() && this.GetNotifier(team) == NotifierSlack {
+			return true
+		}
+		if this.SyncsRoster(team.Roster) && len(team.Roster.SlackUserGroupID) > 0 {
+			return true
+		}
 	}
+	return false
+}
 
-	if len(cfg.Opsgenie.Token) == 0 {
-		return fmt.Errorf("opsgenie token must be non empty")
-	}
+// SyncsRoster reports whether team members are synced from roster groups
+func (this *Config) SyncsRoster(roster RosterGroups) bool {
+	return this.RosterSync.Enable && !roster.IsEmpty()
+}
 
-	dutyDailyMessageTime, err := utils.ParseDayTime(cfg.DutyCommand.DailyMessageTimeString)
-	if err != nil {
-		return fmt.Errorf("error parse duty daily message date time: %s", err.Error())
+// SendsDailyMessages reports whether any daily message of any team is enabled
+func (this *Config) SendsDailyMessages() bool {
+	for _, team := range this.GetTeams() {
+		if team.SendsDailyMessages() {
+			return true
+		}
 	}
-	cfg.DutyCommand.DailyMessageTime = dutyDailyMessageTime
+	return false
+}
 
-	timlogsDailyMessageTime, err := utils.ParseDayTime(cfg.TimelogsCommand.DailyMessageTimeString)
-	if err != nil {
-		return fmt.Errorf("error parse timelogs daily message date time: %s", err.Error())
+// UsesDuty reports whether opsgenie is needed: for duty command, duty daily message or on-call sync
+func (this *Config) UsesDuty() bool {
+	for _, team := range this.GetTeams() {
+		if team.DutyMessage.Enable {
+			return true
+		}
 	}
-	cfg.TimelogsCommand.DailyMessageTime = timlogsDailyMessageTime
+	return len(this.DutyCommand.Name) > 0 || this.OnCallSync.Enable
+}
 
-	if len(cfg.TimelogsCommand.Name) == 0 {
-		return fmt.Errorf("empty time logs command name")
+// UsesTimelogs reports whether jira is needed: for timelogs command or timelogs daily message
+func (this *Config) UsesTimelogs() bool {
+	for _, team := range this.GetTeams() {
+		if team.TimelogsMessage.Enable {
+			return true
+		}
 	}
+	return len(this.TimelogsCommand.Name) > 0
+}
 
-	if len(cfg.TimelogsCommand.Token) == 0 {
-		return fmt.Errorf("timelogs command auth token must be non empty")
+// defaultCacheTTLs fills command stale and error cache ttls. Stale ttl is never shorter than fresh one.
+func defaultCacheTTLs(fresh, stale, errors time.Duration) (time.Duration, time.Duration) {
+	if stale == 0 {
+		stale = defaultStaleCacheTTL
 	}
 
-	if cfg.TimelogsCommand.MinimumTimeSpent == 0 {
-		return fmt.Errorf("timelogs command minimum time logged")
+	if stale < fresh {
+		stale = fresh
 	}
 
-	if len(cfg.TimelogsCommand.Team) == 0 {
-		return fmt.Errorf("timelogs command team must be non empty")
+	if errors == 0 {
+		errors = defaultErrorCacheTTL
 	}
+	return stale, errors
+}
+
+// parseSchedule parses cron expression schedule in timezone. Daily message time
+// is a shorthand for "every working day at" schedule.
+func parseSchedule(dailyMessageTime, schedule, timezone string) (utils.DayTime, *cron.Schedule, error) {
+	var dayTime utils.DayTime
+	if len(dailyMessageTime) > 0 {
+		var err error
+		if dayTime, err = utils.ParseDayTime(dailyMessageTime); err != nil {
+			return dayTime, nil, err
+		}
 
-	if len(cfg.DutyCommand.Token) == 0 {
-		return fmt.Errorf("duty command token must be non empty")
+		if len(schedule) == 0 {
+			schedule = fmt.Sprintf("%d %d * * MON-FRI", dayTime.Minute, dayTime.Hour)
+		}
 	}
 
-	if len(cfg.DutyCommand.ScheduleID) == 0 {
-		return fmt.Errorf("duty command schedule id must be non empty")
+	if len(schedule) == 0 {
+		return dayTime, nil, fmt.Errorf("daily message time or schedule must be non empty")
 	}
 
-	return nil
+	cronSchedule, err := cron.LoadSchedule(schedule, timezone)
+	return dayTime, cronSchedule, err
 }
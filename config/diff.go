@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Diff lists changed config values as "path: old -> new" lines. Values of secret fields
+// (tokens, passwords, webhook urls) are not shown, derived fields are skipped.
+func Diff(old, new *Config) []string {
+	var changes []string
+	diffValues(&changes, "", false, reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem())
+	return changes
+}
+
+func isSecretField(name string) bool {
+	for _, secret := range []string{"token", "password", "webhook-url"} {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+func diffValues(changes *[]string, path string, secret bool, old, new reflect.Value) {
+	switch old.Kind() {
+	case reflect.Struct:
+		for i := 0; i < old.NumField(); i++ {
+			name := strings.Split(old.Type().Field(i).Tag.Get("yaml"), ",")[0]
+			if name == "-" || len(name) == 0 {
+				continue
+			}
+
+			if len(path) > 0 {
+				name = path + "." + name
+			}
+			diffValues(changes, name, secret || isSecretField(name), old.Field(i), new.Field(i))
+		}
+	case reflect.Slice:
+		for i := 0; i < old.Len() || i < new.Len(); i++ {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= new.Len():
+				*changes = append(*changes, itemPath+": removed "+formatValue(secret, old.Index(i)))
+			case i >= old.Len():
+				*changes = append(*changes, itemPath+": added "+formatValue(secret, new.Index(i)))
+			default:
+				diffValues(changes, itemPath, secret, old.Index(i), new.Index(i))
+			}
+		}
+	default:
+		if !reflect.DeepEqual(old.Interface(), new.Interface()) {
+			*changes = append(*changes, path+": "+formatValue(secret, old)+" -> "+formatValue(secret, new))
+		}
+	}
+}
+
+func formatValue(secret bool, value reflect.Value) string {
+	if secret {
+		return "<redacted>"
+	}
+
+	if value.Kind() == reflect.Struct {
+		return fmt.Sprintf("%+v", value.Interface())
+	}
+	return fmt.Sprintf("%q", fmt.Sprint(value.Interface()))
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+const (
+	// EncryptedSuffix marks config files encrypted with the key from KeyEnv
+	EncryptedSuffix = ".enc"
+	// KeyEnv is environment variable with base64 encoded 32 bytes key of encrypted config
+	KeyEnv = "BOBBY_CONFIG_KEY"
+
+	nonceSize = 24
+	keySize   = 32
+)
+
+func loadKey() (*[keySize]byte, error) {
+	encoded, found := os.LookupEnv(KeyEnv)
+	if !found {
+		return nil, fmt.Errorf("config key environment variable %s is not set", KeyEnv)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(decoded) != keySize {
+		return nil, fmt.Errorf("config key %s must be base64 encoded %d bytes", KeyEnv, keySize)
+	}
+
+	var key [keySize]byte
+	copy(key[:], decoded)
+	return &key, nil
+}
+
+// Encrypt seals config file data with NaCl secretbox using the key from KeyEnv.
+// The result is base64 encoded nonce followed by the sealed data.
+func Encrypt(data []byte) ([]byte, error) {
+	key, err := loadKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce [nonceSize]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return nil, err
+	}
+
+	sealed := secretbox.Seal(nonce[:], data, &nonce, key)
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(len(sealed)))
+	base64.StdEncoding.Encode(encoded, sealed)
+	return append(encoded, '\n'), nil
+}
+
+// Decrypt opens config file data sealed by Encrypt
+func Decrypt(data []byte) ([]byte, error) {
+	key, err := loadKey()
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := make([]byte, base64.StdEncoding.DecodedLen(len(data)))
+	n, err := base64.StdEncoding.Decode(sealed, bytes.TrimSpace(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decode encrypted config: %s", err.Error())
+	}
+	sealed = sealed[:n]
+
+	if len(sealed) < nonceSize {
+		return nil, fmt.Errorf("encrypted config is too short")
+	}
+
+	var nonce [nonceSize]byte
+	copy(nonce[:], sealed)
+	plain, ok := secretbox.Open(nil, sealed[nonceSize:], &nonce, key)
+	if !ok {
+		return nil, fmt.Errorf("error decrypt config: wrong key or corrupted file")
+	}
+	return plain, nil
+}
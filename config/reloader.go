@@ -0,0 +1,127 @@
+package config
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay coalesces bursts of file events (editors write files in several steps)
+const reloadDelay = 500 * time.Millisecond
+
+// Reloader keeps current config and replaces it when the config file changes. A new config
+// is swapped in only after it is fully parsed and validated, an invalid one is logged and ignored.
+type Reloader struct {
+	filename string
+
+	lock      sync.Mutex
+	cfg       *Config
+	callbacks []func(old, cfg *Config)
+}
+
+func NewReloader(filename string, cfg *Config) *Reloader {
+	return &Reloader{
+		filename: filename,
+		cfg:      cfg,
+	}
+}
+
+// Config returns current config. The config must not be modified.
+func (this *Reloader) Config() *Config {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.cfg
+}
+
+// OnReload adds callback called with the old and the new config after every reload
+func (this *Reloader) OnReload(callback func(old, cfg *Config)) {
+	this.lock.Lock()
+	this.callbacks = append(this.callbacks, callback)
+	this.lock.Unlock()
+}
+
+// Reload parses config file and applies it if it changed
+func (this *Reloader) Reload() error {
+	cfg, err := ParseConfig(this.filename)
+	if err != nil {
+		return err
+	}
+
+	this.lock.Lock()
+	old := this.cfg
+	changes := Diff(old, cfg)
+	if len(changes) == 0 {
+		this.lock.Unlock()
+		log.Printf("config %q is not changed", this.filename)
+		return nil
+	}
+
+	this.cfg = cfg
+	callbacks := append([]func(old, cfg *Config){}, this.callbacks...)
+	this.lock.Unlock()
+
+	log.Printf("config %q is reloaded:", this.filename)
+	for _, change := range changes {
+		log.Printf("\t%s", change)
+	}
+
+	for _, callback := range callbacks {
+		callback(old, cfg)
+	}
+	return nil
+}
+
+// Run reloads config on every signal and on config file change until signals channel is closed
+func (this *Reloader) Run(signals <-chan os.Signal) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("error watch config %q: %s", this.filename, err)
+	} else {
+		defer watcher.Close()
+
+		// directory is watched, so files replaced by rename (editors, k8s config maps) are noticed
+		if err := watcher.Add(filepath.Dir(this.filename)); err != nil {
+			log.Printf("error watch config %q: %s", this.filename, err)
+		}
+	}
+
+	var events <-chan fsnotify.Event
+	var errors <-chan error
+	if watcher != nil {
+		events, errors = watcher.Events, watcher.Errors
+	}
+
+	timer := time.NewTimer(reloadDelay)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case _, ok := <-signals:
+			if !ok {
+				return
+			}
+			log.Printf("reload config %q on signal", this.filename)
+			this.reload()
+		case event := <-events:
+			if filepath.Clean(event.Name) == filepath.Clean(this.filename) {
+				timer.Reset(reloadDelay)
+			}
+		case err := <-errors:
+			log.Printf("error watch config %q: %s", this.filename, err)
+		case <-timer.C:
+			log.Printf("reload config %q on file change", this.filename)
+			this.reload()
+		}
+	}
+}
+
+func (this *Reloader) reload() {
+	if err := this.Reload(); err != nil {
+		log.Printf("error reload config %q, keep running with the old one: %s", this.filename, err)
+	}
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+type ReloaderTestSuite struct {
+	filename string
+}
+
+var _ = Suite(&ReloaderTestSuite{})
+
+const testConfig = `
+main:
+  port: 8080
+slack:
+  token: xoxb-secret
+  channel: team
+jira:
+  token: jira-secret
+opsgenie:
+  token: opsgenie-secret
+duty-command:
+  name: duty
+  token: duty-secret
+  schedule-id: schedule
+  daily-message-time: "09:47"
+timelogs-command:
+  name: timelogs
+  token: timelogs-secret
+  minimum-time-logged: 6h
+  daily-message-time: "09:47"
+  team:
+  - name: John Doe
+    jira-login: johndoe
+`
+
+func (suite *ReloaderTestSuite) SetUpTest(c *C) {
+	suite.filename = filepath.Join(c.MkDir(), "conf.yaml")
+	suite.write(c, testConfig)
+}
+
+func (suite *ReloaderTestSuite) write(c *C, data string) {
+	c.Assert(ioutil.WriteFile(suite.filename, []byte(data), 0600), IsNil)
+}
+
+func (suite *ReloaderTestSuite) TestReload(c *C) {
+	cfg, err := ParseConfig(suite.filename)
+	c.Assert(err, IsNil)
+
+	reloader := NewReloader(suite.filename, cfg)
+	var reloaded []*Config
+	reloader.OnReload(func(old, cfg *Config) {
+		reloaded = append(reloaded, old, cfg)
+	})
+
+	// not changed
+	c.Assert(reloader.Reload(), IsNil)
+	c.Assert(reloaded, HasLen, 0)
+
+	// invalid config keeps the old one
+	suite.write(c, strings.Replace(testConfig, "minimum-time-logged: 6h", "minimum-time-logged: 0", 1))
+	c.Assert(reloader.Reload(), NotNil)
+	c.Assert(reloader.Config(), Equals, cfg)
+
+	suite.write(c, strings.Replace(testConfig, "timelogs-secret", "timelogs-new-secret", 1)+
+		"  - name: Jane Doe\n    jira-login: janedoe\n")
+	c.Assert(reloader.Reload(), IsNil)
+	c.Assert(reloaded, HasLen, 2)
+	c.Assert(reloaded[0], Equals, cfg)
+	c.Assert(reloaded[1], Equals, reloader.Config())
+	c.Assert(reloader.Config().TimelogsCommand.Team, HasLen, 2)
+	c.Assert(Diff(cfg, reloader.Config()), DeepEquals, []string{
+		"timelogs-command.token: <redacted> -> <redacted>",
+		"timelogs-command.team[1]: added {Name:Jane Doe JiraLogin:janedoe SlackLogin: MattermostLogin: Email: EmailDigest:false}",
+	})
+}
+
+func (suite *ReloaderTestSuite) TestWatch(c *C) {
+	cfg, err := ParseConfig(suite.filename)
+	c.Assert(err, IsNil)
+
+	reloader := NewReloader(suite.filename, cfg)
+	reloaded := make(chan *Config, 10)
+	reloader.OnReload(func(old, cfg *Config) {
+		reloaded <- cfg
+	})
+
+	signals := make(chan os.Signal)
+	go reloader.Run(signals)
+	defer close(signals)
+	time.Sleep(100 * time.Millisecond)
+
+	// file replaced by rename
+	tmp := suite.filename + ".tmp"
+	c.Assert(ioutil.WriteFile(tmp, []byte(strings.Replace(testConfig, "6h", "7h", 1)), 0600), IsNil)
+	c.Assert(os.Rename(tmp, suite.filename), IsNil)
+
+	select {
+	case cfg := <-reloaded:
+		c.Assert(cfg.TimelogsCommand.MinimumTimeSpent, Equals, 7*time.Hour)
+	case <-time.After(5 * time.Second):
+		c.Fatal("config wasn't reloaded on file change")
+	}
+
+	suite.write(c, strings.Replace(testConfig, "6h", "8h", 1))
+	select {
+	case cfg := <-reloaded:
+		c.Assert(cfg.TimelogsCommand.MinimumTimeSpent, Equals, 8*time.Hour)
+	case <-time.After(5 * time.Second):
+		c.Fatal("config wasn't reloaded on file change")
+	}
+}
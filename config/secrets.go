@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+const secretFilePrefix = "file:"
+
+var envReferenceRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// resolveSecrets resolves references in every string value, so secrets can be kept out of the config file:
+// "${NAME}" is replaced with environment variable NAME and "file:/run/secrets/x" with the file contents.
+// Every unresolved reference is added to validator.
+func resolveSecrets(validator *validator, cfg *Config) {
+	resolveValue(validator, "", reflect.ValueOf(cfg).Elem())
+}
+
+func resolveValue(validator *validator, path string, value reflect.Value) {
+	switch value.Kind() {
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			name := strings.Split(value.Type().Field(i).Tag.Get("yaml"), ",")[0]
+			if name == "-" || len(name) == 0 {
+				continue
+			}
+
+			if len(path) > 0 {
+				name = path + "." + name
+			}
+			resolveValue(validator, name, value.Field(i))
+		}
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			resolveValue(validator, fmt.Sprintf("%s[%d]", path, i), value.Index(i))
+		}
+	case reflect.String:
+		resolved, err := resolveString(value.String())
+		if err != nil {
+			validator.add(path, "%s", err.Error())
+			return
+		}
+		value.SetString(resolved)
+	}
+}
+
+func resolveString(value string) (string, error) {
+	var err error
+	value = envReferenceRegexp.ReplaceAllStringFunc(value, func(reference string) string {
+		name := envReferenceRegexp.FindStringSubmatch(reference)[1]
+		env, found := os.LookupEnv(name)
+		if !found && err == nil {
+			err = fmt.Errorf("environment variable %q is not set", name)
+		}
+		return env
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasPrefix(value, secretFilePrefix) {
+		return value, nil
+	}
+
+	filename := strings.TrimPrefix(value, secretFilePrefix)
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("error read secret file: %s", err.Error())
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type SecretsTestSuite struct {
+	dir string
+}
+
+var _ = Suite(&SecretsTestSuite{})
+
+func (suite *SecretsTestSuite) SetUpTest(c *C) {
+	suite.dir = c.MkDir()
+}
+
+func (suite *SecretsTestSuite) TearDownTest(c *C) {
+	os.Unsetenv("BOBBY_TEST_SLACK_TOKEN")
+	os.Unsetenv(KeyEnv)
+}
+
+func (suite *SecretsTestSuite) TestReferences(c *C) {
+	secret := filepath.Join(suite.dir, "jira")
+	c.Assert(ioutil.WriteFile(secret, []byte("jira-from-file\n"), 0600), IsNil)
+	os.Setenv("BOBBY_TEST_SLACK_TOKEN", "xoxb-from-env")
+
+	data := strings.Replace(testConfig, "xoxb-secret", "${BOBBY_TEST_SLACK_TOKEN}", 1)
+	data = strings.Replace(data, "jira-secret", "file:"+secret, 1)
+	filename := filepath.Join(suite.dir, "conf.yaml")
+	c.Assert(ioutil.WriteFile(filename, []byte(data), 0600), IsNil)
+
+	cfg, err := ParseConfig(filename)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Slack.Token, Equals, "xoxb-from-env")
+	c.Assert(cfg.Jira.Token, Equals, "jira-from-file")
+
+	// every unresolved secret is reported
+	os.Unsetenv("BOBBY_TEST_SLACK_TOKEN")
+	c.Assert(os.Remove(secret), IsNil)
+	_, err = ParseConfig(filename)
+	c.Assert(err, FitsTypeOf, &ValidationError{})
+	c.Assert(err.(*ValidationError).Problems, DeepEquals, []string{
+		`slack.token: environment variable "BOBBY_TEST_SLACK_TOKEN" is not set`,
+		"jira.token: error read secret file: open " + secret + ": no such file or directory",
+	})
+}
+
+func (suite *SecretsTestSuite) TestEncrypted(c *C) {
+	os.Setenv(KeyEnv, "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	encrypted, err := Encrypt([]byte(testConfig))
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(encrypted), "secret"), Equals, false)
+
+	filename := filepath.Join(suite.dir, "conf.yaml"+EncryptedSuffix)
+	c.Assert(ioutil.WriteFile(filename, encrypted, 0600), IsNil)
+
+	cfg, err := ParseConfig(filename)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Slack.Token, Equals, "xoxb-secret")
+
+	os.Setenv(KeyEnv, "ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=")
+	_, err = ParseConfig(filename)
+	c.Assert(err, ErrorMatches, "error decrypt config: wrong key or corrupted file")
+
+	os.Unsetenv(KeyEnv)
+	_, err = ParseConfig(filename)
+	c.Assert(err, ErrorMatches, "config key environment variable BOBBY_CONFIG_KEY is not set")
+}
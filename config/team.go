@@ -0,0 +1,173 @@
+package config
+
+import (
+	"time"
+
+	"bobby/cron"
+	"bobby/utils"
+)
+
+// Team is a roster with its own channel, duty schedule and daily messages.
+// Command names, tokens and cache ttls are shared by all teams.
+type Team struct {
+	Name string `yaml:"name"`
+	// Channel receives team daily messages, commands sent from it are resolved to the team
+	Channel          string        `yaml:"channel"`
+	ScheduleID       string        `yaml:"schedule-id"`
+	MinimumTimeSpent time.Duration `yaml:"minimum-time-logged"`
+	Members          []User        `yaml:"members"`
+	DutyMessage      DailyMessage  `yaml:"duty-message"`
+	TimelogsMessage  DailyMessage  `yaml:"timelogs-message"`
+	Roster           RosterGroups  `yaml:"roster"`
+	// Notifier and WebhookURL override the global notifier and its webhook for the team
+	Notifier   string `yaml:"notifier"`
+	WebhookURL string `yaml:"webhook-url"`
+}
+
+// RosterGroups are directory groups team members are synced from
+type RosterGroups struct {
+	SlackUserGroupID string `yaml:"slack-usergroup-id"`
+	JiraGroup        string `yaml:"jira-group"`
+	LDAPGroup        string `yaml:"ldap-group"`
+}
+
+func (this *RosterGroups) IsEmpty() bool {
+	return len(this.SlackUserGroupID) == 0 && len(this.JiraGroup) == 0 && len(this.LDAPGroup) == 0
+}
+
+type DailyMessage struct {
+	Enable                 bool           `yaml:"enable"`
+	DailyMessageTimeString string         `yaml:"time"`
+	Schedule               string         `yaml:"schedule"`
+	Timezone               string         `yaml:"timezone"`
+	DailyMessageTime       utils.DayTime  `yaml:"-"`
+	DailyMessageSchedule   *cron.Schedule `yaml:"-"`
+}
+
+// Title makes message title, named team is added to it as several teams may share a channel
+func (this *Team) Title(title string) string {
+	if len(this.Name) == 0 {
+		return title + ":"
+	}
+	return title + " (" + this.Name + "):"
+}
+
+// SendsDailyMessages reports whether the team has any daily message enabled
+func (this *Team) SendsDailyMessages() bool {
+	return this.DutyMessage.Enable || this.TimelogsMessage.Enable
+}
+
+// GetNotifier returns notifier of the team, the global one by default
+func (this *Config) GetNotifier(team Team) string {
+	if len(team.Notifier) > 0 {
+		return team.Notifier
+	}
+	return this.Notifier
+}
+
+// GetWebhookURL returns webhook url of the team notifier, the global one of the notifier by default
+func (this *Config) GetWebhookURL(team Team) string {
+	if len(team.WebhookURL) > 0 {
+		return team.WebhookURL
+	}
+
+	switch this.GetNotifier(team) {
+	case NotifierMattermost:
+		return this.Mattermost.WebhookURL
+	case NotifierMSTeams:
+		return this.MSTeams.WebhookURL
+	}
+	return ""
+}
+
+// GetChannel returns channel of the team daily messages, the global one of the notifier by default.
+// Microsoft Teams webhook is bound to a single channel, so there is no channel for it.
+func (this *Config) GetChannel(team Team) string {
+	if len(team.Channel) > 0 {
+		return team.Channel
+	}
+
+	switch this.GetNotifier(team) {
+	case NotifierSlack:
+		return this.Slack.Channel
+	case NotifierMattermost:
+		return this.Mattermost.Channel
+	}
+	return ""
+}
+
+// GetTeams returns configured teams. Config without teams section is a single unnamed team
+// made of duty-command and timelogs-command settings.
+func (this *Config) GetTeams() []Team {
+	if len(this.Teams) > 0 {
+		return this.Teams
+	}
+
+	return []Team{{
+		ScheduleID:       this.DutyCommand.ScheduleID,
+		MinimumTimeSpent: this.TimelogsCommand.MinimumTimeSpent,
+		Members:          this.TimelogsCommand.Team,
+		DutyMessage: DailyMessage{
+			Enable:                 this.DutyCommand.Enable,
+			DailyMessageTimeString: this.DutyCommand.DailyMessageTimeString,
+			Schedule:               this.DutyCommand.Schedule,
+			Timezone:               this.DutyCommand.Timezone,
+			DailyMessageTime:       this.DutyCommand.DailyMessageTime,
+			DailyMessageSchedule:   this.DutyCommand.DailyMessageSchedule,
+		},
+		TimelogsMessage: DailyMessage{
+			Enable:                 this.TimelogsCommand.Enable,
+			DailyMessageTimeString: this.TimelogsCommand.DailyMessageTimeString,
+			Schedule:               this.TimelogsCommand.Schedule,
+			Timezone:               this.TimelogsCommand.Timezone,
+			DailyMessageTime:       this.TimelogsCommand.DailyMessageTime,
+			DailyMessageSchedule:   this.TimelogsCommand.DailyMessageSchedule,
+		},
+		Roster: this.TimelogsCommand.Roster,
+	}}
+}
+
+// WithMembers returns copy of config with members of teams replaced by synced ones
+func (this *Config) WithMembers(members map[string][]User) *Config {
+	cfg := *this
+	if len(cfg.Teams) == 0 {
+		if teamMembers, found := members[""]; found {
+			cfg.TimelogsCommand.Team = teamMembers
+		}
+		return &cfg
+	}
+
+	cfg.Teams = append([]Team(nil), this.Teams...)
+	for i := range cfg.Teams {
+		if teamMembers, found := members[cfg.Teams[i].Name]; found {
+			cfg.Teams[i].Members = teamMembers
+		}
+	}
+	return &cfg
+}
+
+// GetMembers returns members of all teams, a user in several teams is returned once
+func (this *Config) GetMembers() []User {
+	var members []User
+	seen := make(map[User]bool)
+	for _, team := range this.GetTeams() {
+		for _, member := range team.Members {
+			if !seen[member] {
+				seen[member] = true
+				members = append(members, member)
+			}
+		}
+	}
+	return members
+}
+
+// GetOnCallTeam returns team whose duty schedule is synced to on-call user group
+func (this *Config) GetOnCallTeam() Team {
+	teams := this.GetTeams()
+	for _, team := range teams {
+		if team.Name == this.OnCallSync.Team {
+			return team
+		}
+	}
+	return teams[0]
+}
@@ -1,7 +1,21 @@
 package config
 
+import "strings"
+
 type User struct {
-	Name       string `yaml:"name"`
-	JiraLogin  string `yaml:"jira-login"`
-	SlackLogin string `yaml:"slack-login"`
+	Name            string `yaml:"name"`
+	JiraLogin       string `yaml:"jira-login"`
+	SlackLogin      string `yaml:"slack-login"`
+	MattermostLogin string `yaml:"mattermost-login"`
+	Email           string `yaml:"email"`
+	EmailDigest     bool   `yaml:"email-digest"`
+}
+
+// Key identifies user across directories: email if set, slack login otherwise.
+// Users synced from ldap or jira have no slack login.
+func (this *User) Key() string {
+	if len(this.Email) > 0 {
+		return strings.ToLower(this.Email)
+	}
+	return this.SlackLogin
 }
@@ -0,0 +1,476 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"bobby/cron"
+	"bobby/utils"
+)
+
+// ValidationError lists all config problems, each prefixed with its yaml path
+type ValidationError struct {
+	Problems []string
+}
+
+func (this *ValidationError) Error() string {
+	return "invalid config:\n\t" + strings.Join(this.Problems, "\n\t")
+}
+
+type validator struct {
+	problems []string
+}
+
+func (this *validator) add(path, format string, args ...interface{}) {
+	this.problems = append(this.problems, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (this *validator) requireString(path, value string) {
+	if len(value) == 0 {
+		this.add(path, "must be non empty")
+	}
+}
+
+func (this *validator) err() error {
+	if len(this.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: this.problems}
+}
+
+// checkUnknownKeys reports keys of decoded yaml which don't match any field of type
+func checkUnknownKeys(validator *validator, path string, raw interface{}, typ reflect.Type) {
+	switch typ.Kind() {
+	case reflect.Ptr:
+		checkUnknownKeys(validator, path, raw, typ.Elem())
+	case reflect.Slice:
+		items, ok := raw.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			checkUnknownKeys(validator, fmt.Sprintf("%s[%d]", path, i), item, typ.Elem())
+		}
+	case reflect.Struct:
+		values, ok := raw.(map[interface{}]interface{})
+		if !ok {
+			return
+		}
+
+		fields := make(map[string]reflect.Type, typ.NumField())
+		for i := 0; i < typ.NumField(); i++ {
+			name := strings.Split(typ.Field(i).Tag.Get("yaml"), ",")[0]
+			if name != "-" && len(name) > 0 {
+				fields[name] = typ.Field(i).Type
+			}
+		}
+
+		keys := make([]string, 0, len(values))
+		byName := make(map[string]interface{}, len(values))
+		for key, value := range values {
+			keys = append(keys, fmt.Sprint(key))
+			byName[fmt.Sprint(key)] = value
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			keyPath := key
+			if len(path) > 0 {
+				keyPath = path + "." + key
+			}
+
+			fieldType, found := fields[key]
+			if !found {
+				validator.add(keyPath, "unknown key")
+				continue
+			}
+			checkUnknownKeys(validator, keyPath, byName[key], fieldType)
+		}
+	}
+}
+
+// validate checks sections of enabled features only and fills defaults
+func validate(validator *validator, cfg *Config) {
+	if port, err := strconv.Atoi(cfg.Main.Port); err != nil || port <= 0 || port >= 60000 {
+		validator.add("main.port", "must be a positive number less then 60000, got %q", cfg.Main.Port)
+	}
+
+	if cfg.Main.ShutdownTimeout == 0 {
+		cfg.Main.ShutdownTimeout = defaultShutdownTimeout
+	}
+
+	validateNotifier(validator, cfg)
+	validateSlack(validator, cfg)
+	validateDuty(validator, cfg)
+	validateTimelogs(validator, cfg)
+	validateTeams(validator, cfg)
+	validateRoster(validator, cfg)
+
+	if cfg.Email.Enable {
+		validator.requireString("email.host", cfg.Email.Host)
+		validator.requireString("email.port", cfg.Email.Port)
+		validator.requireString("email.from", cfg.Email.From)
+
+		if len(cfg.Email.WeeklySchedule) == 0 {
+			cfg.Email.WeeklySchedule = defaultEmailWeeklySchedule
+		}
+
+		var err error
+		if _, cfg.Email.WeeklyCronSchedule, err = parseSchedule("", cfg.Email.WeeklySchedule, cfg.Email.Timezone); err != nil {
+			validator.add("email.weekly-schedule", "error parse schedule: %s", err.Error())
+		}
+	}
+
+	if cfg.Delivery.Concurrency == 0 {
+		cfg.Delivery.Concurrency = defaultDeliveryConcurrency
+	}
+
+	if cfg.Delivery.MaxAttempts == 0 {
+		cfg.Delivery.MaxAttempts = defaultDeliveryMaxAttempts
+	}
+
+	validateCache(validator, cfg)
+	validateScheduler(validator, cfg)
+
+	if cfg.OnCallSync.Enable {
+		validator.requireString("oncall-sync.usergroup-id", cfg.OnCallSync.UserGroupID)
+
+		if len(cfg.OnCallSync.AlertChannel) == 0 {
+			cfg.OnCallSync.AlertChannel = cfg.Slack.Channel
+		}
+
+		if cfg.OnCallSync.RefreshInterval == 0 {
+			cfg.OnCallSync.RefreshInterval = defaultOnCallRefreshInterval
+		}
+	}
+}
+
+func validateNotifier(validator *validator, cfg *Config) {
+	switch cfg.Notifier {
+	case "":
+		cfg.Notifier = NotifierSlack
+	case NotifierSlack, NotifierMattermost, NotifierMSTeams:
+	default:
+		validator.add("notifier", "unknown notifier %q", cfg.Notifier)
+	}
+
+	for i, team := range cfg.Teams {
+		switch team.Notifier {
+		case "", NotifierSlack, NotifierMattermost, NotifierMSTeams:
+		default:
+			validator.add(fmt.Sprintf("teams[%d].notifier", i), "unknown notifier %q", team.Notifier)
+		}
+	}
+
+	// a team without channel or webhook falls back to the global one, which is reported once
+	reported := make(map[string]bool)
+	require := func(path, value string) {
+		if len(value) == 0 && !reported[path] {
+			reported[path] = true
+			validator.add(path, "must be non empty")
+		}
+	}
+
+	for _, team := range cfg.GetTeams() {
+		if !team.SendsDailyMessages() {
+			continue
+		}
+
+		switch cfg.GetNotifier(team) {
+		case NotifierSlack:
+			require("slack.channel", cfg.GetChannel(team))
+		case NotifierMattermost:
+			require("mattermost.webhook-url", cfg.GetWebhookURL(team))
+		case NotifierMSTeams:
+			require("msteams.webhook-url", cfg.GetWebhookURL(team))
+		}
+	}
+}
+
+func validateSlack(validator *validator, cfg *Config) {
+	switch cfg.Slack.Transport {
+	case "":
+		cfg.Slack.Transport = TransportHTTP
+	case TransportHTTP:
+	case TransportSocketMode:
+		validator.requireString("slack.app-token", cfg.Slack.AppToken)
+	default:
+		validator.add("slack.transport", "unknown slack transport %q", cfg.Slack.Transport)
+	}
+
+	if cfg.UsesSlack() {
+		validator.requireString("slack.token", cfg.Slack.Token)
+	}
+
+	if cfg.Slack.UsersRefreshInterval == 0 {
+		cfg.Slack.UsersRefreshInterval = defaultUsersRefreshInterval
+	}
+}
+
+func validateDuty(validator *validator, cfg *Config) {
+	dutyCommand := &cfg.DutyCommand
+	if cfg.UsesDuty() {
+		validator.requireString("opsgenie.token", cfg.Opsgenie.Token)
+		if len(cfg.Teams) == 0 {
+			validator.requireString("duty-command.schedule-id", dutyCommand.ScheduleID)
+		}
+	}
+
+	if len(dutyCommand.Name) > 0 {
+		validator.requireString("duty-command.token", dutyCommand.Token)
+	}
+
+	validateDailyMessage(validator, "duty-command", dutyCommand.Enable, dutyCommand.DailyMessageTimeString,
+		dutyCommand.Schedule, dutyCommand.Timezone, &dutyCommand.DailyMessageTime, &dutyCommand.DailyMessageSchedule)
+
+	dutyCommand.StaleCacheTTL, dutyCommand.ErrorCacheTTL = defaultCacheTTLs(dutyCommand.CacheTTL,
+		dutyCommand.StaleCacheTTL, dutyCommand.ErrorCacheTTL)
+}
+
+func validateTimelogs(validator *validator, cfg *Config) {
+	timelogsCommand := &cfg.TimelogsCommand
+	if cfg.UsesTimelogs() {
+		validator.requireString("jira.token", cfg.Jira.Token)
+
+		if len(cfg.Teams) == 0 && timelogsCommand.MinimumTimeSpent <= 0 {
+			validator.add("timelogs-command.minimum-time-logged", "must be positive")
+		}
+
+		if len(cfg.Teams) == 0 && len(timelogsCommand.Team) == 0 && !cfg.SyncsRoster(timelogsCommand.Roster) {
+			validator.add("timelogs-command.team", "must be non empty")
+		}
+	}
+
+	if len(timelogsCommand.Name) > 0 {
+		validator.requireString("timelogs-command.token", timelogsCommand.Token)
+	}
+
+	for i, user := range timelogsCommand.Team {
+		validator.requireString(fmt.Sprintf("timelogs-command.team[%d].name", i), user.Name)
+	}
+
+	validateDailyMessage(validator, "timelogs-command", timelogsCommand.Enable, timelogsCommand.DailyMessageTimeString,
+		timelogsCommand.Schedule, timelogsCommand.Timezone, &timelogsCommand.DailyMessageTime, &timelogsCommand.DailyMessageSchedule)
+
+	timelogsCommand.StaleCacheTTL, timelogsCommand.ErrorCacheTTL = defaultCacheTTLs(timelogsCommand.CacheTTL,
+		timelogsCommand.StaleCacheTTL, timelogsCommand.ErrorCacheTTL)
+}
+
+// validateTeams checks teams section. Team settings can't be mixed with the single team ones
+// in duty-command and timelogs-command.
+func validateTeams(validator *validator, cfg *Config) {
+	names := make(map[string]bool, len(cfg.Teams))
+	for _, team := range cfg.GetTeams() {
+		names[team.Name] = true
+	}
+
+	if cfg.OnCallSync.Enable && len(cfg.OnCallSync.Team) > 0 && !names[cfg.OnCallSync.Team] {
+		validator.add("oncall-sync.team", "unknown team %q", cfg.OnCallSync.Team)
+	}
+
+	if len(cfg.Teams) == 0 {
+		return
+	}
+
+	singleTeamSettings := []struct {
+		path string
+		set  bool
+	}{
+		{"duty-command.enable", cfg.DutyCommand.Enable},
+		{"duty-command.schedule-id", len(cfg.DutyCommand.ScheduleID) > 0},
+		{"duty-command.daily-message-time", len(cfg.DutyCommand.DailyMessageTimeString) > 0},
+		{"duty-command.schedule", len(cfg.DutyCommand.Schedule) > 0},
+		{"timelogs-command.enable", cfg.TimelogsCommand.Enable},
+		{"timelogs-command.team", len(cfg.TimelogsCommand.Team) > 0},
+		{"timelogs-command.minimum-time-logged", cfg.TimelogsCommand.MinimumTimeSpent != 0},
+		{"timelogs-command.daily-message-time", len(cfg.TimelogsCommand.DailyMessageTimeString) > 0},
+		{"timelogs-command.schedule", len(cfg.TimelogsCommand.Schedule) > 0},
+	}
+	for _, setting := range singleTeamSettings {
+		if setting.set {
+			validator.add(setting.path, "must be set per team when teams are configured")
+		}
+	}
+
+	names = make(map[string]bool, len(cfg.Teams))
+	for i := range cfg.Teams {
+		team := &cfg.Teams[i]
+		path := fmt.Sprintf("teams[%d]", i)
+
+		switch {
+		case len(team.Name) == 0:
+			validator.add(path+".name", "must be non empty")
+		case strings.ContainsAny(team.Name, " \t\"'"):
+			validator.add(path+".name", "must be a single word, got %q", team.Name)
+		case names[team.Name]:
+			validator.add(path+".name", "duplicate team %q", team.Name)
+		}
+		names[team.Name] = true
+
+		onCall := cfg.OnCallSync.Enable && cfg.GetOnCallTeam().Name == team.Name
+		if len(cfg.DutyCommand.Name) > 0 || team.DutyMessage.Enable || onCall {
+			validator.requireString(path+".schedule-id", team.ScheduleID)
+		}
+
+		if len(cfg.TimelogsCommand.Name) > 0 || team.TimelogsMessage.Enable {
+			if team.MinimumTimeSpent <= 0 {
+				validator.add(path+".minimum-time-logged", "must be positive")
+			}
+
+			if len(team.Members) == 0 && !cfg.SyncsRoster(team.Roster) {
+				validator.add(path+".members", "must be non empty")
+			}
+		}
+
+		for j, user := range team.Members {
+			validator.requireString(fmt.Sprintf("%s.members[%d].name", path, j), user.Name)
+		}
+
+		for _, message := range []struct {
+			path    string
+			message *DailyMessage
+		}{
+			{path + ".duty-message", &team.DutyMessage},
+			{path + ".timelogs-message", &team.TimelogsMessage},
+		} {
+			validateDailyMessage(validator, message.path, message.message.Enable, message.message.DailyMessageTimeString,
+				message.message.Schedule, message.message.Timezone, &message.message.DailyMessageTime,
+				&message.message.DailyMessageSchedule)
+		}
+	}
+}
+
+func validateRoster(validator *validator, cfg *Config) {
+	if cfg.RosterSync.RefreshInterval == 0 {
+		cfg.RosterSync.RefreshInterval = defaultRosterRefreshInterval
+	}
+
+	if !cfg.RosterSync.Enable {
+		return
+	}
+
+	synced, usesJira, usesLDAP := false, false, false
+	for _, team := range cfg.GetTeams() {
+		synced = synced || !team.Roster.IsEmpty()
+		usesJira = usesJira || len(team.Roster.JiraGroup) > 0
+		usesLDAP = usesLDAP || len(team.Roster.LDAPGroup) > 0
+	}
+
+	if !synced {
+		validator.add("roster-sync.enable", "no team has roster groups")
+	}
+
+	if usesJira {
+		validator.requireString("jira.token", cfg.Jira.Token)
+	}
+
+	if usesLDAP {
+		validator.requireString("ldap.url", cfg.LDAP.URL)
+		validator.requireString("ldap.base-dn", cfg.LDAP.BaseDN)
+	}
+
+	if len(cfg.LDAP.MemberFilter) == 0 {
+		cfg.LDAP.MemberFilter = defaultLDAPMemberFilter
+	} else if strings.Count(cfg.LDAP.MemberFilter, "%s") != 1 {
+		validator.add("ldap.member-filter", "must contain single %%s for group dn, got %q", cfg.LDAP.MemberFilter)
+	}
+
+	if len(cfg.LDAP.NameAttribute) == 0 {
+		cfg.LDAP.NameAttribute = defaultLDAPNameAttribute
+	}
+
+	if len(cfg.LDAP.EmailAttribute) == 0 {
+		cfg.LDAP.EmailAttribute = defaultLDAPEmailAttribute
+	}
+}
+
+// validateDailyMessage parses daily message schedule. The schedule is required only for enabled
+// daily message, but it is checked whenever it is set.
+func validateDailyMessage(validator *validator, path string, enable bool, dailyMessageTime, schedule, timezone string,
+	dayTime *utils.DayTime, cronSchedule **cron.Schedule) {
+	if !enable && len(dailyMessageTime) == 0 && len(schedule) == 0 {
+		return
+	}
+
+	var err error
+	if *dayTime, *cronSchedule, err = parseSchedule(dailyMessageTime, schedule, timezone); err != nil {
+		validator.add(path, "error parse daily message schedule: %s", err.Error())
+	}
+}
+
+func validateCache(validator *validator, cfg *Config) {
+	switch cfg.Cache.Backend {
+	case "":
+		cfg.Cache.Backend = CacheBackendMemory
+	case CacheBackendMemory:
+	case CacheBackendFile:
+		if len(cfg.Cache.File) == 0 {
+			cfg.Cache.File = defaultCacheFile
+		}
+	default:
+		validator.add("cache.backend", "unknown cache backend %q", cfg.Cache.Backend)
+	}
+
+	if cfg.Cache.PrewarmLead == 0 {
+		cfg.Cache.PrewarmLead = defaultCachePrewarmLead
+	}
+
+	if cfg.Cache.MaxEntries == 0 {
+		cfg.Cache.MaxEntries = defaultCacheMaxEntries
+	}
+
+	if cfg.Cache.MaxBytes == 0 {
+		cfg.Cache.MaxBytes = defaultCacheMaxBytes
+	}
+
+	if cfg.Cache.SweepInterval == 0 {
+		cfg.Cache.SweepInterval = defaultCacheSweepInterval
+	}
+}
+
+func validateScheduler(validator *validator, cfg *Config) {
+	if cfg.Scheduler.CatchUpGrace == 0 {
+		cfg.Scheduler.CatchUpGrace = defaultSchedulerCatchUpGrace
+	}
+
+	switch cfg.Scheduler.LeaderElection {
+	case "":
+		cfg.Scheduler.LeaderElection = LeaderElectionNone
+	case LeaderElectionNone:
+	case LeaderElectionFile:
+		if len(cfg.Scheduler.LockFile) == 0 {
+			cfg.Scheduler.LockFile = defaultSchedulerLockFile
+		}
+	case LeaderElectionStore:
+		if len(cfg.Scheduler.LeaseFile) == 0 {
+			cfg.Scheduler.LeaseFile = defaultSchedulerLeaseFile
+		}
+		// the cache keeps its file open, so other instances could never take the lease
+		if cfg.Cache.Backend == CacheBackendFile && cfg.Scheduler.LeaseFile == cfg.Cache.File {
+			validator.add("scheduler.lease-file", "must differ from cache.file")
+		}
+	default:
+		validator.add("scheduler.leader-election", "unknown leader election %q", cfg.Scheduler.LeaderElection)
+	}
+
+	if cfg.Scheduler.LockInterval == 0 {
+		cfg.Scheduler.LockInterval = defaultSchedulerLockInterval
+	}
+
+	if cfg.Scheduler.LeaseTTL == 0 {
+		cfg.Scheduler.LeaseTTL = defaultSchedulerLeaseTTL
+	}
+
+	if len(cfg.Scheduler.InstanceID) == 0 {
+		hostname, err := os.Hostname()
+		if err != nil {
+			validator.add("scheduler.instance-id", "error get hostname: %s", err.Error())
+			return
+		}
+		cfg.Scheduler.InstanceID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+}
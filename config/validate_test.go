@@ -0,0 +1,307 @@
+package config
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type ValidateTestSuite struct{}
+
+var _ = Suite(&ValidateTestSuite{})
+
+func (suite *ValidateTestSuite) TestExampleConfig(c *C) {
+	_, err := ParseConfig("../example_config.yaml")
+	c.Assert(err, IsNil)
+}
+
+func (suite *ValidateTestSuite) TestDisabledFeatures(c *C) {
+	// duty command only: neither jira nor timelogs settings are needed
+	cfg, err := parseConfig([]byte(`
+main:
+  port: 8080
+opsgenie:
+  token: opsgenie-secret
+duty-command:
+  name: duty
+  token: duty-secret
+  schedule-id: schedule
+`))
+	c.Assert(err, IsNil)
+	c.Assert(cfg.DutyCommand.DailyMessageSchedule, IsNil)
+	c.Assert(cfg.UsesSlack(), Equals, false)
+}
+
+func (suite *ValidateTestSuite) TestProblems(c *C) {
+	_, err := parseConfig([]byte(`
+main:
+  port: http
+slack:
+  token: xoxb-secret
+duty-command:
+  enable: true
+  name: duty
+  schedule-ids:
+  - schedule
+  daily-message-time: "9:4"
+timelogs-command:
+  name: timelogs
+  minimum-time-logged: 6h
+  team:
+  - jira-login: johndoe
+    slack: john.doe
+cache:
+  backend: redis
+`))
+	c.Assert(err, FitsTypeOf, &ValidationError{})
+	c.Assert(err.(*ValidationError).Problems, DeepEquals, []string{
+		"duty-command.schedule-ids: unknown key",
+		"timelogs-command.team[0].slack: unknown key",
+		"main.port: must be a positive number less then 60000, got \"http\"",
+		"slack.channel: must be non empty",
+		"opsgenie.token: must be non empty",
+		"duty-command.schedule-id: must be non empty",
+		"duty-command.token: must be non empty",
+		"duty-command: error parse daily message schedule: time format error: \"9:4\" must be HH:MM",
+		"jira.token: must be non empty",
+		"timelogs-command.token: must be non empty",
+		"timelogs-command.team[0].name: must be non empty",
+		"cache.backend: unknown cache backend \"redis\"",
+	})
+}
+
+func (suite *ValidateTestSuite) TestTeams(c *C) {
+	cfg, err := parseConfig([]byte(`
+main:
+  port: 8080
+slack:
+  token: xoxb-secret
+jira:
+  token: jira-secret
+opsgenie:
+  token: opsgenie-secret
+duty-command:
+  name: duty
+  token: duty-secret
+timelogs-command:
+  name: timelogs
+  token: timelogs-secret
+teams:
+- name: team-x
+  channel: "#team-x"
+  schedule-id: schedule-x
+  minimum-time-logged: 6h
+  members:
+  - name: John Doe
+  - name: Jane Roe
+  duty-message:
+    enable: true
+    time: "09:47"
+- name: team-y
+  channel: "#team-y"
+  schedule-id: schedule-y
+  minimum-time-logged: 4h
+  members:
+  - name: John Doe
+  timelogs-message:
+    enable: true
+    schedule: "0 10 * * MON"
+    timezone: Europe/Berlin
+`))
+	c.Assert(err, IsNil)
+	c.Assert(cfg.GetTeams(), HasLen, 2)
+	c.Assert(cfg.Teams[0].DutyMessage.DailyMessageSchedule, NotNil)
+	c.Assert(cfg.Teams[1].TimelogsMessage.DailyMessageSchedule, NotNil)
+	c.Assert(cfg.GetMembers(), HasLen, 2)
+	c.Assert(cfg.SendsDailyMessages(), Equals, true)
+	c.Assert(cfg.Teams[1].Title("Time logs"), Equals, "Time logs (team-y):")
+}
+
+func (suite *ValidateTestSuite) TestTeamsProblems(c *C) {
+	_, err := parseConfig([]byte(`
+main:
+  port: 8080
+opsgenie:
+  token: opsgenie-secret
+duty-command:
+  name: duty
+  token: duty-secret
+  schedule-id: schedule
+oncall-sync:
+  enable: true
+  team: team-z
+  usergroup-id: S1
+teams:
+- name: team x
+  schedule-id: schedule-x
+- name: team-y
+  duty-message:
+    enable: true
+    time: "09:47"
+- name: team-y
+  schedule-id: schedule-y
+`))
+	c.Assert(err, FitsTypeOf, &ValidationError{})
+	c.Assert(err.(*ValidationError).Problems, DeepEquals, []string{
+		"slack.channel: must be non empty",
+		"slack.token: must be non empty",
+		"oncall-sync.team: unknown team \"team-z\"",
+		"duty-command.schedule-id: must be set per team when teams are configured",
+		"teams[0].name: must be a single word, got \"team x\"",
+		"teams[1].schedule-id: must be non empty",
+		"teams[2].name: duplicate team \"team-y\"",
+	})
+}
+
+func (suite *ValidateTestSuite) TestTeamsNotifier(c *C) {
+	cfg, err := parseConfig([]byte(`
+main:
+  port: 8080
+opsgenie:
+  token: opsgenie-secret
+notifier: mattermost
+mattermost:
+  webhook-url: https://mattermost.example.com/hooks/global
+  channel: town-square
+teams:
+- name: team-x
+  schedule-id: schedule-x
+  duty-message:
+    enable: true
+    time: "09:47"
+- name: team-y
+  schedule-id: schedule-y
+  notifier: msteams
+  webhook-url: https://example.webhook.office.com/team-y
+  duty-message:
+    enable: true
+    time: "09:47"
+`))
+	c.Assert(err, IsNil)
+	c.Assert(cfg.UsesSlack(), Equals, false)
+	c.Assert(cfg.GetNotifier(cfg.Teams[0]), Equals, NotifierMattermost)
+	c.Assert(cfg.GetWebhookURL(cfg.Teams[0]), Equals, "https://mattermost.example.com/hooks/global")
+	c.Assert(cfg.GetChannel(cfg.Teams[0]), Equals, "town-square")
+	c.Assert(cfg.GetNotifier(cfg.Teams[1]), Equals, NotifierMSTeams)
+	c.Assert(cfg.GetWebhookURL(cfg.Teams[1]), Equals, "https://example.webhook.office.com/team-y")
+}
+
+func (suite *ValidateTestSuite) TestTeamsNotifierProblems(c *C) {
+	_, err := parseConfig([]byte(`
+main:
+  port: 8080
+slack:
+  token: xoxb-secret
+opsgenie:
+  token: opsgenie-secret
+teams:
+- name: team-x
+  schedule-id: schedule-x
+  notifier: telegram
+- name: team-y
+  schedule-id: schedule-y
+  notifier: msteams
+  duty-message:
+    enable: true
+    time: "09:47"
+- name: team-z
+  schedule-id: schedule-z
+  notifier: mattermost
+  webhook-url: https://mattermost.example.com/hooks/team-z
+  duty-message:
+    enable: true
+    time: "09:47"
+`))
+	c.Assert(err, FitsTypeOf, &ValidationError{})
+	c.Assert(err.(*ValidationError).Problems, DeepEquals, []string{
+		"teams[0].notifier: unknown notifier \"telegram\"",
+		"msteams.webhook-url: must be non empty",
+	})
+}
+
+func (suite *ValidateTestSuite) TestRosterSync(c *C) {
+	cfg, err := parseConfig([]byte(`
+main:
+  port: 8080
+slack:
+  token: xoxb-secret
+jira:
+  token: jira-secret
+timelogs-command:
+  name: timelogs
+  token: timelogs-secret
+  minimum-time-logged: 6h
+  roster:
+    jira-group: team-x
+    slack-usergroup-id: S1
+roster-sync:
+  enable: true
+`))
+	c.Assert(err, IsNil)
+	c.Assert(cfg.RosterSync.RefreshInterval, Equals, time.Hour)
+	c.Assert(cfg.GetTeams()[0].Roster.JiraGroup, Equals, "team-x")
+	c.Assert(cfg.UsesSlack(), Equals, true)
+
+	synced := cfg.WithMembers(map[string][]User{"": {{Name: "John Doe"}}})
+	c.Assert(synced.GetMembers(), DeepEquals, []User{{Name: "John Doe"}})
+	c.Assert(cfg.GetMembers(), HasLen, 0)
+
+	_, err = parseConfig([]byte(`
+main:
+  port: 8080
+roster-sync:
+  enable: true
+teams:
+- name: team-x
+  roster:
+    ldap-group: cn=team-x,ou=groups,dc=example,dc=com
+ldap:
+  member-filter: (memberOf=*)
+`))
+	c.Assert(err, FitsTypeOf, &ValidationError{})
+	c.Assert(err.(*ValidationError).Problems, DeepEquals, []string{
+		"ldap.url: must be non empty",
+		"ldap.base-dn: must be non empty",
+		"ldap.member-filter: must contain single %s for group dn, got \"(memberOf=*)\"",
+	})
+}
+
+func (suite *ValidateTestSuite) TestSchedulerStoreLeaderElection(c *C) {
+	cfg, err := parseConfig([]byte(`
+main:
+  port: 8080
+opsgenie:
+  token: opsgenie-secret
+duty-command:
+  name: duty
+  token: duty-secret
+  schedule-id: schedule
+scheduler:
+  leader-election: store
+  instance-id: bobby-1
+`))
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Scheduler.LeaseFile, Equals, "bobby_lease.db")
+	c.Assert(cfg.Scheduler.LeaseTTL, Equals, 30*time.Second)
+	c.Assert(cfg.Scheduler.InstanceID, Equals, "bobby-1")
+
+	_, err = parseConfig([]byte(`
+main:
+  port: 8080
+opsgenie:
+  token: opsgenie-secret
+duty-command:
+  name: duty
+  token: duty-secret
+  schedule-id: schedule
+cache:
+  backend: file
+scheduler:
+  leader-election: store
+  lease-file: bobby_cache.db
+`))
+	c.Assert(err, FitsTypeOf, &ValidationError{})
+	c.Assert(err.(*ValidationError).Problems, DeepEquals, []string{
+		"scheduler.lease-file: must differ from cache.file",
+	})
+}
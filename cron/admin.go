@@ -0,0 +1,203 @@
+package cron
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	JobNotFoundError        = fmt.Errorf("job not found")
+	DryRunNotSupportedError = fmt.Errorf("job doesn't support dry run")
+	NotLeaderError          = fmt.Errorf("this instance isn't the leader")
+	JobRunningError         = fmt.Errorf("job is already running")
+)
+
+// IDryRunJob is a job which can render what it would do without side effects
+type IDryRunJob interface {
+	DryRun(now time.Time) (interface{}, error)
+}
+
+// JobInfo describes registered job for admin api
+type JobInfo struct {
+	Name        string    `json:"name"`
+	Schedule    string    `json:"schedule"`
+	Next        time.Time `json:"next"`
+	LastRun     *JobRun   `json:"last_run,omitempty"`
+	LastSuccess *JobRun   `json:"last_success,omitempty"`
+	DryRun      bool      `json:"dry_run"`
+}
+
+// Jobs lists registered jobs by name
+func (this *Cron) Jobs() ([]JobInfo, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	jobs := make([]JobInfo, 0, len(this.jobs))
+	for _, item := range this.jobs {
+		history, err := this.history.Get(item.name)
+		if err != nil {
+			return nil, fmt.Errorf("error get %q history: %s", item.name, err)
+		}
+
+		_, dryRun := item.job.(IDryRunJob)
+		jobs = append(jobs, JobInfo{
+			Name:        item.name,
+			Schedule:    fmt.Sprint(item.checker),
+			Next:        item.next,
+			LastRun:     history.LastRun,
+			LastSuccess: history.LastSuccess,
+			DryRun:      dryRun,
+		})
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Name < jobs[j].Name
+	})
+	return jobs, nil
+}
+
+// RunNow runs job immediately and waits for it to finish. The run is recorded in history as manual,
+// so a scheduled time it replaces isn't caught up later. Like scheduled runs it only runs on the leader,
+// is skipped if its start can't be recorded and doesn't start while the job is running.
+func (this *Cron) RunNow(name string) error {
+	this.lock.Lock()
+	item, found := this.jobs[name]
+	history := this.history
+	leader := this.leader
+	this.lock.Unlock()
+
+	if !found {
+		return JobNotFoundError
+	}
+
+	if leader != nil && !leader.IsLeader() {
+		return NotLeaderError
+	}
+
+	this.lock.Lock()
+	running := item.running
+	item.running = true
+	this.lock.Unlock()
+
+	if running {
+		return JobRunningError
+	}
+	defer this.finish(item)
+
+	now := this.wallNow()
+	run := JobRun{
+		Scheduled: now,
+		StartedAt: now,
+		Manual:    true,
+	}
+	if err := history.Record(name, run); err != nil {
+		log.Printf("cron: error record %q start, skip run: %s", name, err)
+		return fmt.Errorf("error record start: %s", err)
+	}
+
+	this.running.Add(1)
+	defer this.running.Done()
+	return this.execute(history, item, run)
+}
+
+// DryRun renders job output as if it ran now without sending anything. Nothing is recorded in history.
+func (this *Cron) DryRun(name string) (interface{}, error) {
+	this.lock.Lock()
+	item, found := this.jobs[name]
+	this.lock.Unlock()
+
+	if !found {
+		return nil, JobNotFoundError
+	}
+
+	job, ok := item.job.(IDryRunJob)
+	if !ok {
+		return nil, DryRunNotSupportedError
+	}
+	return job.DryRun(inLocation(item.checker, this.wallNow()))
+}
+
+// ServeHTTP lists jobs on GET /admin/jobs and runs job on POST /admin/jobs/{name}/run.
+// Query parameter dry-run=true renders job output without sending it.
+func (this *Cron) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/jobs"), "/")
+	if len(path) == 0 {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		jobs, err := this.Jobs()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, jobs)
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 || parts[1] != "run" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := parts[0]
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry-run"))
+	if dryRun {
+		result, err := this.DryRun(name)
+		if err != nil {
+			writeJSON(w, errorStatus(err), struct {
+				Error string `json:"error"`
+			}{err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, struct {
+			Result interface{} `json:"result"`
+		}{result})
+		return
+	}
+
+	if err := this.RunNow(name); err != nil {
+		writeJSON(w, errorStatus(err), struct {
+			Error string `json:"error"`
+		}{err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		OK bool `json:"ok"`
+	}{true})
+}
+
+func errorStatus(err error) int {
+	switch err {
+	case JobNotFoundError:
+		return http.StatusNotFound
+	case DryRunNotSupportedError:
+		return http.StatusBadRequest
+	case NotLeaderError, JobRunningError:
+		return http.StatusConflict
+	}
+	return http.StatusBadGateway
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("cron: error write response: %s", err)
+	}
+}
@@ -0,0 +1,145 @@
+package cron_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"bobby/cron"
+
+	. "gopkg.in/check.v1"
+)
+
+// previewJob is a job with dry run support
+type previewJob struct {
+	recordingJob
+}
+
+func (this *previewJob) DryRun(now time.Time) (interface{}, error) {
+	return []string{"on duty: John Doe"}, nil
+}
+
+func serve(scheduler *cron.Cron, method, url string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	scheduler.ServeHTTP(recorder, httptest.NewRequest(method, url, nil))
+	return recorder
+}
+
+func (suite *CronTestSuite) TestAdminJobs(c *C) {
+	suite.now = time.Date(2020, 3, 2, 9, 48, 0, 0, time.UTC)
+	job := &previewJob{recordingJob{runs: make(chan time.Time, 10)}}
+	scheduler, history := suite.newCron(c, job)
+	scheduler.AddJob("warmer", cron.Before(suite.schedule, 5*time.Minute), &recordingJob{runs: make(chan time.Time, 10)})
+
+	recorder := serve(scheduler, http.MethodGet, "/admin/jobs")
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	var jobs []cron.JobInfo
+	c.Assert(json.NewDecoder(recorder.Body).Decode(&jobs), IsNil)
+	c.Assert(jobs, HasLen, 2)
+	c.Assert(jobs[0].Name, Equals, "duty")
+	c.Assert(jobs[0].Schedule, Equals, "47 9 * * MON-FRI UTC")
+	c.Assert(jobs[0].Next, Equals, time.Date(2020, 3, 3, 9, 47, 0, 0, time.UTC))
+	c.Assert(jobs[0].LastRun, IsNil)
+	c.Assert(jobs[0].DryRun, Equals, true)
+	c.Assert(jobs[1].Schedule, Equals, "5m0s before 47 9 * * MON-FRI UTC")
+	c.Assert(jobs[1].DryRun, Equals, false)
+
+	recorder = serve(scheduler, http.MethodPost, "/admin/jobs/duty/run?dry-run=true")
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), Equals, "{\"result\":[\"on duty: John Doe\"]}\n")
+	c.Assert(job.runs, HasLen, 0)
+
+	recorder = serve(scheduler, http.MethodPost, "/admin/jobs/warmer/run?dry-run=true")
+	c.Assert(recorder.Code, Equals, http.StatusBadRequest)
+
+	recorder = serve(scheduler, http.MethodPost, "/admin/jobs/duty/run")
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(<-job.runs, Equals, suite.now)
+
+	jobHistory, err := history.Get("duty")
+	c.Assert(err, IsNil)
+	c.Assert(jobHistory.LastSuccess.Manual, Equals, true)
+
+	// manual run replaces the missed scheduled one
+	scheduler.CatchUp(time.Date(2020, 3, 2, 9, 50, 0, 0, time.UTC))
+	c.Assert(job.runs, HasLen, 0)
+
+	job.err = fmt.Errorf("slack is down")
+	recorder = serve(scheduler, http.MethodPost, "/admin/jobs/duty/run")
+	c.Assert(recorder.Code, Equals, http.StatusBadGateway)
+	c.Assert(recorder.Body.String(), Equals, "{\"error\":\"slack is down\"}\n")
+	<-job.runs
+
+	c.Assert(serve(scheduler, http.MethodPost, "/admin/jobs/unknown/run").Code, Equals, http.StatusNotFound)
+	c.Assert(serve(scheduler, http.MethodGet, "/admin/jobs/duty/run").Code, Equals, http.StatusMethodNotAllowed)
+	c.Assert(serve(scheduler, http.MethodGet, "/admin/jobs/duty").Code, Equals, http.StatusNotFound)
+}
+
+func (suite *CronTestSuite) TestAdminRunOnLeaderOnly(c *C) {
+	job := &recordingJob{runs: make(chan time.Time, 10)}
+	scheduler, _ := suite.newCron(c, job)
+	leader := &fakeLeader{}
+	scheduler.SetLeader(leader)
+
+	recorder := serve(scheduler, http.MethodPost, "/admin/jobs/duty/run")
+	c.Assert(recorder.Code, Equals, http.StatusConflict)
+	c.Assert(recorder.Body.String(), Equals, "{\"error\":\"this instance isn't the leader\"}\n")
+	c.Assert(job.runs, HasLen, 0)
+
+	leader.leader = true
+	recorder = serve(scheduler, http.MethodPost, "/admin/jobs/duty/run")
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(<-job.runs, Equals, suite.now)
+}
+
+// blockingJob runs until released
+type blockingJob struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (this *blockingJob) Run(now time.Time) error {
+	this.started <- struct{}{}
+	<-this.release
+	return nil
+}
+
+func (suite *CronTestSuite) TestAdminRunWhileRunning(c *C) {
+	job := &blockingJob{started: make(chan struct{}, 10), release: make(chan struct{})}
+	scheduler, history := suite.newCron(c, job)
+
+	// scheduled run is in progress
+	scheduler.CatchUp(time.Date(2020, 3, 2, 10, 0, 0, 0, time.UTC))
+	<-job.started
+
+	recorder := serve(scheduler, http.MethodPost, "/admin/jobs/duty/run")
+	c.Assert(recorder.Code, Equals, http.StatusConflict)
+	c.Assert(recorder.Body.String(), Equals, "{\"error\":\"job is already running\"}\n")
+
+	// the next slot doesn't start while the previous run is in progress
+	scheduler.CatchUp(time.Date(2020, 3, 3, 10, 0, 0, 0, time.UTC))
+	jobHistory, err := history.Get("duty")
+	c.Assert(err, IsNil)
+	c.Assert(jobHistory.LastRun.Scheduled, Equals, time.Date(2020, 3, 2, 9, 47, 0, 0, time.UTC))
+
+	close(job.release)
+	c.Assert(scheduler.Wait(time.Second), Equals, true)
+
+	recorder = serve(scheduler, http.MethodPost, "/admin/jobs/duty/run")
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(job.started, HasLen, 1)
+}
+
+func (suite *CronTestSuite) TestAdminSkipRunIfStartNotRecorded(c *C) {
+	job := &recordingJob{runs: make(chan time.Time, 10)}
+	scheduler := cron.NewCron()
+	scheduler.SetHistory(&failingHistory{}, time.Hour)
+	scheduler.AddJob("duty", suite.schedule, job)
+
+	recorder := serve(scheduler, http.MethodPost, "/admin/jobs/duty/run")
+	c.Assert(recorder.Code, Equals, http.StatusBadGateway)
+	c.Assert(recorder.Body.String(), Equals, "{\"error\":\"error record start: disk is full\"}\n")
+	c.Assert(job.runs, HasLen, 0)
+}
@@ -1,31 +1,46 @@
 package cron
 
 import (
+	"fmt"
 	"time"
 
 	"bobby/utils"
 )
 
-type everyWorkingDayAtTimeChecker struct {
-	dayTime utils.DayTime
-}
-
+// EveryWorkingDayAt fires Monday to Friday at day time in local time zone
 func EveryWorkingDayAt(dayTime utils.DayTime) IChecker {
-	return &everyWorkingDayAtTimeChecker{
-		dayTime: dayTime,
+	return &Schedule{
+		expression: fmt.Sprintf("%d %d * * MON-FRI", dayTime.Minute, dayTime.Hour),
+		location:   time.Local,
+		minutes:    1 << uint(dayTime.Minute),
+		hours:      1 << uint(dayTime.Hour),
+		months:     rangeBits(monthBounds.min, monthBounds.max),
+		anyDay:     true,
+		weekdays:   rangeBits(int(time.Monday), int(time.Friday)),
 	}
 }
 
-func (this *everyWorkingDayAtTimeChecker) Check(now time.Time) bool {
-	switch now.Weekday() {
-	case time.Sunday, time.Saturday:
-		return false
+type beforeChecker struct {
+	checker IChecker
+	lead    time.Duration
+}
+
+// Before fires lead time ahead of checker
+func Before(checker IChecker, lead time.Duration) IChecker {
+	return &beforeChecker{
+		checker: checker,
+		lead:    lead,
 	}
+}
 
-	hour, minute, _ := now.Clock()
-	if hour != this.dayTime.Hour || minute != this.dayTime.Minute {
-		return false
+func (this *beforeChecker) Next(after time.Time) time.Time {
+	next := this.checker.Next(after.Add(this.lead))
+	if next.IsZero() {
+		return next
 	}
+	return next.Add(-this.lead)
+}
 
-	return true
+func (this *beforeChecker) String() string {
+	return fmt.Sprintf("%s before %s", this.lead, this.checker)
 }
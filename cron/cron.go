@@ -1,84 +1,450 @@
 package cron
 
 import (
+	"container/heap"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
 	"time"
+
+	"bobby/metrics"
 )
 
 const (
-	defaultScheduleInterval time.Duration = 1 * time.Second
+	// maxSleep bounds timer duration, so wall clock jumps are noticed in time
+	maxSleep = time.Minute
+
+	// maxTickAge is how long Run loop may not tick before it is considered stuck
+	maxTickAge = 3 * maxSleep
+
+	// maxAttempts bounds how many times a failed or crashed slot is caught up
+	maxAttempts = 3
 )
 
 type ICronJob interface {
-	Run(now time.Time)
+	Run(now time.Time) error
 }
 
+// IChecker computes job fire times
 type IChecker interface {
-	Check(now time.Time) bool
+	// Next returns the first fire time after the given time, zero time if there is none
+	Next(after time.Time) time.Time
 }
 
-type cronItem struct {
-	job          ICronJob
-	checker      IChecker
-	lastExecTime time.Time
+// ILocatedChecker is a checker evaluated in its own time zone,
+// jobs get time in that zone
+type ILocatedChecker interface {
+	Location() *time.Location
 }
 
-func (this cronItem) checkLastExecTime(now time.Time) bool {
-	return this.lastExecTime.Add(24 * time.Hour).Before(now)
+func inLocation(checker IChecker, now time.Time) time.Time {
+	if located, ok := checker.(ILocatedChecker); ok {
+		return now.In(located.Location())
+	}
+	return now
 }
 
+// ILeader reports whether this instance runs scheduled jobs
+type ILeader interface {
+	IsLeader() bool
+}
+
+type cronItem struct {
+	name    string
+	job     ICronJob
+	checker IChecker
+	next    time.Time
+	index   int
+	// running is set while a scheduled or manual run is in progress, guarded by Cron lock
+	running bool
+}
+
+// Cron runs jobs at fire times computed by their checkers. Every run is recorded in history
+// by its scheduled time, so a succeeded scheduled time never runs twice, even after restart. Runs missed
+// while the bot was down, failed or crashed are started by Run if they are not older than catch up grace,
+// a scheduled time is tried at most maxAttempts times.
 type Cron struct {
-	scheduleInterval time.Duration
-	jobs             []cronItem
-	stop             chan struct{}
+	now func() time.Time
+
+	lock         sync.Mutex
+	history      IHistory
+	catchUpGrace time.Duration
+	leader       ILeader
+	jobs         map[string]*cronItem
+	queue        cronQueue
+	tickedAt     time.Time
+
+	wakeup  chan struct{}
+	stop    chan struct{}
+	stopped sync.Once
+	running sync.WaitGroup
 }
 
-func NewCron(scheduleInterval time.Duration) *Cron {
+func NewCron() *Cron {
 	return &Cron{
-		scheduleInterval: scheduleInterval,
-		stop:             make(chan struct{}),
+		now:     time.Now,
+		history: newMemoryHistory(),
+		jobs:    make(map[string]*cronItem),
+		wakeup:  make(chan struct{}, 1),
+		stop:    make(chan struct{}),
 	}
 }
 
-func (this *Cron) AddJob(checker IChecker, job ICronJob) {
-	this.jobs = append(this.jobs, cronItem{
+// SetHistory sets job run history storage and how old missed runs are caught up by Run
+func (this *Cron) SetHistory(history IHistory, catchUpGrace time.Duration) {
+	this.lock.Lock()
+	this.history = history
+	this.catchUpGrace = catchUpGrace
+	this.lock.Unlock()
+}
+
+// SetLeader makes scheduled runs start only while leader reports this instance is the leader.
+// Jobs still get rescheduled on other instances, so they are ready to take over.
+func (this *Cron) SetLeader(leader ILeader) {
+	this.lock.Lock()
+	this.leader = leader
+	this.lock.Unlock()
+}
+
+// TakeOver reloads history recorded by the previous leader and catches up runs it missed.
+// It must be called when this instance becomes the leader.
+func (this *Cron) TakeOver() {
+	this.lock.Lock()
+	history := this.history
+	this.lock.Unlock()
+
+	if reloader, ok := history.(IReloader); ok {
+		if err := reloader.Reload(); err != nil {
+			log.Printf("cron: error reload history: %s", err)
+		}
+	}
+	this.catchUp(this.wallNow())
+}
+
+// AddJob adds job or replaces job with the same name. The name identifies job in history.
+func (this *Cron) AddJob(name string, checker IChecker, job ICronJob) {
+	this.lock.Lock()
+	if item, found := this.jobs[name]; found {
+		this.unschedule(item)
+	}
+
+	item := &cronItem{
+		name:    name,
 		job:     job,
 		checker: checker,
-	})
+		index:   -1,
+	}
+	this.jobs[name] = item
+	this.schedule(item, this.wallNow())
+	this.lock.Unlock()
+
+	this.notify()
 }
 
-func (this *Cron) Run() {
-	if len(this.jobs) == 0 {
-		return
+// RemoveJob removes job by name and reports if it was found. Already started run isn't interrupted.
+func (this *Cron) RemoveJob(name string) bool {
+	this.lock.Lock()
+	item, found := this.jobs[name]
+	if found {
+		this.unschedule(item)
+		delete(this.jobs, name)
 	}
+	this.lock.Unlock()
+
+	this.notify()
+	return found
+}
+
+// Run catches up missed runs and runs jobs until Stop is called
+func (this *Cron) Run() {
+	this.catchUp(this.wallNow())
 
 	for {
+		this.tick()
+		timer := time.NewTimer(this.sleepDuration(this.wallNow()))
 		select {
-		case <-time.After(this.scheduleInterval):
-			this.run()
+		case <-timer.C:
+			this.fire(this.wallNow())
+		case <-this.wakeup:
+			timer.Stop()
 		case <-this.stop:
+			timer.Stop()
+			this.lock.Lock()
+			this.tickedAt = time.Time{}
+			this.lock.Unlock()
 			return
 		}
 	}
 }
 
+func (this *Cron) tick() {
+	this.lock.Lock()
+	this.tickedAt = this.now()
+	this.lock.Unlock()
+}
+
+// Alive reports an error unless Run loop is running and ticked recently
+func (this *Cron) Alive(now time.Time) error {
+	this.lock.Lock()
+	tickedAt := this.tickedAt
+	this.lock.Unlock()
+
+	if tickedAt.IsZero() {
+		return fmt.Errorf("scheduler isn't running")
+	}
+
+	if age := now.Sub(tickedAt); age > maxTickAge {
+		return fmt.Errorf("scheduler didn't tick for %s", age.Round(time.Second))
+	}
+	return nil
+}
+
+// Stop stops Run. It doesn't wait for started jobs, see Wait.
 func (this *Cron) Stop() {
-	this.stop <- struct{}{}
+	this.stopped.Do(func() {
+		close(this.stop)
+	})
+}
+
+// Wait waits for started jobs to finish and reports if they did within timeout
+func (this *Cron) Wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		this.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// wallNow strips monotonic clock reading, so fire times follow wall clock jumps
+func (this *Cron) wallNow() time.Time {
+	return this.now().Round(0)
+}
+
+func (this *Cron) notify() {
+	select {
+	case this.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (this *Cron) sleepDuration(now time.Time) time.Duration {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if len(this.queue) == 0 {
+		return maxSleep
+	}
+
+	duration := this.queue[0].next.Sub(now)
+	switch {
+	case duration < 0:
+		return 0
+	case duration > maxSleep:
+		return maxSleep
+	}
+	return duration
+}
+
+// fire starts all jobs due at now. Jobs which missed several fire times
+// (wall clock jumped forward) run once.
+func (this *Cron) fire(now time.Time) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	for len(this.queue) > 0 && !this.queue[0].next.After(now) {
+		item := heap.Pop(&this.queue).(*cronItem)
+		this.start(item, item.next)
+		this.schedule(item, now)
+	}
 }
 
-func (this *Cron) run() {
-	now := time.Now()
-	for i, item := range this.jobs {
-		if item.checker.Check(now) && item.checkLastExecTime(now) {
-			go item.job.Run(now)
-			this.jobs[i].lastExecTime = now
+// catchUp starts the latest run of every job within grace which didn't happen
+func (this *Cron) catchUp(now time.Time) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.catchUpGrace <= 0 {
+		return
+	}
+
+	for _, item := range this.jobs {
+		var missed time.Time
+		for next := item.checker.Next(now.Add(-this.catchUpGrace)); !next.IsZero() && !next.After(now); next = item.checker.Next(next) {
+			missed = next
 		}
+
+		if !missed.IsZero() {
+			log.Printf("cron: catch up %q scheduled at %s", item.name, missed)
+			this.start(item, missed)
+		}
+	}
+}
+
+// schedule pushes item to the queue at its next fire time. Must be called with lock held.
+func (this *Cron) schedule(item *cronItem, now time.Time) {
+	item.next = item.checker.Next(now)
+	if item.next.IsZero() {
+		log.Printf("cron: %q has no next fire time", item.name)
+		return
+	}
+	heap.Push(&this.queue, item)
+}
+
+// unschedule removes item from the queue. Must be called with lock held.
+func (this *Cron) unschedule(item *cronItem) {
+	if item.index >= 0 {
+		heap.Remove(&this.queue, item.index)
+	}
+}
+
+// start runs job for scheduled time unless the job is running, the time has already succeeded,
+// a later one has started, the time has run out of attempts or this instance isn't the leader. The run is skipped if its start
+// can't be recorded, otherwise it could be repeated after restart. Must be called with lock held.
+func (this *Cron) start(item *cronItem, scheduled time.Time) {
+	if this.leader != nil && !this.leader.IsLeader() {
+		return
+	}
+
+	if item.running {
+		log.Printf("cron: %q is still running, skip run scheduled at %s", item.name, scheduled)
+		return
+	}
+
+	history, err := this.history.Get(item.name)
+	if err != nil {
+		log.Printf("cron: error get %q history: %s", item.name, err)
+		return
+	}
+
+	if history.LastSuccess != nil && !history.LastSuccess.Scheduled.Before(scheduled) {
+		return
+	}
+
+	attempt := 1
+	if history.LastRun != nil {
+		if history.LastRun.Scheduled.After(scheduled) {
+			return
+		}
+
+		if history.LastRun.Scheduled.Equal(scheduled) {
+			// runs recorded before attempts were counted are the first attempt
+			attempt = history.LastRun.Attempt + 1
+			if attempt < 2 {
+				attempt = 2
+			}
+			if attempt > maxAttempts {
+				return
+			}
+			log.Printf("cron: retry %q scheduled at %s, attempt %d", item.name, scheduled, attempt)
+		}
+	}
+
+	run := JobRun{
+		Scheduled: scheduled,
+		Attempt:   attempt,
+		StartedAt: this.now(),
+	}
+	if err := this.history.Record(item.name, run); err != nil {
+		log.Printf("cron: error record %q start, skip run: %s", item.name, err)
+		return
+	}
+
+	historyStorage := this.history
+	item.running = true
+	this.running.Add(1)
+	go func() {
+		defer this.running.Done()
+		defer this.finish(item)
+		this.execute(historyStorage, item, run)
+	}()
+}
+
+// finish allows the next run of the job
+func (this *Cron) finish(item *cronItem) {
+	this.lock.Lock()
+	item.running = false
+	this.lock.Unlock()
+}
+
+// execute runs job and records the run outcome
+func (this *Cron) execute(history IHistory, item *cronItem, run JobRun) error {
+	err := item.job.Run(inLocation(item.checker, run.Scheduled))
+	result := "success"
+	if err != nil {
+		log.Printf("cron: job %q failed: %s", item.name, err)
+		run.Error = err.Error()
+		result = "failure"
+	}
+
+	run.FinishedAt = this.now()
+	metrics.JobRuns.WithLabelValues(item.name, result).Inc()
+	metrics.JobDuration.WithLabelValues(item.name).Observe(run.FinishedAt.Sub(run.StartedAt).Seconds())
+	if err := history.Record(item.name, run); err != nil {
+		log.Printf("cron: error record %q finish: %s", item.name, err)
 	}
+	return err
+}
+
+// cronQueue is a heap of items ordered by next fire time
+type cronQueue []*cronItem
+
+func (this cronQueue) Len() int {
+	return len(this)
+}
+
+func (this cronQueue) Less(i, j int) bool {
+	return this[i].next.Before(this[j].next)
+}
+
+func (this cronQueue) Swap(i, j int) {
+	this[i], this[j] = this[j], this[i]
+	this[i].index = i
+	this[j].index = j
+}
+
+func (this *cronQueue) Push(x interface{}) {
+	item := x.(*cronItem)
+	item.index = len(*this)
+	*this = append(*this, item)
 }
 
-var defaultCron *Cron = NewCron(defaultScheduleInterval)
+func (this *cronQueue) Pop() interface{} {
+	old := *this
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	item.index = -1
+	*this = old[:len(old)-1]
+	return item
+}
+
+var defaultCron *Cron = NewCron()
+
+func SetHistory(history IHistory, catchUpGrace time.Duration) {
+	defaultCron.SetHistory(history, catchUpGrace)
+}
+
+func SetLeader(leader ILeader) {
+	defaultCron.SetLeader(leader)
+}
+
+func TakeOver() {
+	defaultCron.TakeOver()
+}
 
-func AddJob(checker IChecker, job ICronJob) {
-	defaultCron.AddJob(checker, job)
+func AddJob(name string, checker IChecker, job ICronJob) {
+	defaultCron.AddJob(name, checker, job)
+}
+
+func RemoveJob(name string) bool {
+	return defaultCron.RemoveJob(name)
 }
 
 func Run() {
@@ -88,3 +454,16 @@ func Run() {
 func Stop() {
 	defaultCron.Stop()
 }
+
+func Wait(timeout time.Duration) bool {
+	return defaultCron.Wait(timeout)
+}
+
+func Alive() error {
+	return defaultCron.Alive(time.Now())
+}
+
+// Handler serves default cron admin api
+func Handler() http.Handler {
+	return defaultCron
+}
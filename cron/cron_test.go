@@ -0,0 +1,287 @@
+package cron_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"bobby/cron"
+
+	. "gopkg.in/check.v1"
+)
+
+// recordingJob sends time of every run to runs and fails with err
+type recordingJob struct {
+	runs chan time.Time
+	err  error
+}
+
+func (this *recordingJob) Run(now time.Time) error {
+	this.runs <- now
+	return this.err
+}
+
+type CronTestSuite struct {
+	filename string
+	schedule *cron.Schedule
+	now      time.Time
+}
+
+var _ = Suite(&CronTestSuite{})
+
+func (suite *CronTestSuite) SetUpTest(c *C) {
+	suite.filename = filepath.Join(c.MkDir(), "history.json")
+
+	var err error
+	suite.schedule, err = cron.ParseSchedule("47 9 * * MON-FRI", time.UTC)
+	c.Assert(err, IsNil)
+
+	suite.now = time.Date(2020, 3, 2, 9, 0, 0, 0, time.UTC)
+}
+
+func (suite *CronTestSuite) newCron(c *C, job cron.ICronJob) (*cron.Cron, *cron.FileHistory) {
+	history, err := cron.NewFileHistory(suite.filename)
+	c.Assert(err, IsNil)
+
+	now := suite.now
+	scheduler := cron.NewCron()
+	scheduler.SetNow(func() time.Time { return now })
+	scheduler.SetHistory(history, time.Hour)
+	scheduler.AddJob("duty", suite.schedule, job)
+	return scheduler, history
+}
+
+// waitFinished waits until the last run of the job is recorded as finished
+func waitFinished(c *C, history cron.IHistory) cron.JobHistory {
+	for i := 0; i < 1000; i++ {
+		jobHistory, err := history.Get("duty")
+		c.Assert(err, IsNil)
+		if jobHistory.LastRun != nil && jobHistory.LastRun.Finished() {
+			return jobHistory
+		}
+		time.Sleep(time.Millisecond)
+	}
+	c.Fatal("job didn't finish")
+	return cron.JobHistory{}
+}
+
+func (suite *CronTestSuite) TestRunOncePerSlot(c *C) {
+	job := &recordingJob{runs: make(chan time.Time, 10)}
+	scheduler, history := suite.newCron(c, job)
+
+	slot := time.Date(2020, 3, 2, 9, 47, 0, 0, time.UTC)
+	scheduler.Fire(slot.Add(-time.Second))
+	c.Assert(job.runs, HasLen, 0)
+
+	scheduler.Fire(slot.Add(time.Second))
+	c.Assert(<-job.runs, Equals, slot)
+	waitFinished(c, history)
+
+	scheduler.Fire(slot.Add(2 * time.Second))
+	c.Assert(job.runs, HasLen, 0)
+
+	// restart within the same minute
+	scheduler, _ = suite.newCron(c, job)
+	scheduler.CatchUp(slot.Add(30 * time.Second))
+	c.Assert(job.runs, HasLen, 0)
+}
+
+func (suite *CronTestSuite) TestCatchUp(c *C) {
+	job := &recordingJob{runs: make(chan time.Time, 10), err: fmt.Errorf("slack is down")}
+	scheduler, history := suite.newCron(c, job)
+
+	// down at 09:47, started at 10:30
+	scheduler.CatchUp(time.Date(2020, 3, 2, 10, 30, 15, 0, time.UTC))
+	c.Assert(<-job.runs, Equals, time.Date(2020, 3, 2, 9, 47, 0, 0, time.UTC))
+
+	jobHistory := waitFinished(c, history)
+	c.Assert(jobHistory.LastRun.Error, Equals, "slack is down")
+	c.Assert(jobHistory.LastSuccess, IsNil)
+
+	// failed runs are retried on restart up to max attempts
+	for attempt := 2; attempt <= 3; attempt++ {
+		scheduler, history = suite.newCron(c, job)
+		scheduler.CatchUp(time.Date(2020, 3, 2, 10, 31, 0, 0, time.UTC))
+		c.Assert(<-job.runs, Equals, time.Date(2020, 3, 2, 9, 47, 0, 0, time.UTC))
+		jobHistory = waitFinished(c, history)
+		c.Assert(jobHistory.LastRun.Attempt, Equals, attempt)
+	}
+
+	scheduler, history = suite.newCron(c, job)
+	scheduler.CatchUp(time.Date(2020, 3, 2, 10, 32, 0, 0, time.UTC))
+	c.Assert(scheduler.Wait(time.Second), Equals, true)
+	c.Assert(job.runs, HasLen, 0)
+
+	// missed runs older than grace are skipped
+	scheduler.CatchUp(time.Date(2020, 3, 3, 11, 0, 0, 0, time.UTC))
+	c.Assert(job.runs, HasLen, 0)
+
+	job.err = nil
+	scheduler.CatchUp(time.Date(2020, 3, 3, 10, 0, 0, 0, time.UTC))
+	c.Assert(<-job.runs, Equals, time.Date(2020, 3, 3, 9, 47, 0, 0, time.UTC))
+	jobHistory = waitFinished(c, history)
+	c.Assert(jobHistory.LastSuccess.Scheduled, Equals, time.Date(2020, 3, 3, 9, 47, 0, 0, time.UTC))
+}
+
+func (suite *CronTestSuite) TestCatchUpCrashedRun(c *C) {
+	slot := time.Date(2020, 3, 2, 9, 47, 0, 0, time.UTC)
+	crashed, err := cron.NewFileHistory(suite.filename)
+	c.Assert(err, IsNil)
+	c.Assert(crashed.Record("duty", cron.JobRun{Scheduled: slot, StartedAt: slot}), IsNil)
+
+	job := &recordingJob{runs: make(chan time.Time, 10)}
+	scheduler, history := suite.newCron(c, job)
+	scheduler.CatchUp(slot.Add(10 * time.Minute))
+	c.Assert(<-job.runs, Equals, slot)
+
+	jobHistory := waitFinished(c, history)
+	c.Assert(jobHistory.LastSuccess.Scheduled, Equals, slot)
+	c.Assert(jobHistory.LastSuccess.Attempt, Equals, 2)
+
+	// succeeded slot isn't caught up again
+	scheduler, _ = suite.newCron(c, job)
+	scheduler.CatchUp(slot.Add(20 * time.Minute))
+	c.Assert(scheduler.Wait(time.Second), Equals, true)
+	c.Assert(job.runs, HasLen, 0)
+}
+
+func (suite *CronTestSuite) TestAddRemoveJobs(c *C) {
+	scheduler := cron.NewCron()
+	done := make(chan struct{})
+	go func() {
+		scheduler.Run()
+		close(done)
+	}()
+
+	everySecond, err := cron.ParseSchedule("* * * * * *", time.UTC)
+	c.Assert(err, IsNil)
+
+	job := &recordingJob{runs: make(chan time.Time, 10)}
+	scheduler.AddJob("duty", everySecond, job)
+	select {
+	case <-job.runs:
+	case <-time.After(3 * time.Second):
+		c.Fatal("job added at runtime didn't run")
+	}
+
+	c.Assert(scheduler.RemoveJob("duty"), Equals, true)
+	c.Assert(scheduler.RemoveJob("duty"), Equals, false)
+	c.Assert(scheduler.Wait(time.Second), Equals, true)
+	for len(job.runs) > 0 {
+		<-job.runs
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	c.Assert(job.runs, HasLen, 0)
+
+	scheduler.Stop()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		c.Fatal("Run didn't return after Stop")
+	}
+}
+
+func (suite *CronTestSuite) TestStopWithoutJobs(c *C) {
+	scheduler := cron.NewCron()
+	done := make(chan struct{})
+	go func() {
+		scheduler.Run()
+		close(done)
+	}()
+
+	scheduler.Stop()
+	scheduler.Stop()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		c.Fatal("Run didn't return after Stop")
+	}
+	c.Assert(scheduler.Wait(time.Second), Equals, true)
+}
+
+func (suite *CronTestSuite) TestAlive(c *C) {
+	scheduler := cron.NewCron()
+	scheduler.SetNow(func() time.Time { return suite.now })
+	c.Assert(scheduler.Alive(suite.now), ErrorMatches, "scheduler isn't running")
+
+	done := make(chan struct{})
+	go func() {
+		scheduler.Run()
+		close(done)
+	}()
+
+	for i := 0; i < 1000 && scheduler.Alive(suite.now) != nil; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	c.Assert(scheduler.Alive(suite.now), IsNil)
+	c.Assert(scheduler.Alive(suite.now.Add(time.Hour)), ErrorMatches, "scheduler didn't tick for 1h0m0s")
+
+	scheduler.Stop()
+	<-done
+	c.Assert(scheduler.Alive(suite.now), ErrorMatches, "scheduler isn't running")
+}
+
+type fakeLeader struct {
+	leader bool
+}
+
+func (this *fakeLeader) IsLeader() bool {
+	return this.leader
+}
+
+func (suite *CronTestSuite) TestLeader(c *C) {
+	job := &recordingJob{runs: make(chan time.Time, 10)}
+	scheduler, history := suite.newCron(c, job)
+	leader := &fakeLeader{}
+	scheduler.SetLeader(leader)
+
+	slot := time.Date(2020, 3, 2, 9, 47, 0, 0, time.UTC)
+	scheduler.Fire(slot)
+	c.Assert(job.runs, HasLen, 0)
+
+	// another instance ran the next slot as the leader
+	other, err := cron.NewFileHistory(suite.filename)
+	c.Assert(err, IsNil)
+	next := slot.AddDate(0, 0, 1)
+	c.Assert(other.Record("duty", cron.JobRun{Scheduled: next, StartedAt: next, FinishedAt: next}), IsNil)
+
+	// this instance takes over, reloads history and doesn't repeat the run
+	leader.leader = true
+	scheduler.SetNow(func() time.Time { return next.Add(10 * time.Minute) })
+	scheduler.TakeOver()
+	c.Assert(job.runs, HasLen, 0)
+
+	jobHistory, err := history.Get("duty")
+	c.Assert(err, IsNil)
+	c.Assert(jobHistory.LastRun.Scheduled, Equals, next)
+
+	scheduler.Fire(next)
+	c.Assert(job.runs, HasLen, 0)
+
+	scheduler.Fire(next.AddDate(0, 0, 1))
+	c.Assert(<-job.runs, Equals, next.AddDate(0, 0, 1))
+	waitFinished(c, history)
+}
+
+// failingHistory can't record runs
+type failingHistory struct{}
+
+func (this *failingHistory) Get(name string) (cron.JobHistory, error) {
+	return cron.JobHistory{}, nil
+}
+
+func (this *failingHistory) Record(name string, run cron.JobRun) error {
+	return fmt.Errorf("disk is full")
+}
+
+func (suite *CronTestSuite) TestSkipRunIfStartNotRecorded(c *C) {
+	job := &recordingJob{runs: make(chan time.Time, 10)}
+	scheduler := cron.NewCron()
+	scheduler.SetHistory(&failingHistory{}, time.Hour)
+	scheduler.AddJob("duty", suite.schedule, job)
+
+	scheduler.CatchUp(time.Date(2020, 3, 2, 10, 0, 0, 0, time.UTC))
+	c.Assert(scheduler.Wait(time.Second), Equals, true)
+	c.Assert(job.runs, HasLen, 0)
+}
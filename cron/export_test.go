@@ -0,0 +1,15 @@
+package cron
+
+import "time"
+
+func (this *Cron) SetNow(now func() time.Time) {
+	this.now = now
+}
+
+func (this *Cron) Fire(now time.Time) {
+	this.fire(now)
+}
+
+func (this *Cron) CatchUp(now time.Time) {
+	this.catchUp(now)
+}
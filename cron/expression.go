@@ -0,0 +1,419 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	monthNames = map[string]int{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}
+	weekdayNames = map[string]int{
+		"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+	}
+)
+
+// bounds of a cron expression field
+type bounds struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	secondBounds  = bounds{"second", 0, 59, nil}
+	minuteBounds  = bounds{"minute", 0, 59, nil}
+	hourBounds    = bounds{"hour", 0, 23, nil}
+	dayBounds     = bounds{"day of month", 1, 31, nil}
+	monthBounds   = bounds{"month", 1, 12, monthNames}
+	weekdayBounds = bounds{"day of week", 0, 7, weekdayNames}
+)
+
+// Schedule is a cron expression checker evaluated in its own time zone.
+//
+// Expressions have 5 fields (minute hour day-of-month month day-of-week) or 6 fields
+// with leading seconds. Fields support *, ?, lists (1,15), ranges (MON-FRI), steps (*/15, 8-18/2)
+// and month and weekday names. Day of month also supports L (last day), L-3 (third day
+// before the last), 15W (weekday nearest to the 15th) and LW (last weekday). Day of week
+// supports 5L (last Friday of the month) and 1#2 (second Monday of the month).
+// As in vixie cron, a day matches either of day of month and day of week when both are restricted.
+type Schedule struct {
+	expression string
+	location   *time.Location
+	hasSeconds bool
+
+	seconds, minutes, hours, months uint64
+
+	days            uint64
+	lastDayOffsets  []int
+	nearestWeekdays []int
+	lastWeekday     bool
+	anyDay          bool
+	weekdays        uint64
+	lastWeekdaysOf  []time.Weekday
+	nthWeekdays     []nthWeekday
+	anyWeekday      bool
+}
+
+type nthWeekday struct {
+	weekday time.Weekday
+	n       int
+}
+
+// ParseSchedule parses cron expression. Nil location means time.Local.
+func ParseSchedule(expression string, location *time.Location) (*Schedule, error) {
+	if location == nil {
+		location = time.Local
+	}
+
+	fields := strings.Fields(expression)
+	schedule := &Schedule{
+		expression: expression,
+		location:   location,
+	}
+
+	switch len(fields) {
+	case 5:
+	case 6:
+		schedule.hasSeconds = true
+		seconds, err := parseField(fields[0], secondBounds)
+		if err != nil {
+			return nil, err
+		}
+		schedule.seconds = seconds
+		fields = fields[1:]
+	default:
+		return nil, fmt.Errorf("cron expression %q must have 5 or 6 fields", expression)
+	}
+
+	var err error
+	if schedule.minutes, err = parseField(fields[0], minuteBounds); err != nil {
+		return nil, err
+	}
+
+	if schedule.hours, err = parseField(fields[1], hourBounds); err != nil {
+		return nil, err
+	}
+
+	if err = schedule.parseDays(fields[2]); err != nil {
+		return nil, err
+	}
+
+	if schedule.months, err = parseField(fields[3], monthBounds); err != nil {
+		return nil, err
+	}
+
+	if err = schedule.parseWeekdays(fields[4]); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// LoadSchedule parses cron expression in IANA time zone, empty zone means local time
+func LoadSchedule(expression, timezone string) (*Schedule, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q: %s", timezone, err)
+	}
+	return ParseSchedule(expression, location)
+}
+
+func (this *Schedule) String() string {
+	return this.expression + " " + this.location.String()
+}
+
+func (this *Schedule) Location() *time.Location {
+	return this.location
+}
+
+// step is the schedule resolution
+func (this *Schedule) step() time.Duration {
+	if this.hasSeconds {
+		return time.Second
+	}
+	return time.Minute
+}
+
+// Next returns the first fire time after the given time, zero time if there is none within 5 years.
+//
+// Fields are matched against wall clock time in the schedule zone. A wall clock time skipped
+// by a DST change fires right after the change, a time repeated by a DST change fires once.
+func (this *Schedule) Next(after time.Time) time.Time {
+	step := this.step()
+
+	// wall clock time is handled as UTC time which has no DST changes
+	local := after.In(this.location)
+	wall := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), 0, time.UTC)
+	wall = wall.Truncate(step).Add(step)
+
+	for limit := wall.AddDate(5, 0, 0); wall.Before(limit); {
+		switch {
+		case !hasBit(this.months, int(wall.Month())):
+			wall = time.Date(wall.Year(), wall.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !this.checkDay(wall):
+			wall = time.Date(wall.Year(), wall.Month(), wall.Day()+1, 0, 0, 0, 0, time.UTC)
+		case !hasBit(this.hours, wall.Hour()):
+			wall = wall.Truncate(time.Hour).Add(time.Hour)
+		case !hasBit(this.minutes, wall.Minute()):
+			wall = wall.Truncate(time.Minute).Add(time.Minute)
+		case this.hasSeconds && !hasBit(this.seconds, wall.Second()):
+			wall = wall.Add(time.Second)
+		default:
+			next := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, this.location)
+			if next.After(after) {
+				return next
+			}
+			wall = wall.Add(step)
+		}
+	}
+	return time.Time{}
+}
+
+func (this *Schedule) checkDay(now time.Time) bool {
+	switch {
+	case this.anyDay && this.anyWeekday:
+		return true
+	case this.anyDay:
+		return this.checkWeekday(now)
+	case this.anyWeekday:
+		return this.checkDayOfMonth(now)
+	}
+	return this.checkDayOfMonth(now) || this.checkWeekday(now)
+}
+
+func (this *Schedule) checkDayOfMonth(now time.Time) bool {
+	day, lastDay := now.Day(), daysIn(now)
+	if hasBit(this.days, day) {
+		return true
+	}
+
+	for _, offset := range this.lastDayOffsets {
+		if day == lastDay-offset {
+			return true
+		}
+	}
+
+	for _, target := range this.nearestWeekdays {
+		if target <= lastDay && day == nearestWeekday(now, target, lastDay) {
+			return true
+		}
+	}
+
+	return this.lastWeekday && day == nearestWeekday(now, lastDay, lastDay)
+}
+
+func (this *Schedule) checkWeekday(now time.Time) bool {
+	weekday, day := now.Weekday(), now.Day()
+	if hasBit(this.weekdays, int(weekday)) {
+		return true
+	}
+
+	for _, lastWeekday := range this.lastWeekdaysOf {
+		if weekday == lastWeekday && day+7 > daysIn(now) {
+			return true
+		}
+	}
+
+	for _, nth := range this.nthWeekdays {
+		if weekday == nth.weekday && (day-1)/7+1 == nth.n {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *Schedule) parseDays(field string) error {
+	if field == "*" || field == "?" {
+		this.anyDay = true
+		return nil
+	}
+
+	var plain []string
+	for _, part := range strings.Split(field, ",") {
+		switch {
+		case part == "L":
+			this.lastDayOffsets = append(this.lastDayOffsets, 0)
+		case part == "LW":
+			this.lastWeekday = true
+		case strings.HasPrefix(part, "L-"):
+			offset, err := strconv.Atoi(part[2:])
+			if err != nil || offset < 0 || offset > 30 {
+				return fmt.Errorf("invalid day of month %q", part)
+			}
+			this.lastDayOffsets = append(this.lastDayOffsets, offset)
+		case strings.HasSuffix(part, "W"):
+			day, err := parseValue(part[:len(part)-1], dayBounds)
+			if err != nil {
+				return err
+			}
+			this.nearestWeekdays = append(this.nearestWeekdays, day)
+		default:
+			plain = append(plain, part)
+		}
+	}
+
+	if len(plain) > 0 {
+		days, err := parseField(strings.Join(plain, ","), dayBounds)
+		if err != nil {
+			return err
+		}
+		this.days = days
+	}
+	return nil
+}
+
+func (this *Schedule) parseWeekdays(field string) error {
+	if field == "*" || field == "?" {
+		this.anyWeekday = true
+		return nil
+	}
+
+	var plain []string
+	for _, part := range strings.Split(field, ",") {
+		switch {
+		case len(part) > 1 && strings.HasSuffix(part, "L"):
+			weekday, err := parseValue(part[:len(part)-1], weekdayBounds)
+			if err != nil {
+				return err
+			}
+			this.lastWeekdaysOf = append(this.lastWeekdaysOf, time.Weekday(weekday%7))
+		case strings.Contains(part, "#"):
+			index := strings.Index(part, "#")
+			weekday, err := parseValue(part[:index], weekdayBounds)
+			if err != nil {
+				return err
+			}
+
+			n, err := strconv.Atoi(part[index+1:])
+			if err != nil || n < 1 || n > 5 {
+				return fmt.Errorf("invalid day of week %q", part)
+			}
+			this.nthWeekdays = append(this.nthWeekdays, nthWeekday{weekday: time.Weekday(weekday % 7), n: n})
+		default:
+			plain = append(plain, part)
+		}
+	}
+
+	if len(plain) > 0 {
+		weekdays, err := parseField(strings.Join(plain, ","), weekdayBounds)
+		if err != nil {
+			return err
+		}
+
+		// both 0 and 7 are Sunday
+		if hasBit(weekdays, 7) {
+			weekdays |= 1
+		}
+		this.weekdays = weekdays
+	}
+	return nil
+}
+
+// parseField parses comma separated list of values, ranges and steps into a bitset
+func parseField(field string, fieldBounds bounds) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		partBits, err := parseRange(part, fieldBounds)
+		if err != nil {
+			return 0, err
+		}
+		bits |= partBits
+	}
+	return bits, nil
+}
+
+func parseRange(part string, fieldBounds bounds) (uint64, error) {
+	rangePart, step := part, 1
+	if index := strings.Index(part, "/"); index >= 0 {
+		var err error
+		rangePart = part[:index]
+		step, err = strconv.Atoi(part[index+1:])
+		if err != nil || step < 1 {
+			return 0, fmt.Errorf("invalid %s step %q", fieldBounds.name, part)
+		}
+	}
+
+	from, to := fieldBounds.min, fieldBounds.max
+	switch {
+	case rangePart == "*" || rangePart == "?":
+	case strings.Contains(rangePart, "-"):
+		index := strings.Index(rangePart, "-")
+		var err error
+		if from, err = parseValue(rangePart[:index], fieldBounds); err != nil {
+			return 0, err
+		}
+		if to, err = parseValue(rangePart[index+1:], fieldBounds); err != nil {
+			return 0, err
+		}
+		// SAT-SUN is SAT-7
+		if fieldBounds.name == weekdayBounds.name && to == 0 {
+			to = 7
+		}
+		if from > to {
+			return 0, fmt.Errorf("invalid %s range %q", fieldBounds.name, part)
+		}
+	default:
+		var err error
+		if from, err = parseValue(rangePart, fieldBounds); err != nil {
+			return 0, err
+		}
+		if step == 1 {
+			to = from
+		}
+	}
+
+	var bits uint64
+	for value := from; value <= to; value += step {
+		bits |= 1 << uint(value)
+	}
+	return bits, nil
+}
+
+func rangeBits(from, to int) uint64 {
+	var bits uint64
+	for value := from; value <= to; value++ {
+		bits |= 1 << uint(value)
+	}
+	return bits
+}
+
+func parseValue(value string, fieldBounds bounds) (int, error) {
+	if number, found := fieldBounds.names[strings.ToUpper(value)]; found {
+		return number, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < fieldBounds.min || number > fieldBounds.max {
+		return 0, fmt.Errorf("invalid %s %q: expected %d-%d", fieldBounds.name, value, fieldBounds.min, fieldBounds.max)
+	}
+	return number, nil
+}
+
+func hasBit(bits uint64, value int) bool {
+	return bits&(1<<uint(value)) != 0
+}
+
+func daysIn(now time.Time) int {
+	return time.Date(now.Year(), now.Month()+1, 0, 0, 0, 0, 0, now.Location()).Day()
+}
+
+// nearestWeekday returns the working day nearest to the day of the month of now without leaving the month
+func nearestWeekday(now time.Time, day, lastDay int) int {
+	switch time.Date(now.Year(), now.Month(), day, 0, 0, 0, 0, now.Location()).Weekday() {
+	case time.Saturday:
+		if day == 1 {
+			return day + 2
+		}
+		return day - 1
+	case time.Sunday:
+		if day == lastDay {
+			return day - 2
+		}
+		return day + 1
+	}
+	return day
+}
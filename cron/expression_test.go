@@ -0,0 +1,134 @@
+package cron_test
+
+import (
+	"testing"
+	"time"
+
+	"bobby/cron"
+	"bobby/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+type ScheduleTestSuite struct{}
+
+var _ = Suite(&ScheduleTestSuite{})
+
+// fires returns days of the month of 2020 when schedule fires
+func fires(c *C, schedule cron.IChecker, month time.Month, location *time.Location) []int {
+	var days []int
+	from := time.Date(2020, month, 1, 0, 0, 0, 0, location)
+	till := from.AddDate(0, 1, 0)
+	for next := schedule.Next(from.Add(-time.Second)); !next.IsZero() && next.Before(till); next = schedule.Next(next) {
+		days = append(days, next.Day())
+	}
+	return days
+}
+
+func (suite *ScheduleTestSuite) TestParseErrors(c *C) {
+	for expression, message := range map[string]string{
+		"* * * *":         `cron expression "\* \* \* \*" must have 5 or 6 fields`,
+		"60 * * * *":      `invalid minute "60": expected 0-59`,
+		"0 10 * * MON-XX": `invalid day of week "XX": expected 0-7`,
+		"0 10 * * 5-1":    `invalid day of week range "5-1"`,
+		"*/0 * * * *":     `invalid minute step "\*/0"`,
+		"0 10 L-40 * *":   `invalid day of month "L-40"`,
+	} {
+		_, err := cron.ParseSchedule(expression, time.UTC)
+		c.Check(err, ErrorMatches, message)
+	}
+
+	_, err := cron.LoadSchedule("0 10 * * *", "Mars/Olympus")
+	c.Assert(err, ErrorMatches, `unknown timezone "Mars/Olympus".*`)
+}
+
+func (suite *ScheduleTestSuite) TestTimeFields(c *C) {
+	schedule, err := cron.ParseSchedule("*/15 9-17/4 * * *", time.UTC)
+	c.Assert(err, IsNil)
+	c.Assert(schedule.Next(time.Date(2020, 3, 2, 13, 30, 10, 0, time.UTC)), Equals, time.Date(2020, 3, 2, 13, 45, 0, 0, time.UTC))
+	c.Assert(schedule.Next(time.Date(2020, 3, 2, 13, 45, 0, 0, time.UTC)), Equals, time.Date(2020, 3, 2, 17, 0, 0, 0, time.UTC))
+	c.Assert(schedule.Next(time.Date(2020, 3, 2, 17, 45, 0, 0, time.UTC)), Equals, time.Date(2020, 3, 3, 9, 0, 0, 0, time.UTC))
+
+	schedule, err = cron.ParseSchedule("30 0 10 * * *", time.UTC)
+	c.Assert(err, IsNil)
+	c.Assert(schedule.Next(time.Date(2020, 3, 2, 10, 0, 29, 0, time.UTC)), Equals, time.Date(2020, 3, 2, 10, 0, 30, 0, time.UTC))
+	c.Assert(schedule.Next(time.Date(2020, 3, 2, 10, 0, 30, 0, time.UTC)), Equals, time.Date(2020, 3, 3, 10, 0, 30, 0, time.UTC))
+
+	schedule, err = cron.ParseSchedule("0 0 30 FEB *", time.UTC)
+	c.Assert(err, IsNil)
+	c.Assert(schedule.Next(time.Date(2020, 3, 2, 10, 0, 0, 0, time.UTC)).IsZero(), Equals, true)
+}
+
+func (suite *ScheduleTestSuite) TestTimezone(c *C) {
+	schedule, err := cron.LoadSchedule("0 10 * * MON", "Europe/Berlin")
+	c.Assert(err, IsNil)
+
+	// 2020-03-02 is Monday, Berlin is UTC+1 in winter and UTC+2 since 2020-03-29
+	c.Assert(schedule.Next(time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)).UTC(), Equals, time.Date(2020, 3, 2, 9, 0, 0, 0, time.UTC))
+	c.Assert(schedule.Next(time.Date(2020, 3, 28, 0, 0, 0, 0, time.UTC)).UTC(), Equals, time.Date(2020, 3, 30, 8, 0, 0, 0, time.UTC))
+	c.Assert(fires(c, schedule, time.March, schedule.Location()), DeepEquals, []int{2, 9, 16, 23, 30})
+}
+
+func (suite *ScheduleTestSuite) TestDST(c *C) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	c.Assert(err, IsNil)
+
+	// 02:30 doesn't exist on 2020-03-29 and happens twice on 2020-10-25
+	schedule, err := cron.ParseSchedule("30 2 * * *", berlin)
+	c.Assert(err, IsNil)
+
+	next := schedule.Next(time.Date(2020, 3, 28, 12, 0, 0, 0, berlin))
+	c.Assert(next.Equal(time.Date(2020, 3, 29, 1, 30, 0, 0, time.UTC)), Equals, true, Commentf("%s", next))
+	next = schedule.Next(next)
+	c.Assert(next.Equal(time.Date(2020, 3, 30, 0, 30, 0, 0, time.UTC)), Equals, true, Commentf("%s", next))
+
+	var fired []time.Time
+	for next := schedule.Next(time.Date(2020, 10, 24, 12, 0, 0, 0, berlin)); next.Day() < 27; next = schedule.Next(next) {
+		fired = append(fired, next.UTC())
+	}
+	c.Assert(len(fired), Equals, 2)
+	c.Assert(fired[1], Equals, time.Date(2020, 10, 26, 1, 30, 0, 0, time.UTC))
+}
+
+func (suite *ScheduleTestSuite) TestDays(c *C) {
+	for expression, days := range map[string][]int{
+		// 2020-02-01 is Saturday, February has 29 days
+		"0 10 * FEB MON-FRI":  {3, 4, 5, 6, 7, 10, 11, 12, 13, 14, 17, 18, 19, 20, 21, 24, 25, 26, 27, 28},
+		"0 10 1W * *":         {3},
+		"0 10 15W * *":        {14},
+		"0 10 L * ?":          {29},
+		"0 10 L-1 * ?":        {28},
+		"0 10 LW * ?":         {28},
+		"0 10 ? * 5L":         {28},
+		"0 10 ? * MON#2":      {10},
+		"0 10 1,15 * SUN":     {1, 2, 9, 15, 16, 23},
+		"0 10 */10 * *":       {1, 11, 21},
+		"0 10 * * 0,7":        {2, 9, 16, 23},
+		"0 10 * * SAT-SUN/1":  {1, 2, 8, 9, 15, 16, 22, 23, 29},
+		"0 10 29 JAN-MAR/2 *": nil,
+	} {
+		schedule, err := cron.ParseSchedule(expression, time.UTC)
+		c.Assert(err, IsNil)
+		c.Check(fires(c, schedule, time.February, time.UTC), DeepEquals, days, Commentf("%s", expression))
+	}
+}
+
+func (suite *ScheduleTestSuite) TestBefore(c *C) {
+	schedule, err := cron.ParseSchedule("0 10 * * *", time.UTC)
+	c.Assert(err, IsNil)
+
+	checker := cron.Before(schedule, 5*time.Minute)
+	c.Assert(checker.Next(time.Date(2020, 3, 2, 9, 0, 0, 0, time.UTC)), Equals, time.Date(2020, 3, 2, 9, 55, 0, 0, time.UTC))
+	c.Assert(checker.Next(time.Date(2020, 3, 2, 9, 55, 0, 0, time.UTC)), Equals, time.Date(2020, 3, 3, 9, 55, 0, 0, time.UTC))
+}
+
+func (suite *ScheduleTestSuite) TestEveryWorkingDayAt(c *C) {
+	checker := cron.EveryWorkingDayAt(utils.DayTime{Hour: 9, Minute: 47})
+	c.Assert(fires(c, checker, time.February, time.Local), DeepEquals,
+		[]int{3, 4, 5, 6, 7, 10, 11, 12, 13, 14, 17, 18, 19, 20, 21, 24, 25, 26, 27, 28})
+}
@@ -0,0 +1,169 @@
+package cron
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// JobRun is a single run of a job. Scheduled is the schedule slot the run belongs to,
+// Attempt counts runs of the slot. Manual runs are scheduled at their start time.
+type JobRun struct {
+	Scheduled  time.Time `json:"scheduled"`
+	Attempt    int       `json:"attempt,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Manual     bool      `json:"manual,omitempty"`
+}
+
+func (this *JobRun) Finished() bool {
+	return !this.FinishedAt.IsZero()
+}
+
+func (this *JobRun) Succeeded() bool {
+	return this.Finished() && len(this.Error) == 0
+}
+
+// JobHistory is the last run and the last successful run of a job
+type JobHistory struct {
+	LastRun     *JobRun `json:"last_run,omitempty"`
+	LastSuccess *JobRun `json:"last_success,omitempty"`
+}
+
+// IHistory persists job runs by job name
+type IHistory interface {
+	Get(name string) (JobHistory, error)
+	Record(name string, run JobRun) error
+}
+
+// IReloader is a history shared by instances, which is reloaded when the instance becomes the leader
+type IReloader interface {
+	Reload() error
+}
+
+// FileHistory keeps job history in a json file
+type FileHistory struct {
+	filename string
+
+	lock sync.Mutex
+	jobs map[string]JobHistory
+}
+
+func NewFileHistory(filename string) (*FileHistory, error) {
+	history := &FileHistory{
+		filename: filename,
+		jobs:     make(map[string]JobHistory),
+	}
+
+	if err := history.Reload(); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// Reload reads history from the file, which may be written by another instance
+func (this *FileHistory) Reload() error {
+	data, err := ioutil.ReadFile(this.filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	jobs := make(map[string]JobHistory)
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return err
+	}
+
+	this.lock.Lock()
+	this.jobs = jobs
+	this.lock.Unlock()
+	return nil
+}
+
+func (this *FileHistory) Get(name string) (JobHistory, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.jobs[name], nil
+}
+
+func (this *FileHistory) Record(name string, run JobRun) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	recordRun(this.jobs, name, run)
+	return this.save()
+}
+
+// save writes history to a temporary file and renames it, so a crash never leaves a partial file.
+// Must be called with lock held.
+func (this *FileHistory) save() error {
+	data, err := json.MarshalIndent(this.jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(this.filename), filepath.Base(this.filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), this.filename)
+}
+
+// memoryHistory is used when history isn't persisted
+type memoryHistory struct {
+	lock sync.Mutex
+	jobs map[string]JobHistory
+}
+
+func newMemoryHistory() *memoryHistory {
+	return &memoryHistory{
+		jobs: make(map[string]JobHistory),
+	}
+}
+
+func (this *memoryHistory) Get(name string) (JobHistory, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.jobs[name], nil
+}
+
+func (this *memoryHistory) Record(name string, run JobRun) error {
+	this.lock.Lock()
+	recordRun(this.jobs, name, run)
+	this.lock.Unlock()
+	return nil
+}
+
+// recordRun updates job history. Runs finishing after a later run has started don't replace it.
+func recordRun(jobs map[string]JobHistory, name string, run JobRun) {
+	history := jobs[name]
+	if history.LastRun == nil || !run.Scheduled.Before(history.LastRun.Scheduled) {
+		history.LastRun = &run
+	}
+
+	if run.Succeeded() && (history.LastSuccess == nil || !run.Scheduled.Before(history.LastSuccess.Scheduled)) {
+		history.LastSuccess = &run
+	}
+	jobs[name] = history
+}
@@ -0,0 +1,332 @@
+package delivery
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"bobby/metrics"
+	"bobby/utils"
+)
+
+const (
+	kindChannel   = "channel"
+	kindDirect    = "direct"
+	kindPostponed = "postponed"
+
+	minBackoff = 1 * time.Second
+	maxBackoff = 1 * time.Minute
+
+	// abortTimeout bounds waiting for sends in flight when queue is closed
+	abortTimeout = 5 * time.Second
+)
+
+type ISender interface {
+	SendMessage(channelID, text string) error
+	SendDirectMessage(userID, text string) error
+	SendPostponedMessage(responseURL, text string) error
+}
+
+type retryAfterError interface {
+	RetryAfter() time.Duration
+}
+
+type retryableError interface {
+	Retryable() bool
+}
+
+type Options struct {
+	Concurrency    int
+	MaxAttempts    int
+	DeadLetterFile string
+}
+
+type message struct {
+	Kind        string    `json:"kind"`
+	Destination string    `json:"destination"`
+	Text        string    `json:"text"`
+	Attempts    int       `json:"attempts"`
+	EnqueuedAt  time.Time `json:"enqueued_at"`
+	Error       string    `json:"error,omitempty"`
+}
+
+func (this *message) key() string {
+	return this.Kind + ":" + this.Destination
+}
+
+// logDestination hides response urls in logs, anyone with the url can post to the channel
+func (this *message) logDestination() string {
+	if this.Kind == kindPostponed {
+		return "<redacted>"
+	}
+	return this.Destination
+}
+
+// Queue delivers outbound messages asynchronously.
+// Messages to the same destination are delivered in order, destinations are served concurrently
+// with at most Concurrency sends in flight. Failed sends are retried with backoff (honouring Retry-After)
+// and dead-lettered after MaxAttempts.
+type Queue struct {
+	sender  ISender
+	options Options
+	sem     chan struct{}
+	pending utils.Inflight
+
+	lock   sync.Mutex
+	queues map[string][]*message
+	stats  Stats
+
+	deadLetterLock sync.Mutex
+	deadLetter     *os.File
+
+	closed  chan struct{}
+	closing sync.Once
+
+	sleep func(time.Duration)
+}
+
+func NewQueue(sender ISender, options Options) (*Queue, error) {
+	if options.Concurrency <= 0 {
+		options.Concurrency = 1
+	}
+
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = 1
+	}
+
+	queue := &Queue{
+		sender:  sender,
+		options: options,
+		sem:     make(chan struct{}, options.Concurrency),
+		queues:  make(map[string][]*message),
+		stats: Stats{
+			Channels: make(map[string]*ChannelStats),
+		},
+		closed: make(chan struct{}),
+	}
+	queue.sleep = queue.wait
+
+	if len(options.DeadLetterFile) > 0 {
+		file, err := os.OpenFile(options.DeadLetterFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, err
+		}
+		queue.deadLetter = file
+	}
+	return queue, nil
+}
+
+func (this *Queue) SendMessage(channelID, text string) error {
+	this.enqueue(kindChannel, channelID, text)
+	return nil
+}
+
+func (this *Queue) SendDirectMessage(userID, text string) error {
+	this.enqueue(kindDirect, userID, text)
+	return nil
+}
+
+func (this *Queue) SendPostponedMessage(responseURL, text string) error {
+	this.enqueue(kindPostponed, responseURL, text)
+	return nil
+}
+
+// Wait waits until all enqueued messages are delivered or dead-lettered.
+// It returns false if timeout expired first.
+func (this *Queue) Wait(timeout time.Duration) bool {
+	return this.pending.Wait(timeout)
+}
+
+// Close waits until enqueued messages are delivered within timeout. Messages left after timeout
+// aren't sent or retried, they are dead-lettered so nothing is lost on shutdown.
+// Messages enqueued after Close are dead-lettered too.
+func (this *Queue) Close(timeout time.Duration) error {
+	delivered := this.Wait(timeout)
+
+	this.lock.Lock()
+	left := 0
+	for _, queue := range this.queues {
+		left += len(queue)
+	}
+	this.lock.Unlock()
+
+	this.closing.Do(func() {
+		close(this.closed)
+	})
+	if !delivered {
+		this.Wait(abortTimeout)
+	}
+
+	this.deadLetterLock.Lock()
+	if this.deadLetter != nil {
+		this.deadLetter.Close()
+		this.deadLetter = nil
+	}
+	this.deadLetterLock.Unlock()
+
+	if !delivered {
+		return fmt.Errorf("%d messages aren't delivered in %s, they are dead-lettered", left, timeout)
+	}
+	return nil
+}
+
+func (this *Queue) isClosed() bool {
+	select {
+	case <-this.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// wait sleeps for backoff, it is interrupted by Close
+func (this *Queue) wait(d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-this.closed:
+	}
+}
+
+func (this *Queue) enqueue(kind, destination, text string) {
+	msg := &message{
+		Kind:        kind,
+		Destination: destination,
+		Text:        text,
+		EnqueuedAt:  time.Now(),
+	}
+	key := msg.key()
+
+	this.pending.Add()
+
+	this.lock.Lock()
+	this.channelStats(msg).Enqueued++
+	this.stats.Enqueued++
+	queue, active := this.queues[key]
+	this.queues[key] = append(queue, msg)
+	this.lock.Unlock()
+
+	if !active {
+		go this.drain(key)
+	}
+}
+
+// drain delivers messages for key one by one until queue for key is empty
+func (this *Queue) drain(key string) {
+	for {
+		this.lock.Lock()
+		queue := this.queues[key]
+		if len(queue) == 0 {
+			delete(this.queues, key)
+			this.lock.Unlock()
+			return
+		}
+		msg := queue[0]
+		this.lock.Unlock()
+
+		this.deliver(msg)
+
+		this.lock.Lock()
+		this.queues[key] = this.queues[key][1:]
+		this.lock.Unlock()
+		this.pending.Done()
+	}
+}
+
+func (this *Queue) deliver(msg *message) {
+	for {
+		if this.isClosed() {
+			if len(msg.Error) == 0 {
+				msg.Error = "queue is closed"
+			}
+			this.record(msg, func(stats *ChannelStats) { stats.Failed++ })
+			metrics.Messages.WithLabelValues(msg.statsKey(), "failed").Inc()
+			this.writeDeadLetter(msg)
+			return
+		}
+
+		msg.Attempts++
+
+		this.sem <- struct{}{}
+		err := this.send(msg)
+		<-this.sem
+
+		if err == nil {
+			this.record(msg, func(stats *ChannelStats) { stats.Sent++ })
+			metrics.Messages.WithLabelValues(msg.statsKey(), "sent").Inc()
+			return
+		}
+
+		log.Printf("delivery: error send %s message to %q (attempt %d): %s",
+			msg.Kind, msg.logDestination(), msg.Attempts, err)
+		msg.Error = err.Error()
+
+		if !isRetryable(err) || msg.Attempts >= this.options.MaxAttempts {
+			this.record(msg, func(stats *ChannelStats) { stats.Failed++ })
+			metrics.Messages.WithLabelValues(msg.statsKey(), "failed").Inc()
+			this.writeDeadLetter(msg)
+			return
+		}
+
+		this.record(msg, func(stats *ChannelStats) { stats.Retried++ })
+		metrics.Messages.WithLabelValues(msg.statsKey(), "retried").Inc()
+		this.sleep(getBackoff(err, msg.Attempts))
+	}
+}
+
+func (this *Queue) send(msg *message) error {
+	switch msg.Kind {
+	case kindChannel:
+		return this.sender.SendMessage(msg.Destination, msg.Text)
+	case kindDirect:
+		return this.sender.SendDirectMessage(msg.Destination, msg.Text)
+	case kindPostponed:
+		return this.sender.SendPostponedMessage(msg.Destination, msg.Text)
+	}
+	return fmt.Errorf("unknown message kind %q", msg.Kind)
+}
+
+func isRetryable(err error) bool {
+	if retryable, ok := err.(retryableError); ok {
+		return retryable.Retryable()
+	}
+	// network errors and anything unknown are worth retrying
+	return true
+}
+
+func getBackoff(err error, attempts int) time.Duration {
+	if rateLimited, ok := err.(retryAfterError); ok {
+		return rateLimited.RetryAfter()
+	}
+
+	backoff := minBackoff << uint(attempts-1)
+	if backoff > maxBackoff || backoff <= 0 {
+		backoff = maxBackoff
+	}
+	return backoff
+}
+
+func (this *Queue) writeDeadLetter(msg *message) {
+	this.deadLetterLock.Lock()
+	defer this.deadLetterLock.Unlock()
+
+	if this.deadLetter == nil {
+		log.Printf("delivery: dead letter %s message to %q: %s", msg.Kind, msg.logDestination(), msg.Text)
+		return
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("delivery: error marshal dead letter: %s", err)
+		return
+	}
+
+	if _, err := this.deadLetter.Write(append(data, '\n')); err != nil {
+		log.Printf("delivery: error write dead letter: %s", err)
+	}
+}
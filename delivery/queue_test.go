@@ -0,0 +1,157 @@
+package delivery
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+type rateLimitedError struct{}
+
+func (this *rateLimitedError) Error() string             { return "rate limited" }
+func (this *rateLimitedError) Retryable() bool           { return true }
+func (this *rateLimitedError) RetryAfter() time.Duration { return 7 * time.Second }
+
+type permanentError struct{}
+
+func (this *permanentError) Error() string   { return "channel_not_found" }
+func (this *permanentError) Retryable() bool { return false }
+
+type fakeSender struct {
+	lock   sync.Mutex
+	errors map[string][]error
+	sent   map[string][]string
+}
+
+func (this *fakeSender) send(destination, text string) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if errors := this.errors[destination]; len(errors) > 0 {
+		this.errors[destination] = errors[1:]
+		return errors[0]
+	}
+	this.sent[destination] = append(this.sent[destination], text)
+	return nil
+}
+
+func (this *fakeSender) SendMessage(channelID, text string) error {
+	return this.send(channelID, text)
+}
+
+func (this *fakeSender) SendDirectMessage(userID, text string) error {
+	return this.send(userID, text)
+}
+
+func (this *fakeSender) SendPostponedMessage(responseURL, text string) error {
+	return this.send(responseURL, text)
+}
+
+type QueueTestSuite struct {
+	dir    string
+	sender *fakeSender
+	queue  *Queue
+	sleeps []time.Duration
+}
+
+var _ = Suite(&QueueTestSuite{})
+
+func (suite *QueueTestSuite) SetUpTest(c *C) {
+	suite.dir = c.MkDir()
+	suite.sender = &fakeSender{
+		errors: make(map[string][]error),
+		sent:   make(map[string][]string),
+	}
+	suite.sleeps = nil
+
+	var err error
+	suite.queue, err = NewQueue(suite.sender, Options{
+		Concurrency:    2,
+		MaxAttempts:    3,
+		DeadLetterFile: filepath.Join(suite.dir, "dead_letters.log"),
+	})
+	c.Assert(err, IsNil)
+
+	var lock sync.Mutex
+	suite.queue.sleep = func(d time.Duration) {
+		lock.Lock()
+		suite.sleeps = append(suite.sleeps, d)
+		lock.Unlock()
+	}
+}
+
+func (suite *QueueTestSuite) TestOrderAndRetry(c *C) {
+	suite.sender.errors["C1"] = []error{&rateLimitedError{}, fmt.Errorf("connection reset")}
+	for i := 0; i < 5; i++ {
+		suite.queue.SendMessage("C1", fmt.Sprintf("message %d", i))
+		suite.queue.SendDirectMessage("U1", fmt.Sprintf("direct %d", i))
+	}
+
+	c.Assert(suite.queue.Wait(time.Second), Equals, true)
+	c.Assert(suite.sender.sent["C1"], DeepEquals, []string{"message 0", "message 1", "message 2", "message 3", "message 4"})
+	c.Assert(suite.sender.sent["U1"], DeepEquals, []string{"direct 0", "direct 1", "direct 2", "direct 3", "direct 4"})
+	c.Assert(suite.sleeps, DeepEquals, []time.Duration{7 * time.Second, 2 * time.Second})
+
+	stats := suite.queue.Stats()
+	c.Assert(stats.Enqueued, Equals, uint64(10))
+	c.Assert(stats.Sent, Equals, uint64(10))
+	c.Assert(stats.Retried, Equals, uint64(2))
+	c.Assert(stats.Channels["channel:C1"].Retried, Equals, uint64(2))
+}
+
+func (suite *QueueTestSuite) TestDeadLetter(c *C) {
+	suite.sender.errors["C1"] = []error{&permanentError{}}
+	suite.sender.errors["C2"] = []error{fmt.Errorf("timeout"), fmt.Errorf("timeout"), fmt.Errorf("timeout")}
+	suite.queue.SendMessage("C1", "lost")
+	suite.queue.SendMessage("C2", "lost too")
+	suite.queue.SendPostponedMessage("https://hooks.slack.com/commands/1", "response")
+
+	c.Assert(suite.queue.Wait(time.Second), Equals, true)
+
+	stats := suite.queue.Stats()
+	c.Assert(stats.Failed, Equals, uint64(2))
+	c.Assert(stats.Sent, Equals, uint64(1))
+	c.Assert(stats.Channels["postponed"].Sent, Equals, uint64(1))
+
+	data, err := ioutil.ReadFile(filepath.Join(suite.dir, "dead_letters.log"))
+	c.Assert(err, IsNil)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	c.Assert(len(lines), Equals, 2)
+	c.Assert(strings.Join(lines, "\n"), Matches, `(?s).*"destination":"C1".*"attempts":1.*`)
+	c.Assert(strings.Join(lines, "\n"), Matches, `(?s).*"destination":"C2".*"attempts":3.*`)
+}
+
+func (suite *QueueTestSuite) TestClose(c *C) {
+	suite.queue.sleep = suite.queue.wait
+	suite.sender.errors["C1"] = []error{&rateLimitedError{}}
+	suite.queue.SendMessage("C1", "retried")
+	suite.queue.SendMessage("C1", "queued")
+	suite.queue.SendMessage("C2", "sent")
+
+	c.Assert(suite.queue.Close(100*time.Millisecond), ErrorMatches, "2 messages aren't delivered in 100ms, they are dead-lettered")
+	c.Assert(suite.sender.sent["C2"], DeepEquals, []string{"sent"})
+	c.Assert(suite.sender.sent["C1"], HasLen, 0)
+
+	data, err := ioutil.ReadFile(filepath.Join(suite.dir, "dead_letters.log"))
+	c.Assert(err, IsNil)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	c.Assert(len(lines), Equals, 2)
+	c.Assert(lines[0], Matches, `.*"text":"retried".*"error":"rate limited".*`)
+	c.Assert(lines[1], Matches, `.*"text":"queued".*"error":"queue is closed".*`)
+
+	suite.queue.SendMessage("C2", "late")
+	c.Assert(suite.queue.Wait(time.Second), Equals, true)
+	c.Assert(suite.sender.sent["C2"], DeepEquals, []string{"sent"})
+	c.Assert(suite.queue.Stats().Failed, Equals, uint64(3))
+}
@@ -0,0 +1,69 @@
+package delivery
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type ChannelStats struct {
+	Enqueued uint64 `json:"enqueued"`
+	Sent     uint64 `json:"sent"`
+	Retried  uint64 `json:"retried"`
+	Failed   uint64 `json:"failed"`
+}
+
+type Stats struct {
+	ChannelStats
+	Channels map[string]*ChannelStats `json:"channels"`
+}
+
+// channelStats returns stats for message destination. Must be called with lock held.
+func (this *Queue) channelStats(msg *message) *ChannelStats {
+	key := msg.statsKey()
+	stats, found := this.stats.Channels[key]
+	if !found {
+		stats = &ChannelStats{}
+		this.stats.Channels[key] = stats
+	}
+	return stats
+}
+
+// statsKey is the destination of stats and metrics. Response urls are unique per command
+// so postponed messages are accounted all together.
+func (this *message) statsKey() string {
+	if this.Kind == kindPostponed {
+		return kindPostponed
+	}
+	return this.key()
+}
+
+func (this *Queue) record(msg *message, update func(*ChannelStats)) {
+	this.lock.Lock()
+	update(this.channelStats(msg))
+	update(&this.stats.ChannelStats)
+	this.lock.Unlock()
+}
+
+// Stats returns a snapshot of delivery counters
+func (this *Queue) Stats() Stats {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	stats := Stats{
+		ChannelStats: this.stats.ChannelStats,
+		Channels:     make(map[string]*ChannelStats, len(this.stats.Channels)),
+	}
+	for key, channelStats := range this.stats.Channels {
+		channelStatsCopy := *channelStats
+		stats.Channels[key] = &channelStatsCopy
+	}
+	return stats
+}
+
+// ServeHTTP writes delivery stats as json
+func (this *Queue) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(this.Stats()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
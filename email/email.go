@@ -0,0 +1,166 @@
+package email
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"time"
+)
+
+const defaultTimeout = 30 * time.Second
+
+type Options struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	StartTLS bool
+	// Timeout limits the whole smtp session, 30s by default
+	Timeout time.Duration
+}
+
+// Client sends multipart (plain text + html) emails over smtp
+type Client struct {
+	options   Options
+	tlsConfig *tls.Config
+}
+
+func NewClient(options Options) *Client {
+	return &Client{
+		options: options,
+		tlsConfig: &tls.Config{
+			ServerName: options.Host,
+		},
+	}
+}
+
+func (this *Client) Send(to []string, subject, plain, html string) error {
+	if len(to) == 0 {
+		return nil
+	}
+
+	body, err := buildMessage(this.options.From, subject, plain, html)
+	if err != nil {
+		return err
+	}
+
+	conn, err := this.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if this.options.StartTLS {
+		if err := conn.StartTLS(this.tlsConfig); err != nil {
+			return fmt.Errorf("starttls: %s", err)
+		}
+	}
+
+	if len(this.options.Username) > 0 {
+		auth := smtp.PlainAuth("", this.options.Username, this.options.Password, this.options.Host)
+		if err := conn.Auth(auth); err != nil {
+			return fmt.Errorf("auth: %s", err)
+		}
+	}
+
+	if err := conn.Mail(this.options.From); err != nil {
+		return err
+	}
+
+	for _, recipient := range to {
+		if err := conn.Rcpt(recipient); err != nil {
+			return fmt.Errorf("rcpt %q: %s", recipient, err)
+		}
+	}
+
+	writer, err := conn.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := writer.Write(body); err != nil {
+		return err
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return conn.Quit()
+}
+
+// dial connects to smtp server, a server which stalls fails the session after timeout
+func (this *Client) dial() (*smtp.Client, error) {
+	timeout := this.options.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	netConn, err := net.DialTimeout("tcp", net.JoinHostPort(this.options.Host, this.options.Port), timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := netConn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	conn, err := smtp.NewClient(netConn, this.options.Host)
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// buildMessage addresses message to sender, recipients get it as bcc via RCPT TO
+// and don't see each other
+func buildMessage(from, subject, plain, html string) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	var headerBuf bytes.Buffer
+	fmt.Fprintf(&headerBuf, "From: %s\r\n", from)
+	fmt.Fprintf(&headerBuf, "To: %s\r\n", from)
+	fmt.Fprintf(&headerBuf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&headerBuf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&headerBuf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&headerBuf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", writer.Boundary())
+
+	for _, part := range []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=utf-8", plain},
+		{"text/html; charset=utf-8", html},
+	} {
+		partWriter, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		qpWriter := quotedprintable.NewWriter(partWriter)
+		if _, err := qpWriter.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+
+		if err := qpWriter.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return append(headerBuf.Bytes(), buf.Bytes()...), nil
+}
@@ -0,0 +1,105 @@
+package email
+
+import (
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"bobby/email/emailtest"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+type EmailTestSuite struct{}
+
+var _ = Suite(&EmailTestSuite{})
+
+func (suite *EmailTestSuite) TestSend(c *C) {
+	sink, err := emailtest.NewSink()
+	c.Assert(err, IsNil)
+	defer sink.Close()
+
+	host, port := sink.Addr()
+
+	client := NewClient(Options{
+		Host: host,
+		Port: port,
+		From: "bobby@example.com",
+	})
+
+	err = client.Send([]string{"managers@example.com", "john.doe@example.com"}, "[bobby] On duty:",
+		"On duty:\n\nNow: John Doe", "<h3>On duty:</h3>\n<p>Now: John Doe</p>")
+	c.Assert(err, IsNil)
+
+	msg := <-sink.Messages
+	c.Assert(msg.From, Equals, "bobby@example.com")
+	c.Assert(msg.Recipients, DeepEquals, []string{"managers@example.com", "john.doe@example.com"})
+
+	parsed, err := mail.ReadMessage(strings.NewReader(msg.Data))
+	c.Assert(err, IsNil)
+	c.Assert(parsed.Header.Get("To"), Equals, "bobby@example.com")
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	c.Assert(err, IsNil)
+	c.Assert(subject, Equals, "[bobby] On duty:")
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	c.Assert(err, IsNil)
+	c.Assert(mediaType, Equals, "multipart/alternative")
+
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	var parts []string
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			break
+		}
+		body, err := ioutil.ReadAll(quotedprintable.NewReader(part))
+		c.Assert(err, IsNil)
+		parts = append(parts, part.Header.Get("Content-Type")+": "+string(body))
+	}
+
+	c.Assert(parts, DeepEquals, []string{
+		"text/plain; charset=utf-8: On duty:\r\n\r\nNow: John Doe",
+		"text/html; charset=utf-8: <h3>On duty:</h3>\r\n<p>Now: John Doe</p>",
+	})
+}
+
+func (suite *EmailTestSuite) TestTimeout(c *C) {
+	// server accepts connection and never greets
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	c.Assert(err, IsNil)
+	client := NewClient(Options{
+		Host:    host,
+		Port:    port,
+		From:    "bobby@example.com",
+		Timeout: 100 * time.Millisecond,
+	})
+
+	startedAt := time.Now()
+	err = client.Send([]string{"managers@example.com"}, "subject", "plain", "<p>html</p>")
+	c.Assert(err, NotNil)
+	c.Assert(time.Since(startedAt) < time.Second, Equals, true)
+}
@@ -0,0 +1,99 @@
+// Package emailtest provides a local smtp server for email tests
+package emailtest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+)
+
+type Message struct {
+	From       string
+	Recipients []string
+	Data       string
+}
+
+// Sink is a local smtp server which accepts messages of a single connection
+type Sink struct {
+	listener net.Listener
+	Messages chan Message
+}
+
+func NewSink() (*Sink, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	sink := &Sink{
+		listener: listener,
+		Messages: make(chan Message, 1),
+	}
+	go sink.serve()
+	return sink, nil
+}
+
+// Addr returns host and port the sink listens on
+func (this *Sink) Addr() (string, string) {
+	host, port, _ := net.SplitHostPort(this.listener.Addr().String())
+	return host, port
+}
+
+func (this *Sink) Close() error {
+	return this.listener.Close()
+}
+
+func (this *Sink) serve() {
+	conn, err := this.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+
+	var msg Message
+	reply("220 localhost ESMTP sink")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch command {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "MAIL":
+			msg.From = strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<>")
+			reply("250 OK")
+		case "RCPT":
+			msg.Recipients = append(msg.Recipients, strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>"))
+			reply("250 OK")
+		case "DATA":
+			reply("354 go ahead")
+			var data []string
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data = append(data, dataLine)
+			}
+			msg.Data = strings.Join(data, "")
+			this.Messages <- msg
+			reply("250 OK")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK      = "ok"
+	StatusPartial = "partial"
+	StatusFailed  = "failed"
+
+	// checkTimeout bounds a single check, upstream clients have no timeouts of their own
+	checkTimeout = 5 * time.Second
+)
+
+// Check is a cheap check of a dependency, e.g. an authenticated request to an upstream api
+type Check struct {
+	Name  string
+	Check func() error
+}
+
+type CheckResult struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is ok if all checks pass, partial if some of them fail and failed if all fail
+type Report struct {
+	Status    string        `json:"status"`
+	CheckedAt time.Time     `json:"checked_at"`
+	Checks    []CheckResult `json:"checks"`
+}
+
+// Probe runs checks concurrently and caches the report for ttl, so frequent probes
+// don't hit upstream rate limits. Concurrent probes wait for the same run.
+type Probe struct {
+	checks []Check
+	ttl    time.Duration
+	now    func() time.Time
+
+	lock   sync.Mutex
+	report *Report
+}
+
+func NewProbe(ttl time.Duration, checks ...Check) *Probe {
+	return &Probe{
+		checks: checks,
+		ttl:    ttl,
+		now:    time.Now,
+	}
+}
+
+// Report returns the cached report or runs checks if it is older than ttl
+func (this *Probe) Report() *Report {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	now := this.now()
+	if this.report != nil && now.Sub(this.report.CheckedAt) < this.ttl {
+		return this.report
+	}
+
+	report := &Report{
+		CheckedAt: now,
+		Checks:    make([]CheckResult, len(this.checks)),
+	}
+
+	var wg sync.WaitGroup
+	for i := range this.checks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			report.Checks[i] = run(this.checks[i])
+		}(i)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, result := range report.Checks {
+		if result.Status != StatusOK {
+			failed++
+		}
+	}
+
+	switch {
+	case failed == 0:
+		report.Status = StatusOK
+	case failed < len(report.Checks):
+		report.Status = StatusPartial
+	default:
+		report.Status = StatusFailed
+	}
+
+	this.report = report
+	return report
+}
+
+// run runs check within checkTimeout. A timed out check is left running in background.
+func run(check Check) CheckResult {
+	startedAt := time.Now()
+	errs := make(chan error, 1)
+	go func() {
+		errs <- check.Check()
+	}()
+
+	var err error
+	select {
+	case err = <-errs:
+	case <-time.After(checkTimeout):
+		err = fmt.Errorf("timeout exceeded")
+	}
+
+	result := CheckResult{
+		Name:      check.Name,
+		Status:    StatusOK,
+		LatencyMs: float64(time.Since(startedAt).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusFailed
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// ServeHTTP writes report as json. Status is 503 only if all checks fail,
+// so one degraded dependency doesn't take the bot out of service.
+func (this *Probe) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	report := this.Report()
+	data, err := json.Marshal(report)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status == StatusFailed {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(data)
+}
@@ -0,0 +1,99 @@
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+type ProbeTestSuite struct {
+	now  time.Time
+	errs map[string]error
+}
+
+var _ = Suite(&ProbeTestSuite{})
+
+func (suite *ProbeTestSuite) SetUpTest(c *C) {
+	suite.now = time.Date(2020, 3, 10, 12, 0, 0, 0, time.Local)
+	suite.errs = make(map[string]error)
+}
+
+func (suite *ProbeTestSuite) newProbe(names ...string) *Probe {
+	var checks []Check
+	for _, name := range names {
+		name := name
+		checks = append(checks, Check{Name: name, Check: func() error {
+			return suite.errs[name]
+		}})
+	}
+
+	probe := NewProbe(30*time.Second, checks...)
+	probe.now = func() time.Time {
+		return suite.now
+	}
+	return probe
+}
+
+func (suite *ProbeTestSuite) serve(c *C, probe *Probe) (int, Report) {
+	recorder := httptest.NewRecorder()
+	probe.ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+
+	var report Report
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), &report), IsNil)
+	return recorder.Code, report
+}
+
+func (suite *ProbeTestSuite) TestReport(c *C) {
+	probe := suite.newProbe("slack", "jira", "opsgenie")
+
+	code, report := suite.serve(c, probe)
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(report.Status, Equals, StatusOK)
+	c.Assert(report.Checks, HasLen, 3)
+	c.Assert(report.Checks[0].Name, Equals, "slack")
+	c.Assert(report.Checks[0].Status, Equals, StatusOK)
+
+	suite.errs["jira"] = fmt.Errorf("status 401")
+	suite.now = suite.now.Add(time.Minute)
+	code, report = suite.serve(c, probe)
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(report.Status, Equals, StatusPartial)
+	c.Assert(report.Checks[1], DeepEquals, CheckResult{Name: "jira", Status: StatusFailed, LatencyMs: report.Checks[1].LatencyMs, Error: "status 401"})
+
+	suite.errs["slack"] = fmt.Errorf("invalid_auth")
+	suite.errs["opsgenie"] = fmt.Errorf("connection refused")
+	suite.now = suite.now.Add(time.Minute)
+	code, report = suite.serve(c, probe)
+	c.Assert(code, Equals, http.StatusServiceUnavailable)
+	c.Assert(report.Status, Equals, StatusFailed)
+}
+
+func (suite *ProbeTestSuite) TestCache(c *C) {
+	calls := 0
+	probe := NewProbe(30*time.Second, Check{Name: "slack", Check: func() error {
+		calls++
+		return nil
+	}})
+	probe.now = func() time.Time {
+		return suite.now
+	}
+
+	probe.Report()
+	suite.now = suite.now.Add(10 * time.Second)
+	c.Assert(probe.Report().CheckedAt, Equals, suite.now.Add(-10*time.Second))
+	c.Assert(calls, Equals, 1)
+
+	suite.now = suite.now.Add(30 * time.Second)
+	c.Assert(probe.Report().CheckedAt, Equals, suite.now)
+	c.Assert(calls, Equals, 2)
+}
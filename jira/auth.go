@@ -0,0 +1,35 @@
+package jira
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const myselfPath = "/rest/api/2/myself"
+
+// CheckAuth requests the current user, it is a cheap check of the token and jira availability
+func (this *Client) CheckAuth() error {
+	jiraURL := url.URL{
+		Scheme: "https",
+		Host:   jiraHost,
+		Path:   myselfPath,
+	}
+
+	req, err := http.NewRequest("GET", jiraURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Basic "+this.token)
+
+	resp, err := this.cli.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error check jira auth: status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package jira
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"bobby/config"
+)
+
+const (
+	groupMembersPath     = "/rest/api/2/group/member"
+	groupMembersPageSize = 50
+)
+
+type groupMembersPage struct {
+	IsLast bool `json:"isLast"`
+	Values []struct {
+		Name         string `json:"name"`
+		EmailAddress string `json:"emailAddress"`
+		DisplayName  string `json:"displayName"`
+		Active       bool   `json:"active"`
+	} `json:"values"`
+}
+
+// GetGroupMembers returns active members of jira group as roster users with name, email and jira login
+func (this *Client) GetGroupMembers(group string) ([]config.User, error) {
+	var users []config.User
+	for startAt := 0; ; {
+		page, err := this.getGroupMembersPage(group, startAt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, member := range page.Values {
+			if !member.Active {
+				continue
+			}
+
+			users = append(users, config.User{
+				Name:      member.DisplayName,
+				Email:     member.EmailAddress,
+				JiraLogin: member.Name,
+			})
+		}
+
+		if page.IsLast || len(page.Values) == 0 {
+			return users, nil
+		}
+		startAt += len(page.Values)
+	}
+}
+
+func (this *Client) getGroupMembersPage(group string, startAt int) (*groupMembersPage, error) {
+	values := url.Values{}
+	values.Add("groupname", group)
+	values.Add("startAt", strconv.Itoa(startAt))
+	values.Add("maxResults", strconv.Itoa(groupMembersPageSize))
+
+	jiraURL := url.URL{
+		Scheme:   "https",
+		Host:     jiraHost,
+		Path:     groupMembersPath,
+		RawQuery: values.Encode(),
+	}
+
+	req, err := http.NewRequest("GET", jiraURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Basic "+this.token)
+
+	resp, err := this.cli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error get jira group %q members: status %d", group, resp.StatusCode)
+	}
+
+	var page groupMembersPage
+	if err := json.Unmarshal(responseBody, &page); err != nil {
+		return nil, fmt.Errorf("error parse jira group %q members: %s", group, err)
+	}
+	return &page, nil
+}
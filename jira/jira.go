@@ -10,6 +10,8 @@ import (
 	"strings"
 	"time"
 
+	"bobby/metrics"
+
 	"github.com/codeship/go-retro"
 )
 
@@ -65,6 +67,7 @@ type Client struct {
 func NewClient(token string) *Client {
 	return &Client{
 		token: token,
+		cli:   metrics.NewHTTPClient(metrics.UpstreamJira),
 	}
 }
 
@@ -95,7 +98,7 @@ func (this *Client) GetTimesheetForUser(user string, from, to time.Time) (*Times
 
 	req.Header.Set("Authorization", "Basic "+this.token)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := this.cli.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -106,7 +109,7 @@ func (this *Client) GetTimesheetForUser(user string, from, to time.Time) (*Times
 		return nil, err
 	}
 
-	log.Printf("JIRA response for %q from %v to %v: %q", user, from, to, responseBody)
+	log.Printf("JIRA response for %q from %v to %v: status %d, %d bytes", user, from, to, resp.StatusCode, len(responseBody))
 
 	var timesheet Timesheet
 	if err := json.Unmarshal(responseBody, &timesheet); err != nil {
@@ -154,7 +157,12 @@ type durationErrorResult struct {
 
 func (this *Client) getTotalTimeSpentByUserAsync(user string, from, to time.Time, ch chan<- durationErrorResult) {
 	var totalTimeSpent time.Duration
+	attempts := 0
 	getTimesheetError := retro.DoWithRetry(func() error {
+		if attempts++; attempts > 1 {
+			metrics.UpstreamRetries.WithLabelValues(metrics.UpstreamJira).Inc()
+		}
+
 		result, err := this.GetTotalTimeSpentByUser(user, from, to)
 		if err != nil {
 			return retro.NewBackoffRetryableError(err, maxRetryAttempts)
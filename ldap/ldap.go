@@ -0,0 +1,73 @@
+package ldap
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"bobby/config"
+
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+const (
+	dialTimeout    = 10 * time.Second
+	requestTimeout = 30 * time.Second
+)
+
+type Options struct {
+	URL          string
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	// MemberFilter finds users of group, %s is replaced with escaped group dn
+	MemberFilter   string
+	NameAttribute  string
+	EmailAttribute string
+}
+
+// Client reads group members from LDAP directory. Connection is opened per request,
+// as groups are read rarely.
+type Client struct {
+	options Options
+}
+
+func NewClient(options Options) *Client {
+	return &Client{
+		options: options,
+	}
+}
+
+// GetGroupMembers returns users matching member filter of group as roster users with name and email
+func (this *Client) GetGroupMembers(group string) ([]config.User, error) {
+	conn, err := goldap.DialURL(this.options.URL, goldap.DialWithDialer(&net.Dialer{Timeout: dialTimeout}))
+	if err != nil {
+		return nil, fmt.Errorf("error connect ldap: %s", err)
+	}
+	defer conn.Close()
+	conn.SetTimeout(requestTimeout)
+
+	if len(this.options.BindDN) > 0 {
+		if err := conn.Bind(this.options.BindDN, this.options.BindPassword); err != nil {
+			return nil, fmt.Errorf("error bind ldap as %q: %s", this.options.BindDN, err)
+		}
+	}
+
+	request := goldap.NewSearchRequest(this.options.BaseDN, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases,
+		0, 0, false, fmt.Sprintf(this.options.MemberFilter, goldap.EscapeFilter(group)),
+		[]string{this.options.NameAttribute, this.options.EmailAttribute}, nil)
+
+	result, err := conn.Search(request)
+	if err != nil {
+		return nil, fmt.Errorf("error search ldap group %q members: %s", group, err)
+	}
+
+	users := make([]config.User, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		users = append(users, config.User{
+			Name:  entry.GetAttributeValue(this.options.NameAttribute),
+			Email: entry.GetAttributeValue(this.options.EmailAttribute),
+		})
+	}
+	return users, nil
+}
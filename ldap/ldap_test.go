@@ -0,0 +1,173 @@
+package ldap
+
+import (
+	"net"
+	"testing"
+
+	"bobby/config"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	goldap "github.com/go-ldap/ldap/v3"
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+const (
+	testBindDN   = "cn=bobby,dc=example,dc=com"
+	testPassword = "secret"
+	testGroup    = "cn=team-x,ou=groups,dc=example,dc=com"
+)
+
+// fakeServer is a local LDAP stand-in supporting simple bind and search of group members
+type fakeServer struct {
+	listener net.Listener
+	entries  map[string]map[string]string
+}
+
+func newFakeServer(c *C) *fakeServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+
+	server := &fakeServer{
+		listener: listener,
+		entries: map[string]map[string]string{
+			"uid=john,ou=people,dc=example,dc=com": {"cn": "John Doe", "mail": "john.doe@example.com"},
+			"uid=jane,ou=people,dc=example,dc=com": {"cn": "Jane Roe", "mail": "jane.roe@example.com"},
+		},
+	}
+	go server.serve()
+	return server
+}
+
+func (this *fakeServer) URL() string {
+	return "ldap://" + this.listener.Addr().String()
+}
+
+func (this *fakeServer) Close() {
+	this.listener.Close()
+}
+
+func (this *fakeServer) serve() {
+	for {
+		conn, err := this.listener.Accept()
+		if err != nil {
+			return
+		}
+		go this.handle(conn)
+	}
+}
+
+func (this *fakeServer) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+
+		messageID := packet.Children[0].Value.(int64)
+		request := packet.Children[1]
+		switch request.Tag {
+		case goldap.ApplicationBindRequest:
+			code := int64(goldap.LDAPResultSuccess)
+			if request.Children[1].Value != testBindDN || request.Children[2].Data.String() != testPassword {
+				code = goldap.LDAPResultInvalidCredentials
+			}
+			conn.Write(newResult(messageID, goldap.ApplicationBindResponse, code).Bytes())
+		case goldap.ApplicationSearchRequest:
+			filter, _ := goldap.DecompileFilter(request.Children[6])
+			if filter == "(memberOf="+testGroup+")" {
+				for dn, attributes := range this.entries {
+					conn.Write(newEntry(messageID, dn, attributes).Bytes())
+				}
+			}
+			conn.Write(newResult(messageID, goldap.ApplicationSearchResultDone, goldap.LDAPResultSuccess).Bytes())
+		default:
+			return
+		}
+	}
+}
+
+func newMessage(messageID int64, op *ber.Packet) *ber.Packet {
+	message := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Message")
+	message.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+	message.AppendChild(op)
+	return message
+}
+
+func newResult(messageID int64, tag ber.Tag, code int64) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "Result Code"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return newMessage(messageID, op)
+}
+
+func newEntry(messageID int64, dn string, attributes map[string]string) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, goldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "Object Name"))
+
+	list := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for name, value := range attributes {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		attribute.AppendChild(values)
+		list.AppendChild(attribute)
+	}
+	op.AppendChild(list)
+	return newMessage(messageID, op)
+}
+
+type ClientTestSuite struct {
+	server *fakeServer
+}
+
+var _ = Suite(&ClientTestSuite{})
+
+func (suite *ClientTestSuite) SetUpTest(c *C) {
+	suite.server = newFakeServer(c)
+}
+
+func (suite *ClientTestSuite) TearDownTest(c *C) {
+	suite.server.Close()
+}
+
+func (suite *ClientTestSuite) newClient(password string) *Client {
+	return NewClient(Options{
+		URL:            suite.server.URL(),
+		BindDN:         testBindDN,
+		BindPassword:   password,
+		BaseDN:         "dc=example,dc=com",
+		MemberFilter:   "(memberOf=%s)",
+		NameAttribute:  "cn",
+		EmailAttribute: "mail",
+	})
+}
+
+func (suite *ClientTestSuite) TestGetGroupMembers(c *C) {
+	users, err := suite.newClient(testPassword).GetGroupMembers(testGroup)
+	c.Assert(err, IsNil)
+	c.Assert(users, HasLen, 2)
+
+	byEmail := make(map[string]config.User, len(users))
+	for _, user := range users {
+		byEmail[user.Email] = user
+	}
+	c.Assert(byEmail["john.doe@example.com"], Equals, config.User{Name: "John Doe", Email: "john.doe@example.com"})
+	c.Assert(byEmail["jane.roe@example.com"], Equals, config.User{Name: "Jane Roe", Email: "jane.roe@example.com"})
+
+	users, err = suite.newClient(testPassword).GetGroupMembers("cn=unknown,ou=groups,dc=example,dc=com")
+	c.Assert(err, IsNil)
+	c.Assert(users, HasLen, 0)
+}
+
+func (suite *ClientTestSuite) TestInvalidCredentials(c *C) {
+	_, err := suite.newClient("wrong").GetGroupMembers(testGroup)
+	c.Assert(err, ErrorMatches, `error bind ldap as "cn=bobby,dc=example,dc=com": .*Invalid Credentials.*`)
+}
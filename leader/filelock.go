@@ -0,0 +1,66 @@
+//go:build !windows
+// +build !windows
+
+package leader
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// FileLock is an exclusive flock of a file. The lock is held until Release
+// or until the process exits, so it never expires while the leader is alive.
+type FileLock struct {
+	filename string
+
+	lock sync.Mutex
+	file *os.File
+}
+
+func NewFileLock(filename string) *FileLock {
+	return &FileLock{filename: filename}
+}
+
+func (this *FileLock) TryAcquire(now time.Time) (bool, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.file != nil {
+		return true, nil
+	}
+
+	file, err := os.OpenFile(this.filename, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return false, err
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if err == syscall.EWOULDBLOCK {
+			return false, nil
+		}
+		return false, err
+	}
+
+	this.file = file
+	return true, nil
+}
+
+func (this *FileLock) Release() error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.file == nil {
+		return nil
+	}
+
+	file := this.file
+	this.file = nil
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_UN); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,23 @@
+package leader
+
+import (
+	"fmt"
+	"time"
+)
+
+// FileLock isn't supported on windows, use lease lock instead
+type FileLock struct {
+	filename string
+}
+
+func NewFileLock(filename string) *FileLock {
+	return &FileLock{filename: filename}
+}
+
+func (this *FileLock) TryAcquire(now time.Time) (bool, error) {
+	return false, fmt.Errorf("file lock %q isn't supported on windows", this.filename)
+}
+
+func (this *FileLock) Release() error {
+	return nil
+}
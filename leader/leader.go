@@ -0,0 +1,117 @@
+package leader
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// ILock is a lock backend. TryAcquire takes or renews the lock without blocking.
+type ILock interface {
+	TryAcquire(now time.Time) (bool, error)
+	Release() error
+}
+
+// Elector keeps trying to take the lock and reports whether this instance is the leader.
+// The lock is renewed every interval, the instance steps down when renewal fails.
+type Elector struct {
+	lock     ILock
+	interval time.Duration
+	now      func() time.Time
+
+	mutex     sync.Mutex
+	leader    bool
+	onElected []func()
+
+	stop    chan struct{}
+	stopped sync.Once
+	done    chan struct{}
+}
+
+func NewElector(lock ILock, interval time.Duration) *Elector {
+	return &Elector{
+		lock:     lock,
+		interval: interval,
+		now:      time.Now,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// OnElected adds callback called when the instance becomes the leader
+func (this *Elector) OnElected(callback func()) {
+	this.mutex.Lock()
+	this.onElected = append(this.onElected, callback)
+	this.mutex.Unlock()
+}
+
+func (this *Elector) IsLeader() bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.leader
+}
+
+// Campaign tries to take or renew the lock once
+func (this *Elector) Campaign() {
+	acquired, err := this.lock.TryAcquire(this.now())
+	if err != nil {
+		log.Printf("leader: error acquire lock: %s", err)
+	}
+
+	this.mutex.Lock()
+	elected := acquired && !this.leader
+	if this.leader && !acquired {
+		log.Printf("leader: lost leadership")
+	}
+	this.leader = acquired
+	callbacks := append([]func(){}, this.onElected...)
+	this.mutex.Unlock()
+
+	if elected {
+		log.Printf("leader: elected")
+		for _, callback := range callbacks {
+			callback()
+		}
+	}
+}
+
+// Run campaigns until Stop is called, then releases the lock
+func (this *Elector) Run() {
+	defer close(this.done)
+
+	ticker := time.NewTicker(this.interval)
+	defer ticker.Stop()
+
+	for {
+		this.Campaign()
+		select {
+		case <-ticker.C:
+		case <-this.stop:
+			this.resign()
+			return
+		}
+	}
+}
+
+// Stop stops Run and waits until the lock is released
+func (this *Elector) Stop() {
+	this.stopped.Do(func() {
+		close(this.stop)
+	})
+	<-this.done
+}
+
+func (this *Elector) resign() {
+	this.mutex.Lock()
+	leader := this.leader
+	this.leader = false
+	this.mutex.Unlock()
+
+	if !leader {
+		return
+	}
+
+	if err := this.lock.Release(); err != nil {
+		log.Printf("leader: error release lock: %s", err)
+	}
+}
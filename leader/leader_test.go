@@ -0,0 +1,85 @@
+package leader
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+type LeaderTestSuite struct{}
+
+var _ = Suite(&LeaderTestSuite{})
+
+type fakeLock struct {
+	acquired bool
+	err      error
+	released int
+}
+
+func (this *fakeLock) TryAcquire(now time.Time) (bool, error) {
+	return this.acquired, this.err
+}
+
+func (this *fakeLock) Release() error {
+	this.released++
+	return nil
+}
+
+func (suite *LeaderTestSuite) TestElector(c *C) {
+	lock := &fakeLock{}
+	elector := NewElector(lock, time.Hour)
+	elected := 0
+	elector.OnElected(func() { elected++ })
+
+	elector.Campaign()
+	c.Assert(elector.IsLeader(), Equals, false)
+
+	lock.acquired = true
+	elector.Campaign()
+	elector.Campaign()
+	c.Assert(elector.IsLeader(), Equals, true)
+	c.Assert(elected, Equals, 1)
+
+	// renewal errors step down
+	lock.acquired, lock.err = false, fmt.Errorf("store is down")
+	elector.Campaign()
+	c.Assert(elector.IsLeader(), Equals, false)
+
+	lock.acquired, lock.err = true, nil
+	go elector.Run()
+	elector.Stop()
+	c.Assert(elector.IsLeader(), Equals, false)
+	c.Assert(elected, Equals, 2)
+	c.Assert(lock.released, Equals, 1)
+}
+
+func (suite *LeaderTestSuite) TestFileLock(c *C) {
+	filename := filepath.Join(c.MkDir(), "bobby.lock")
+	first, second := NewFileLock(filename), NewFileLock(filename)
+
+	acquired, err := first.TryAcquire(time.Now())
+	c.Assert(err, IsNil)
+	c.Assert(acquired, Equals, true)
+
+	acquired, err = second.TryAcquire(time.Now())
+	c.Assert(err, IsNil)
+	c.Assert(acquired, Equals, false)
+
+	acquired, err = first.TryAcquire(time.Now())
+	c.Assert(err, IsNil)
+	c.Assert(acquired, Equals, true)
+
+	c.Assert(first.Release(), IsNil)
+	acquired, err = second.TryAcquire(time.Now())
+	c.Assert(err, IsNil)
+	c.Assert(acquired, Equals, true)
+	c.Assert(second.Release(), IsNil)
+}
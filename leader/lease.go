@@ -0,0 +1,36 @@
+package leader
+
+import (
+	"time"
+)
+
+// ILeaseStore keeps leases in a store shared by instances
+type ILeaseStore interface {
+	AcquireLease(name, owner string, now time.Time, ttl time.Duration) (bool, error)
+	ReleaseLease(name, owner string) error
+}
+
+// LeaseLock is a lease in the store which expires unless renewed within ttl
+type LeaseLock struct {
+	store ILeaseStore
+	name  string
+	owner string
+	ttl   time.Duration
+}
+
+func NewLeaseLock(store ILeaseStore, name, owner string, ttl time.Duration) *LeaseLock {
+	return &LeaseLock{
+		store: store,
+		name:  name,
+		owner: owner,
+		ttl:   ttl,
+	}
+}
+
+func (this *LeaseLock) TryAcquire(now time.Time) (bool, error) {
+	return this.store.AcquireLease(this.name, this.owner, now, this.ttl)
+}
+
+func (this *LeaseLock) Release() error {
+	return this.store.ReleaseLease(this.name, this.owner)
+}
@@ -0,0 +1,92 @@
+package lifecycle
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrTimeout is returned by stop functions which didn't finish in time
+var ErrTimeout = errors.New("timeout exceeded")
+
+type hook struct {
+	name string
+	stop func(timeout time.Duration) error
+}
+
+// Lifecycle stops components of the bot on shutdown. Components are stopped in reverse order
+// of registration within a shared deadline, so request sources stop first and outbound queues last.
+type Lifecycle struct {
+	lock  sync.Mutex
+	hooks []hook
+	now   func() time.Time
+}
+
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{
+		now: time.Now,
+	}
+}
+
+// OnStop registers stop function of a component, it gets the time left till the shutdown deadline
+func (this *Lifecycle) OnStop(name string, stop func(timeout time.Duration) error) {
+	this.lock.Lock()
+	this.hooks = append(this.hooks, hook{name: name, stop: stop})
+	this.lock.Unlock()
+}
+
+// Stop calls stop functions in reverse order. All of them are called even after the deadline,
+// so resources are released anyway.
+func (this *Lifecycle) Stop(timeout time.Duration) error {
+	this.lock.Lock()
+	hooks := this.hooks
+	this.hooks = nil
+	this.lock.Unlock()
+
+	deadline := this.now().Add(timeout)
+	var problems []string
+	for i := len(hooks) - 1; i >= 0; i-- {
+		left := deadline.Sub(this.now())
+		if left < 0 {
+			left = 0
+		}
+
+		if err := hooks[i].stop(left); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", hooks[i].name, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("error stop %s", strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// Run serves until serve fails or a signal is received, then stops components.
+// It returns process exit code, non-zero if serve failed or components didn't stop cleanly.
+func (this *Lifecycle) Run(serve func() error, signals <-chan os.Signal, timeout time.Duration) int {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- serve()
+	}()
+
+	code := 0
+	select {
+	case sig := <-signals:
+		log.Printf("received %s, shutting down", sig)
+	case err := <-errs:
+		log.Printf("Error ListenAndServe: %q", err.Error())
+		code = 1
+	}
+
+	if err := this.Stop(timeout); err != nil {
+		log.Printf("%s", err)
+		code = 1
+	}
+	log.Printf("stopped")
+	return code
+}
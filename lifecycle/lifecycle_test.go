@@ -0,0 +1,91 @@
+package lifecycle
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+type LifecycleTestSuite struct {
+	now       time.Time
+	lifecycle *Lifecycle
+	stopped   []string
+}
+
+var _ = Suite(&LifecycleTestSuite{})
+
+func (suite *LifecycleTestSuite) SetUpTest(c *C) {
+	suite.now = time.Date(2020, 3, 10, 12, 0, 0, 0, time.Local)
+	suite.stopped = nil
+	suite.lifecycle = NewLifecycle()
+	suite.lifecycle.now = func() time.Time {
+		return suite.now
+	}
+}
+
+// addComponent registers component which takes duration to stop and fails if it gets less time
+func (suite *LifecycleTestSuite) addComponent(name string, duration time.Duration) {
+	suite.lifecycle.OnStop(name, func(timeout time.Duration) error {
+		suite.stopped = append(suite.stopped, fmt.Sprintf("%s %s", name, timeout))
+		if timeout < duration {
+			suite.now = suite.now.Add(timeout)
+			return ErrTimeout
+		}
+		suite.now = suite.now.Add(duration)
+		return nil
+	})
+}
+
+func (suite *LifecycleTestSuite) TestStop(c *C) {
+	suite.addComponent("delivery", time.Second)
+	suite.addComponent("scheduler", 10*time.Second)
+	suite.addComponent("http", time.Second)
+
+	c.Assert(suite.lifecycle.Stop(30*time.Second), IsNil)
+	c.Assert(suite.stopped, DeepEquals, []string{"http 30s", "scheduler 29s", "delivery 19s"})
+}
+
+func (suite *LifecycleTestSuite) TestStopTimeout(c *C) {
+	suite.addComponent("delivery", time.Second)
+	suite.addComponent("scheduler", time.Minute)
+	suite.addComponent("http", time.Second)
+
+	c.Assert(suite.lifecycle.Stop(30*time.Second), ErrorMatches, "error stop scheduler: timeout exceeded, delivery: timeout exceeded")
+	c.Assert(suite.stopped, DeepEquals, []string{"http 30s", "scheduler 29s", "delivery 0s"})
+}
+
+func (suite *LifecycleTestSuite) TestRun(c *C) {
+	suite.addComponent("http", time.Second)
+	signals := make(chan os.Signal, 1)
+	signals <- syscall.SIGTERM
+
+	served := make(chan struct{})
+	code := suite.lifecycle.Run(func() error {
+		<-served
+		return fmt.Errorf("server closed")
+	}, signals, 30*time.Second)
+	close(served)
+
+	c.Assert(code, Equals, 0)
+	c.Assert(suite.stopped, DeepEquals, []string{"http 30s"})
+}
+
+func (suite *LifecycleTestSuite) TestRunListenerFails(c *C) {
+	suite.addComponent("http", time.Second)
+
+	code := suite.lifecycle.Run(func() error {
+		return fmt.Errorf("listen tcp :80: bind: permission denied")
+	}, make(chan os.Signal), 30*time.Second)
+
+	c.Assert(code, Equals, 1)
+	c.Assert(suite.stopped, DeepEquals, []string{"http 30s"})
+}
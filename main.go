@@ -1,64 +1,104 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"bobby/cache"
 	"bobby/config"
 	"bobby/cron"
+	"bobby/delivery"
+	"bobby/email"
+	"bobby/health"
 	"bobby/jira"
+	"bobby/ldap"
+	"bobby/leader"
+	"bobby/lifecycle"
+	"bobby/mattermost"
 	"bobby/messengers/duty"
+	"bobby/messengers/oncall"
 	"bobby/messengers/timelogs"
+	"bobby/metrics"
+	"bobby/msteams"
+	"bobby/notify"
 	"bobby/opsgenie"
 	"bobby/processors"
+	"bobby/roster"
 	"bobby/slack"
+	"bobby/utils"
 )
 
-const (
-	DefaultCacheSize = 256
-)
-
-func initCommandProcessManager(cfg *config.Config, slackClient processors.ISlackPostponedClient, cache processors.ICache,
-	dutyProvider processors.IDutyProvider, jiraClient processors.IJiraClient) *processors.CommandProcessManager {
-	commandProcessManager := processors.NewCommandProcessManager()
-	commandProcessManager.AddCommandProcessor(cfg.DutyCommand.Name, &processors.PostponedCommandProcessor{
-		Token:         cfg.DutyCommand.Token,
-		SlackClient:   slackClient,
-		Cache:         cache,
-		CacheDuration: cfg.DutyCommand.CacheTTL,
-		Processor: &processors.DutyCommandProcessor{
-			DutyProvider: dutyProvider,
-			ScheduleID:   cfg.DutyCommand.ScheduleID,
-		},
-	})
-
-	usersJiraLogins := make([]string, 0, len(cfg.TimelogsCommand.Team))
-	for _, user := range cfg.TimelogsCommand.Team {
-		usersJiraLogins = append(usersJiraLogins, user.JiraLogin)
+// initCommandProcessors registers commands with non empty names. Every command is routed to the team
+// processor, processors of different teams share the cache.
+func initCommandProcessors(cfg *config.Config, slackClient processors.IPostponedClient, cache processors.ICache,
+	dutyProvider processors.IDutyProvider, jiraClient processors.IJiraClient,
+	userResolver processors.IUserResolver) map[string]processors.ICommandProcessor {
+	commandProcessors := make(map[string]processors.ICommandProcessor, 2)
+	if len(cfg.DutyCommand.Name) > 0 {
+		dutyCommand := &processors.TeamCommandProcessor{Token: cfg.DutyCommand.Token}
+		for _, team := range cfg.GetTeams() {
+			dutyCommand.Teams = append(dutyCommand.Teams, processors.TeamProcessor{
+				Name:    team.Name,
+				Channel: team.Channel,
+				Processor: &processors.PostponedCommandProcessor{
+					Token:              cfg.DutyCommand.Token,
+					Client:             slackClient,
+					Cache:              cache,
+					CacheDuration:      cfg.DutyCommand.CacheTTL,
+					StaleCacheDuration: cfg.DutyCommand.StaleCacheTTL,
+					ErrorCacheDuration: cfg.DutyCommand.ErrorCacheTTL,
+					Processor: &processors.DutyCommandProcessor{
+						DutyProvider: dutyProvider,
+						ScheduleID:   team.ScheduleID,
+					},
+				},
+			})
+		}
+		commandProcessors[cfg.DutyCommand.Name] = dutyCommand
 	}
 
-	commandProcessManager.AddCommandProcessor(cfg.TimelogsCommand.Name, &processors.PostponedCommandProcessor{
-		Token:         cfg.TimelogsCommand.Token,
-		SlackClient:   slackClient,
-		Cache:         cache,
-		CacheDuration: cfg.TimelogsCommand.CacheTTL,
-		Processor: &processors.TimeLogsCommandProcessor{
-			JiraClient:       jiraClient,
-			Users:            usersJiraLogins,
-			MinimumTimeSpent: cfg.TimelogsCommand.MinimumTimeSpent,
-		},
-	})
-	return commandProcessManager
+	if len(cfg.TimelogsCommand.Name) > 0 {
+		timelogsCommand := &processors.TeamCommandProcessor{Token: cfg.TimelogsCommand.Token}
+		for _, team := range cfg.GetTeams() {
+			timelogsCommand.Teams = append(timelogsCommand.Teams, processors.TeamProcessor{
+				Name:    team.Name,
+				Channel: team.Channel,
+				Processor: &processors.PostponedCommandProcessor{
+					Token:              cfg.TimelogsCommand.Token,
+					Client:             slackClient,
+					Cache:              cache,
+					CacheDuration:      cfg.TimelogsCommand.CacheTTL,
+					StaleCacheDuration: cfg.TimelogsCommand.StaleCacheTTL,
+					ErrorCacheDuration: cfg.TimelogsCommand.ErrorCacheTTL,
+					Processor: &processors.TimeLogsCommandProcessor{
+						JiraClient:       jiraClient,
+						Team:             team.Members,
+						MinimumTimeSpent: team.MinimumTimeSpent,
+						UserResolver:     userResolver,
+					},
+				},
+			})
+		}
+		commandProcessors[cfg.TimelogsCommand.Name] = timelogsCommand
+	}
+	return commandProcessors
 }
 
 func initHandlers(mux *http.ServeMux, commandProcessManager *processors.CommandProcessManager) {
 	mux.HandleFunc("/api/v1", func(w http.ResponseWriter, r *http.Request) {
 		command := processors.UnmarshalCommand(r)
-		log.Printf("command: %+v\n", command)
+		log.Printf("command: %s\n", command)
 		result, err := commandProcessManager.ProcessCommand(command)
 		if err != nil {
 			fmt.Fprintf(w, "Error: %q", err.Error())
@@ -71,54 +111,637 @@ func initHandlers(mux *http.ServeMux, commandProcessManager *processors.CommandP
 	})
 }
 
-func run(addr string, mux *http.ServeMux) {
-	if err := http.ListenAndServe(addr, mux); err != nil {
-		log.Printf("Error ListenAndServe: %q", err.Error())
+// initAdminHandlers mounts admin api. It is disabled without admin token.
+func initAdminHandlers(cfg *config.Config, mux *http.ServeMux, deliveryQueue *delivery.Queue, cacheManager *cache.Cache,
+	rosterSyncer *roster.Syncer) {
+	if len(cfg.Admin.Token) == 0 {
+		log.Printf("admin api is disabled: admin token is empty")
+		return
+	}
+
+	adminMux := http.NewServeMux()
+	adminMux.Handle("/admin/delivery", deliveryQueue)
+	adminMux.Handle("/admin/cache", cacheManager)
+	adminMux.Handle("/admin/jobs", cron.Handler())
+	adminMux.Handle("/admin/jobs/", cron.Handler())
+	adminMux.Handle("/admin/roster", rosterSyncer)
+	mux.Handle("/admin/", utils.RequireToken(cfg.Admin.Token, adminMux))
+}
+
+func runUserResolver(cfg *config.Config, slackClient *slack.Client) *slack.UserResolver {
+	userResolver := slack.NewUserResolver(slackClient, cfg.GetMembers())
+	if !cfg.UsesSlack() {
+		return userResolver
+	}
+
+	if err := userResolver.Refresh(); err != nil {
+		log.Printf("Error resolve slack users: %s", err.Error())
+	}
+	go userResolver.Run(cfg.Slack.UsersRefreshInterval)
+	return userResolver
+}
+
+// notifierFactory makes daily message notifiers of a team. Slack messages go through the shared
+// delivery queue, each mattermost and msteams webhook gets its own queue on first use.
+type notifierFactory struct {
+	slackQueue   *delivery.Queue
+	userResolver *slack.UserResolver
+	options      delivery.Options
+	lock         sync.Mutex
+	queues       map[string]*delivery.Queue
+}
+
+func initNotifierFactory(cfg *config.Config, slackQueue *delivery.Queue, userResolver *slack.UserResolver) (*notifierFactory, error) {
+	factory := &notifierFactory{
+		slackQueue:   slackQueue,
+		userResolver: userResolver,
+		options: delivery.Options{
+			Concurrency:    cfg.Delivery.Concurrency,
+			MaxAttempts:    cfg.Delivery.MaxAttempts,
+			DeadLetterFile: cfg.Delivery.DeadLetterFile,
+		},
+		queues: make(map[string]*delivery.Queue),
+	}
+
+	// queues of configured teams are created at startup to fail early
+	for _, team := range cfg.GetTeams() {
+		if !team.SendsDailyMessages() {
+			continue
+		}
+		if _, err := factory.newNotifier(cfg, team); err != nil {
+			return nil, err
+		}
+	}
+	return factory, nil
+}
+
+func (this *notifierFactory) webhookQueue(notifier, webhookURL string) (*delivery.Queue, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	key := notifier + " " + webhookURL
+	if queue, found := this.queues[key]; found {
+		return queue, nil
+	}
+
+	var sender delivery.ISender
+	switch notifier {
+	case config.NotifierMattermost:
+		sender = mattermost.NewClient(webhookURL)
+	case config.NotifierMSTeams:
+		sender = msteams.NewClient(webhookURL)
+	default:
+		return nil, fmt.Errorf("notifier %s has no webhook", notifier)
+	}
+
+	queue, err := delivery.NewQueue(sender, this.options)
+	if err != nil {
+		return nil, err
+	}
+	this.queues[key] = queue
+	return queue, nil
+}
+
+// newNotifier makes chat notifier of the team posting to team channel or to the default one.
+// Microsoft Teams webhook is bound to a single channel, so the team posts there.
+func (this *notifierFactory) newNotifier(cfg *config.Config, team config.Team) (notify.INotifier, error) {
+	var notifier notify.INotifier
+	switch name := cfg.GetNotifier(team); name {
+	case config.NotifierMattermost, config.NotifierMSTeams:
+		queue, err := this.webhookQueue(name, cfg.GetWebhookURL(team))
+		if err != nil {
+			return nil, err
+		}
+
+		if name == config.NotifierMattermost {
+			notifier = notify.NewMattermostNotifier(queue, cfg.GetChannel(team))
+		} else {
+			notifier = notify.NewMSTeamsNotifier(queue)
+		}
+	default:
+		notifier = notify.NewSlackNotifier(this.slackQueue, this.userResolver, cfg.GetChannel(team))
+	}
+
+	return notifier, nil
+}
+
+// Close flushes webhook queues, the slack queue is closed by its owner
+func (this *notifierFactory) Close(timeout time.Duration) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	var result error
+	deadline := time.Now().Add(timeout)
+	for _, queue := range this.queues {
+		if err := queue.Close(time.Until(deadline)); err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
+
+func initEmailNotifier(cfg *config.Config, team config.Team) notify.INotifier {
+	return notify.NewEmailNotifier(email.NewClient(email.Options{
+		Host:     cfg.Email.Host,
+		Port:     cfg.Email.Port,
+		Username: cfg.Email.Username,
+		Password: cfg.Email.Password,
+		From:     cfg.Email.From,
+		StartTLS: cfg.Email.StartTLS,
+	}), cfg.Email.Recipients, team.Members, cfg.Email.SubjectPrefix)
+}
+
+// teamJobName names cron job of a team. Jobs of the single unnamed team keep their names.
+func teamJobName(name string, team config.Team) string {
+	if len(team.Name) == 0 {
+		return name
 	}
+	return name + ":" + team.Name
 }
 
-func runDailyMessangers(cfg *config.Config, slackClient *slack.Client,
+func runDailyMessangers(cfg *config.Config, notifiers *notifierFactory,
 	dutyProvider processors.IDutyProvider, jiraClient processors.IJiraClient) {
-	if cfg.DutyCommand.Enable {
-		cron.AddJob(cron.EveryWorkingDayAt(cfg.DutyCommand.DailyMessageTime), &duty.DutyDailyMessenger{
-			Config:       cfg,
-			SlackClient:  slackClient,
-			DutyProvider: dutyProvider,
-		})
+	for _, team := range cfg.GetTeams() {
+		var chatNotifier notify.INotifier
+		if team.SendsDailyMessages() {
+			var err error
+			if chatNotifier, err = notifiers.newNotifier(cfg, team); err != nil {
+				log.Printf("Error init notifier of team %q: %s", team.Name, err.Error())
+				continue
+			}
+		}
+
+		// duty roster is emailed daily, time logs are summarized weekly
+		var emailNotifier notify.INotifier
+		if cfg.Email.Enable {
+			emailNotifier = initEmailNotifier(cfg, team)
+		}
+
+		if team.DutyMessage.Enable {
+			dutyNotifiers := []notify.INotifier{chatNotifier}
+			if emailNotifier != nil {
+				dutyNotifiers = append(dutyNotifiers, emailNotifier)
+			}
+			cron.AddJob(teamJobName("duty-daily-message", team), team.DutyMessage.DailyMessageSchedule, &duty.DutyDailyMessenger{
+				Team:         team,
+				Notifiers:    dutyNotifiers,
+				DutyProvider: dutyProvider,
+			})
+		} else {
+			cron.RemoveJob(teamJobName("duty-daily-message", team))
+		}
+
+		if team.TimelogsMessage.Enable {
+			cron.AddJob(teamJobName("timelogs-daily-message", team), team.TimelogsMessage.DailyMessageSchedule, &timelogs.TimelogsDailyMessenger{
+				Team:       team,
+				Notifiers:  []notify.INotifier{chatNotifier},
+				JiraClient: jiraClient,
+			})
+		} else {
+			cron.RemoveJob(teamJobName("timelogs-daily-message", team))
+		}
+
+		if team.TimelogsMessage.Enable && emailNotifier != nil {
+			cron.AddJob(teamJobName("timelogs-weekly-email", team), cfg.Email.WeeklyCronSchedule, &timelogs.TimelogsWeeklyMessenger{
+				Team:       team,
+				Notifiers:  []notify.INotifier{emailNotifier},
+				JiraClient: jiraClient,
+			})
+		} else {
+			cron.RemoveJob(teamJobName("timelogs-weekly-email", team))
+		}
+	}
+}
+
+// removeTeamJobs removes jobs of teams which are not in the new config
+func removeTeamJobs(old, cfg *config.Config) {
+	teams := make(map[string]bool)
+	for _, team := range cfg.GetTeams() {
+		teams[team.Name] = true
+	}
+
+	for _, team := range old.GetTeams() {
+		if teams[team.Name] {
+			continue
+		}
+
+		for _, name := range []string{"duty-daily-message", "timelogs-daily-message", "timelogs-weekly-email",
+			"duty-cache-warmer", "timelogs-cache-warmer"} {
+			cron.RemoveJob(teamJobName(name, team))
+		}
+	}
+}
+
+func initCronHistory(cfg *config.Config) error {
+	if len(cfg.Scheduler.HistoryFile) == 0 {
+		return nil
+	}
+
+	history, err := cron.NewFileHistory(cfg.Scheduler.HistoryFile)
+	if err != nil {
+		return err
+	}
+	cron.SetHistory(history, cfg.Scheduler.CatchUpGrace)
+	return nil
+}
+
+func initCacheStore(cfg *config.Config) (*cache.BoltStore, error) {
+	if cfg.Cache.Backend != config.CacheBackendFile {
+		return nil, nil
+	}
+	return cache.NewBoltStore(cfg.Cache.File)
+}
+
+func initCache(cfg *config.Config, store *cache.BoltStore) *cache.Cache {
+	options := cache.Options{
+		MaxEntries: cfg.Cache.MaxEntries,
+		MaxBytes:   cfg.Cache.MaxBytes,
+	}
+
+	if store != nil {
+		options.Store = store
+	}
+	return cache.NewCache(options)
+}
+
+// runLeaderElection makes scheduler run jobs only on the leader instance.
+// Store lease is renewed every third of its ttl.
+func runLeaderElection(cfg *config.Config) *leader.Elector {
+	var elector *leader.Elector
+	switch cfg.Scheduler.LeaderElection {
+	case config.LeaderElectionFile:
+		elector = leader.NewElector(leader.NewFileLock(cfg.Scheduler.LockFile), cfg.Scheduler.LockInterval)
+	case config.LeaderElectionStore:
+		lock := leader.NewLeaseLock(cache.NewBoltLeaseStore(cfg.Scheduler.LeaseFile), "scheduler",
+			cfg.Scheduler.InstanceID, cfg.Scheduler.LeaseTTL)
+		elector = leader.NewElector(lock, cfg.Scheduler.LeaseTTL/3)
+	default:
+		return nil
+	}
+
+	elector.OnElected(cron.TakeOver)
+	cron.SetLeader(elector)
+	go elector.Run()
+	return elector
+}
+
+// runCacheWarmer computes default command results at startup and shortly before daily messages
+func runCacheWarmer(cfg *config.Config, commandProcessManager *processors.CommandProcessManager) {
+	if !cfg.Cache.Prewarm {
+		return
+	}
+
+	go (&processors.CacheWarmer{Manager: commandProcessManager}).Run(time.Now())
+	scheduleCacheWarmer(cfg, commandProcessManager)
+}
+
+func scheduleCacheWarmer(cfg *config.Config, commandProcessManager *processors.CommandProcessManager) {
+	if !cfg.Cache.Prewarm {
+		return
+	}
+
+	// every job warms only the command and the team of its daily message
+	for _, team := range cfg.GetTeams() {
+		if team.DutyMessage.Enable && len(cfg.DutyCommand.Name) > 0 {
+			warmer := &processors.CacheWarmer{Manager: commandProcessManager, Command: cfg.DutyCommand.Name, Team: team.Name}
+			cron.AddJob(teamJobName("duty-cache-warmer", team), cron.Before(team.DutyMessage.DailyMessageSchedule, cfg.Cache.PrewarmLead), warmer)
+		} else {
+			cron.RemoveJob(teamJobName("duty-cache-warmer", team))
+		}
+
+		if team.TimelogsMessage.Enable && len(cfg.TimelogsCommand.Name) > 0 {
+			warmer := &processors.CacheWarmer{Manager: commandProcessManager, Command: cfg.TimelogsCommand.Name, Team: team.Name}
+			cron.AddJob(teamJobName("timelogs-cache-warmer", team), cron.Before(team.TimelogsMessage.DailyMessageSchedule, cfg.Cache.PrewarmLead), warmer)
+		} else {
+			cron.RemoveJob(teamJobName("timelogs-cache-warmer", team))
+		}
+	}
+}
+
+// readinessCacheTTL is how long readiness check results are reused by probes
+const readinessCacheTTL = 30 * time.Second
+
+// initHealthHandlers mounts liveness and readiness probes. Readiness checks only upstreams
+// used by enabled features.
+func initHealthHandlers(cfg *config.Config, mux *http.ServeMux, slackClient *slack.Client, jiraClient *jira.Client,
+	dutyProvider *opsgenie.OpsgenieClient) {
+	mux.Handle("/healthz", health.NewProbe(0, health.Check{Name: "scheduler", Check: cron.Alive}))
+
+	var checks []health.Check
+	if cfg.UsesSlack() {
+		checks = append(checks, health.Check{Name: "slack", Check: slackClient.CheckAuth})
+	}
+
+	usesJira := cfg.UsesTimelogs()
+	for _, team := range cfg.GetTeams() {
+		usesJira = usesJira || (cfg.SyncsRoster(team.Roster) && len(team.Roster.JiraGroup) > 0)
+	}
+	if usesJira {
+		checks = append(checks, health.Check{Name: "jira", Check: jiraClient.CheckAuth})
+	}
+
+	if cfg.UsesDuty() {
+		checks = append(checks, health.Check{Name: "opsgenie", Check: dutyProvider.CheckAuth})
+	}
+	mux.Handle("/readyz", health.NewProbe(readinessCacheTTL, checks...))
+}
+
+// restartOnlySections are config sections of clients, transports and storages created at startup
+var restartOnlySections = []string{"main", "admin", "slack", "notifier", "mattermost", "msteams", "jira", "opsgenie",
+	"delivery", "cache", "scheduler", "oncall-sync", "roster-sync"}
+
+func warnRestartRequired(old, cfg *config.Config) {
+	for _, change := range config.Diff(old, cfg) {
+		for _, section := range restartOnlySections {
+			if strings.HasPrefix(change, section+".") || strings.HasPrefix(change, section+":") {
+				log.Printf("config change is applied on restart only: %s", strings.SplitN(change, ":", 2)[0])
+			}
+		}
+	}
+}
+
+// runConfigReloader reloads config on SIGHUP and on config file change
+func runConfigReloader(configFilename string, cfg *config.Config, apply func(old, cfg *config.Config)) *config.Reloader {
+	reloader := config.NewReloader(configFilename, cfg)
+	reloader.OnReload(apply)
+	reloader.OnReload(warnRestartRequired)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go reloader.Run(signals)
+	return reloader
+}
+
+func runOnCallSync(cfg *config.Config, slackClient *slack.Client, alertSender oncall.IAlertSender,
+	userResolver *slack.UserResolver, dutyProvider processors.IDutyProvider, elector *leader.Elector) *oncall.OnCallSyncer {
+	if !cfg.OnCallSync.Enable {
+		return nil
+	}
+
+	syncer := &oncall.OnCallSyncer{
+		Config:       cfg,
+		SlackClient:  slackClient,
+		AlertSender:  alertSender,
+		DutyProvider: dutyProvider,
+		UserResolver: userResolver,
+	}
+	if elector != nil {
+		syncer.Leader = elector
+	}
+	go syncer.Run()
+	return syncer
+}
+
+// rosterTeams makes teams synced from their roster groups. Names of synced users come from
+// configured members first, then from LDAP, Jira and Slack.
+func rosterTeams(cfg *config.Config, slackClient *slack.Client, jiraClient *jira.Client) []roster.Team {
+	if !cfg.RosterSync.Enable {
+		return nil
+	}
+
+	ldapClient := ldap.NewClient(ldap.Options{
+		URL:            cfg.LDAP.URL,
+		BindDN:         cfg.LDAP.BindDN,
+		BindPassword:   cfg.LDAP.BindPassword,
+		BaseDN:         cfg.LDAP.BaseDN,
+		MemberFilter:   cfg.LDAP.MemberFilter,
+		NameAttribute:  cfg.LDAP.NameAttribute,
+		EmailAttribute: cfg.LDAP.EmailAttribute,
+	})
+
+	var teams []roster.Team
+	for _, team := range cfg.GetTeams() {
+		if team.Roster.IsEmpty() {
+			continue
+		}
+
+		rosterTeam := roster.Team{
+			Name:       team.Name,
+			Members:    team.Members,
+			ScheduleID: team.ScheduleID,
+		}
+
+		if len(team.Roster.LDAPGroup) > 0 {
+			rosterTeam.Sources = append(rosterTeam.Sources, roster.Source{Name: roster.SourceLDAP, Group: team.Roster.LDAPGroup, Directory: ldapClient})
+		}
+
+		if len(team.Roster.JiraGroup) > 0 {
+			rosterTeam.Sources = append(rosterTeam.Sources, roster.Source{Name: roster.SourceJira, Group: team.Roster.JiraGroup, Directory: jiraClient})
+		}
+
+		if len(team.Roster.SlackUserGroupID) > 0 {
+			rosterTeam.Sources = append(rosterTeam.Sources, roster.Source{Name: roster.SourceSlack, Group: team.Roster.SlackUserGroupID, Directory: slackClient})
+		}
+		teams = append(teams, rosterTeam)
 	}
+	return teams
+}
+
+func runRosterSync(cfg *config.Config, rosterSyncer *roster.Syncer) {
+	if !cfg.RosterSync.Enable {
+		return
+	}
+	go rosterSyncer.Run(cfg.RosterSync.RefreshInterval)
+}
 
-	if cfg.TimelogsCommand.Enable {
-		cron.AddJob(cron.EveryWorkingDayAt(cfg.TimelogsCommand.DailyMessageTime), &timelogs.TimelogsDailyMessenger{
-			Config:      cfg,
-			SlackClient: slackClient,
-			JiraClient:  jiraClient,
+// stopOnShutdown registers components to stop on shutdown. Request sources are stopped first,
+// then postponed commands and running jobs are waited for, outbound messages are flushed last.
+func stopOnShutdown(lc *lifecycle.Lifecycle, deliveryQueue *delivery.Queue, notifiers *notifierFactory,
+	cacheManager *cache.Cache, elector *leader.Elector) {
+	lc.OnStop("delivery", func(timeout time.Duration) error {
+		deadline := time.Now().Add(timeout)
+		if err := notifiers.Close(timeout); err != nil {
+			return err
+		}
+		return deliveryQueue.Close(time.Until(deadline))
+	})
+	lc.OnStop("cache", func(timeout time.Duration) error {
+		return cacheManager.Close()
+	})
+	if elector != nil {
+		lc.OnStop("leader election", func(timeout time.Duration) error {
+			elector.Stop()
+			return nil
 		})
 	}
+	lc.OnStop("scheduler", func(timeout time.Duration) error {
+		cron.Stop()
+		if !cron.Wait(timeout) {
+			return lifecycle.ErrTimeout
+		}
+		return nil
+	})
+	lc.OnStop("postponed commands", func(timeout time.Duration) error {
+		if !processors.Wait(timeout) {
+			return lifecycle.ErrTimeout
+		}
+		return nil
+	})
+}
+
+// runValidate checks config and prints all problems, it is used in CI: bobby validate -config conf.yaml
+func runValidate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	configFilename := flags.String("config", "conf.yaml", "config file (yaml)")
+	flags.Parse(args)
+
+	if _, err := config.ParseConfig(*configFilename); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", *configFilename, err.Error())
+		return 1
+	}
+	fmt.Printf("%s: config is valid\n", *configFilename)
+	return 0
+}
 
-	go cron.Run()
+func encryptConfig(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := config.Encrypt(data)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(encrypted)
+	return err
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(runValidate(os.Args[2:]))
+	}
+
 	var configFilename string
-	flag.StringVar(&configFilename, "config", "conf.yaml", "config file (yaml)")
+	var encrypt bool
+	flag.StringVar(&configFilename, "config", "conf.yaml", "config file (yaml), files with .enc suffix are decrypted with "+config.KeyEnv)
+	flag.BoolVar(&encrypt, "encrypt", false, "encrypt config file with "+config.KeyEnv+" key to stdout and exit")
 	flag.Parse()
 
+	if encrypt {
+		if err := encryptConfig(configFilename); err != nil {
+			log.Printf("Error encrypt config file %q: %s", configFilename, err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
 	cfg, err := config.ParseConfig(configFilename)
 	if err != nil {
 		log.Printf("Error parse config file %q: %s", configFilename, err.Error())
-		return
+		os.Exit(1)
 	}
 
 	slackClient := slack.NewClient(cfg.Slack.Token)
-	cacheManager := cache.NewCache(DefaultCacheSize)
+	deliveryQueue, err := delivery.NewQueue(slackClient, delivery.Options{
+		Concurrency:    cfg.Delivery.Concurrency,
+		MaxAttempts:    cfg.Delivery.MaxAttempts,
+		DeadLetterFile: cfg.Delivery.DeadLetterFile,
+	})
+	if err != nil {
+		log.Printf("Error init delivery queue: %s", err.Error())
+		os.Exit(1)
+	}
+
+	cacheStore, err := initCacheStore(cfg)
+	if err != nil {
+		log.Printf("Error init cache: %s", err.Error())
+		os.Exit(1)
+	}
+	cacheManager := initCache(cfg, cacheStore)
+	go cacheManager.Run(cfg.Cache.SweepInterval)
 	jiraClient := jira.NewClient(cfg.Jira.Token)
 
 	dutyProvider := opsgenie.NewOpsgenieClient(cfg.Opsgenie.Token)
 
-	runDailyMessangers(cfg, slackClient, dutyProvider, jiraClient)
+	userResolver := runUserResolver(cfg, slackClient)
+
+	notifiers, err := initNotifierFactory(cfg, deliveryQueue, userResolver)
+	if err != nil {
+		log.Printf("Error init notifier: %s", err.Error())
+		os.Exit(1)
+	}
+
+	if err := initCronHistory(cfg); err != nil {
+		log.Printf("Error init cron history: %s", err.Error())
+		os.Exit(1)
+	}
+	elector := runLeaderElection(cfg)
+	lc := lifecycle.NewLifecycle()
+	stopOnShutdown(lc, deliveryQueue, notifiers, cacheManager, elector)
+
+	rosterSyncer := roster.NewSyncer(dutyProvider)
+	rosterSyncer.SetTeams(rosterTeams(cfg, slackClient, jiraClient))
+
+	runDailyMessangers(cfg, notifiers, dutyProvider, jiraClient)
+	onCallSyncer := runOnCallSync(cfg, slackClient, deliveryQueue, userResolver, dutyProvider, elector)
 
 	mux := http.NewServeMux()
-	commandProcessManager := initCommandProcessManager(cfg, slackClient, cacheManager, dutyProvider, jiraClient)
-	initHandlers(mux, commandProcessManager)
-	run(net.JoinHostPort(cfg.Main.Host, cfg.Main.Port), mux)
+	mux.Handle("/metrics", metrics.Handler())
+	initHealthHandlers(cfg, mux, slackClient, jiraClient, dutyProvider)
+	initAdminHandlers(cfg, mux, deliveryQueue, cacheManager, rosterSyncer)
+	commandProcessManager := processors.NewCommandProcessManager()
+	commandProcessManager.SetCommandProcessors(initCommandProcessors(cfg, deliveryQueue, cacheManager, dutyProvider, jiraClient, userResolver))
+	runCacheWarmer(cfg, commandProcessManager)
+	go cron.Run()
+
+	// apply updates commands and jobs on config reload and on roster change
+	var applyLock sync.Mutex
+	apply := func(old, cfg *config.Config) {
+		applyLock.Lock()
+		defer applyLock.Unlock()
+
+		cfg = cfg.WithMembers(rosterSyncer.Members())
+		commandProcessManager.SetCommandProcessors(initCommandProcessors(cfg, deliveryQueue, cacheManager, dutyProvider, jiraClient, userResolver))
+		removeTeamJobs(old, cfg)
+		runDailyMessangers(cfg, notifiers, dutyProvider, jiraClient)
+		scheduleCacheWarmer(cfg, commandProcessManager)
+
+		if onCallSyncer != nil {
+			onCallSyncer.SetConfig(cfg)
+		}
+
+		if cfg.UsesSlack() {
+			if err := userResolver.SetUsers(cfg.GetMembers()); err != nil {
+				log.Printf("Error resolve slack users: %s", err.Error())
+			}
+		}
+	}
+
+	reloader := runConfigReloader(configFilename, cfg, func(old, cfg *config.Config) {
+		rosterSyncer.SetTeams(rosterTeams(cfg, slackClient, jiraClient))
+		apply(old, cfg)
+	})
+	rosterSyncer.OnChange(func() {
+		cfg := reloader.Config()
+		apply(cfg, cfg)
+	})
+	runRosterSync(cfg, rosterSyncer)
+
+	if cfg.Slack.Transport == config.TransportSocketMode {
+		socketModeClient := slack.NewSocketModeClient(cfg.Slack.AppToken, &socketModeHandler{
+			slackClient:           deliveryQueue,
+			commandProcessManager: commandProcessManager,
+		})
+		go socketModeClient.Run()
+		lc.OnStop("socket mode", func(timeout time.Duration) error {
+			return socketModeClient.Stop(timeout)
+		})
+	} else {
+		initHandlers(mux, commandProcessManager)
+	}
+
+	server := &http.Server{
+		Addr:    net.JoinHostPort(cfg.Main.Host, cfg.Main.Port),
+		Handler: mux,
+	}
+	lc.OnStop("http", func(timeout time.Duration) error {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return server.Shutdown(ctx)
+	})
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	os.Exit(lc.Run(server.ListenAndServe, signals, cfg.Main.ShutdownTimeout))
 }
@@ -0,0 +1,73 @@
+package mattermost
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"bobby/metrics"
+)
+
+const (
+	botUsername = "BOB API BOT"
+)
+
+// Client posts messages to mattermost incoming webhook.
+// Slash commands are handled by the same /api/v1 endpoint as slack ones
+// because mattermost posts them with slack compatible fields.
+type Client struct {
+	webhookURL string
+	httpClient *http.Client
+}
+
+func NewClient(webhookURL string) *Client {
+	return &Client{
+		webhookURL: webhookURL,
+		httpClient: metrics.NewHTTPClient(metrics.UpstreamMattermost),
+	}
+}
+
+type webhookPayload struct {
+	Text     string `json:"text"`
+	Channel  string `json:"channel,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+func (this *Client) SendMessage(channel, text string) error {
+	return this.post(this.webhookURL, &webhookPayload{
+		Text:     text,
+		Channel:  channel,
+		Username: botUsername,
+	})
+}
+
+// SendDirectMessage sends text to user. Incoming webhooks deliver messages
+// to "@login" channel as direct messages.
+func (this *Client) SendDirectMessage(login, text string) error {
+	return this.SendMessage("@"+login, text)
+}
+
+func (this *Client) SendPostponedMessage(responseURL, text string) error {
+	return this.post(responseURL, &webhookPayload{
+		Text: text,
+	})
+}
+
+func (this *Client) post(url string, payload *webhookPayload) error {
+	requestBody, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := this.httpClient.Post(url, "application/json", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("mattermost: http status: %s", resp.Status)
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"bobby/config"
+	"bobby/notify"
 	"bobby/opsgenie"
 	"bobby/utils"
 )
@@ -18,34 +19,30 @@ const (
 	aproxMessageLength = 128
 )
 
-type ISlackClient interface {
-	SendMessage(channelID, text string) error
-}
-
 type IDutyProvider interface {
 	GetUsersOnDutyForDate(from, to time.Time, scheduleID string) ([]opsgenie.UserOnDuty, error)
 }
 
+// DutyDailyMessenger posts team duties and notifies team members about their duties
 type DutyDailyMessenger struct {
-	Config       *config.Config
-	SlackClient  ISlackClient
+	Team         config.Team
+	Notifiers    []notify.INotifier
 	DutyProvider IDutyProvider
 
 	usersByName map[string]config.User
 }
 
-func (this *DutyDailyMessenger) Run(now time.Time) {
-	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+func (this *DutyDailyMessenger) Run(now time.Time) error {
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 	from, to := dayStart, dayStart.Add(75*time.Hour)
-	usersOnDuty, err := this.DutyProvider.GetUsersOnDutyForDate(from, to, this.Config.DutyCommand.ScheduleID)
+	usersOnDuty, err := this.DutyProvider.GetUsersOnDutyForDate(from, to, this.Team.ScheduleID)
 	if err != nil {
-		log.Printf("error get users on duty: %s", err.Error())
-		return
+		return fmt.Errorf("error get users on duty: %s", err.Error())
 	}
 
 	if len(usersOnDuty) == 0 {
 		log.Printf("no users on duty found")
-		return
+		return nil
 	}
 
 	this.initUserByNameMap()
@@ -54,12 +51,33 @@ func (this *DutyDailyMessenger) Run(now time.Time) {
 
 	this.notifyUsersOnDuty(now, usersOnDutyNext)
 
-	text := this.render(now, userOnDutyNow, usersOnDutyNext)
-	log.Printf("text: %s\n", text)
-
-	if err := this.SlackClient.SendMessage(this.Config.Slack.Channel, text); err != nil {
-		log.Printf("Error send slack message: %s", err)
+	var sendErr error
+	for _, notifier := range this.Notifiers {
+		text := this.render(notifier, userOnDutyNow, usersOnDutyNext)
+		log.Printf("text: %s\n", text)
+
+		if err := notifier.Post(&notify.Message{
+			Emoji: "phone",
+			Title: this.Team.Title("On duty"),
+			Text:  text,
+		}); err != nil {
+			log.Printf("Error send message: %s", err)
+			sendErr = fmt.Errorf("error send message: %s", err)
+		}
 	}
+	return sendErr
+}
+
+// DryRun renders messages for every notifier without sending them
+func (this *DutyDailyMessenger) DryRun(now time.Time) (interface{}, error) {
+	return notify.DryRun(this.Notifiers, func(notifiers []notify.INotifier) error {
+		messenger := &DutyDailyMessenger{
+			Team:         this.Team,
+			Notifiers:    notifiers,
+			DutyProvider: this.DutyProvider,
+		}
+		return messenger.Run(now)
+	})
 }
 
 func (this *DutyDailyMessenger) initUserByNameMap() {
@@ -67,8 +85,8 @@ func (this *DutyDailyMessenger) initUserByNameMap() {
 		return
 	}
 
-	this.usersByName = make(map[string]config.User, len(this.Config.TimelogsCommand.Team))
-	for _, user := range this.Config.TimelogsCommand.Team {
+	this.usersByName = make(map[string]config.User, len(this.Team.Members))
+	for _, user := range this.Team.Members {
 		this.usersByName[user.Name] = user
 	}
 	return
@@ -102,7 +120,7 @@ func (this *DutyDailyMessenger) notifyUsersOnDuty(now time.Time, usersOnDuty []o
 			continue
 		}
 
-		go this.notifyUserOnDuty(user.SlackLogin, message)
+		this.notifyUserOnDuty(user, message)
 	}
 }
 
@@ -122,34 +140,36 @@ func renderPrivateMessage(now time.Time, username string, duties []opsgenie.User
 		strings.Join(msgs, " and "))
 }
 
-func (this *DutyDailyMessenger) notifyUserOnDuty(name, message string) {
-	if err := this.SlackClient.SendMessage(utils.ToSlackUserLogin(name), message); err != nil {
-		log.Printf("send private message error: %s", err.Error())
+func (this *DutyDailyMessenger) notifyUserOnDuty(user config.User, message string) {
+	for _, notifier := range this.Notifiers {
+		if err := notifier.SendDirectMessage(user, &notify.Message{Text: message}); err != nil {
+			log.Printf("send private message error: %s", err.Error())
+		}
 	}
 }
 
-func (this *DutyDailyMessenger) getUserOnDutySlackLoginByName(name string) string {
+func (this *DutyDailyMessenger) getUserOnDutyMentionByName(notifier notify.INotifier, name string) string {
 	if userOnDutyNowConfig, found := this.usersByName[name]; found {
-		return utils.ToSlackUserLogin(userOnDutyNowConfig.SlackLogin)
+		return notifier.Mention(userOnDutyNowConfig)
 	}
 	log.Printf("can't find user by name: %q", name)
 	return name
 }
 
-func (this *DutyDailyMessenger) render(now time.Time, userOnDutyNow opsgenie.UserOnDuty,
+func (this *DutyDailyMessenger) render(notifier notify.INotifier, userOnDutyNow opsgenie.UserOnDuty,
 	usersOnDutyNext []opsgenie.UserOnDuty) string {
 	var buf bytes.Buffer
 	buf.Grow(aproxMessageLength)
 
-	utils.LogIfErr(buf.WriteString(":phone: On duty:\nNow:\n\t"))
-	utils.LogIfErr(buf.WriteString(this.getUserOnDutySlackLoginByName(userOnDutyNow.Name)))
+	utils.LogIfErr(buf.WriteString("Now:\n\t"))
+	utils.LogIfErr(buf.WriteString(this.getUserOnDutyMentionByName(notifier, userOnDutyNow.Name)))
 	utils.LogIfErr(buf.WriteString(" till "))
 	utils.LogIfErr(buf.WriteString(userOnDutyNow.End.Format(timeFormatText)))
 	utils.LogIfErr(buf.WriteString("\nNext:\n"))
 
 	for _, entrie := range usersOnDutyNext {
 		utils.LogIfErr(buf.WriteString("\t"))
-		utils.LogIfErr(buf.WriteString(this.getUserOnDutySlackLoginByName(entrie.Name)))
+		utils.LogIfErr(buf.WriteString(this.getUserOnDutyMentionByName(notifier, entrie.Name)))
 		utils.LogIfErr(buf.WriteString(" from "))
 		utils.LogIfErr(buf.WriteString(entrie.Start.Format(timeFormatText)))
 		utils.LogIfErr(buf.WriteString(" to "))
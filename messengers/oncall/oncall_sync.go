@@ -0,0 +1,216 @@
+package oncall
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"bobby/config"
+	"bobby/opsgenie"
+)
+
+const (
+	timeFormatText = "15:04"
+
+	scheduleLookahead = 75 * time.Hour
+	retryDelay        = time.Minute
+	minSleepDuration  = time.Second
+)
+
+type ISlackClient interface {
+	GetUserGroupMembers(userGroupID string) ([]string, error)
+	UpdateUserGroupMembers(userGroupID string, userIDs []string) error
+	GetChannelTopic(channelID string) (string, error)
+	SetChannelTopic(channelID, topic string) error
+}
+
+// IAlertSender posts alerts, it is the delivery queue so alerts are retried when slack is rate limited
+type IAlertSender interface {
+	SendMessage(channelID, text string) error
+}
+
+type IDutyProvider interface {
+	GetUsersOnDutyForDate(from, to time.Time, scheduleID string) ([]opsgenie.UserOnDuty, error)
+}
+
+type IUserResolver interface {
+	GetUserID(user config.User) (string, bool)
+}
+
+type ILeader interface {
+	IsLeader() bool
+}
+
+// OnCallSyncer keeps slack user group members (and optionally channel topic)
+// in sync with the user on duty. Sync is idempotent: group and topic are updated only when they differ
+// so restarting the bot just brings them to the current state again.
+// With Leader set only the leader instance syncs, so alerts aren't posted by every replica.
+type OnCallSyncer struct {
+	Config       *config.Config
+	SlackClient  ISlackClient
+	AlertSender  IAlertSender
+	DutyProvider IDutyProvider
+	UserResolver IUserResolver
+	Leader       ILeader
+
+	lock      sync.Mutex
+	lastAlert string
+}
+
+// SetConfig replaces config on reload and on roster change, the next sync uses it
+func (this *OnCallSyncer) SetConfig(cfg *config.Config) {
+	this.lock.Lock()
+	this.Config = cfg
+	this.lock.Unlock()
+}
+
+func (this *OnCallSyncer) getConfig() *config.Config {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.Config
+}
+
+// Run syncs user group at every duty boundary. It never returns.
+func (this *OnCallSyncer) Run() {
+	for {
+		next := this.runOnce(time.Now())
+		sleepDuration := next.Sub(time.Now())
+		if sleepDuration < minSleepDuration {
+			sleepDuration = minSleepDuration
+		}
+		time.Sleep(sleepDuration)
+	}
+}
+
+// runOnce syncs on the leader and returns time of the next run. Followers check again after retry delay.
+func (this *OnCallSyncer) runOnce(now time.Time) time.Time {
+	if this.Leader != nil && !this.Leader.IsLeader() {
+		return now.Add(retryDelay)
+	}
+
+	next, err := this.Sync(now)
+	if err != nil {
+		log.Printf("oncall sync error: %s", err)
+		next = now.Add(retryDelay)
+	}
+	return next
+}
+
+// Sync updates user group and topic for the user on duty at now and returns time of the next sync
+func (this *OnCallSyncer) Sync(now time.Time) (time.Time, error) {
+	cfg := this.getConfig()
+	next := now.Add(cfg.OnCallSync.RefreshInterval)
+
+	team := cfg.GetOnCallTeam()
+	usersOnDuty, err := this.DutyProvider.GetUsersOnDutyForDate(now, now.Add(scheduleLookahead), team.ScheduleID)
+	if err != nil {
+		return next, fmt.Errorf("error get users on duty: %s", err)
+	}
+
+	userOnDuty, found := getUserOnDutyAt(now, opsgenie.JoinDuties(usersOnDuty))
+	if !found {
+		return next, fmt.Errorf("no users on duty found")
+	}
+
+	if userOnDuty.End.Before(next) {
+		next = userOnDuty.End
+	}
+
+	userID, err := this.resolveUserID(team.Members, userOnDuty.Name)
+	if err != nil {
+		this.alert(cfg, userOnDuty, err)
+		return next, err
+	}
+
+	if err := this.syncUserGroup(cfg.OnCallSync.UserGroupID, userID); err != nil {
+		return next, err
+	}
+
+	if len(cfg.OnCallSync.TopicChannelID) > 0 {
+		if err := this.syncTopic(cfg.OnCallSync.TopicChannelID, userOnDuty); err != nil {
+			return next, err
+		}
+	}
+	return next, nil
+}
+
+func getUserOnDutyAt(now time.Time, usersOnDuty []opsgenie.UserOnDuty) (opsgenie.UserOnDuty, bool) {
+	for _, userOnDuty := range usersOnDuty {
+		if !userOnDuty.Start.After(now) && userOnDuty.End.After(now) {
+			return userOnDuty, true
+		}
+	}
+	return opsgenie.UserOnDuty{}, false
+}
+
+func (this *OnCallSyncer) resolveUserID(members []config.User, name string) (string, error) {
+	for _, user := range members {
+		if user.Name != name {
+			continue
+		}
+
+		if userID, found := this.UserResolver.GetUserID(user); found {
+			return userID, nil
+		}
+		return "", fmt.Errorf("can't find slack user id for %q", user.Name)
+	}
+	return "", fmt.Errorf("can't find user by name: %q", name)
+}
+
+func (this *OnCallSyncer) syncUserGroup(userGroupID, userID string) error {
+	members, err := this.SlackClient.GetUserGroupMembers(userGroupID)
+	if err != nil {
+		return fmt.Errorf("error get user group %q members: %s", userGroupID, err)
+	}
+
+	if len(members) == 1 && members[0] == userID {
+		return nil
+	}
+
+	sort.Strings(members)
+	log.Printf("update user group %q members: %v => [%s]", userGroupID, members, userID)
+
+	if err := this.SlackClient.UpdateUserGroupMembers(userGroupID, []string{userID}); err != nil {
+		return fmt.Errorf("error update user group %q members: %s", userGroupID, err)
+	}
+	return nil
+}
+
+func (this *OnCallSyncer) syncTopic(channelID string, userOnDuty opsgenie.UserOnDuty) error {
+	topic := fmt.Sprintf("On duty: %s till %s", userOnDuty.Name, userOnDuty.End.Format(timeFormatText))
+
+	currentTopic, err := this.SlackClient.GetChannelTopic(channelID)
+	if err != nil {
+		return fmt.Errorf("error get channel %q topic: %s", channelID, err)
+	}
+
+	if currentTopic == topic {
+		return nil
+	}
+
+	if err := this.SlackClient.SetChannelTopic(channelID, topic); err != nil {
+		return fmt.Errorf("error set channel %q topic: %s", channelID, err)
+	}
+	return nil
+}
+
+// alert posts unresolved user error to alert channel once per duty.
+// User group is left untouched to keep paging the previous user rather than nobody.
+func (this *OnCallSyncer) alert(cfg *config.Config, userOnDuty opsgenie.UserOnDuty, err error) {
+	key := userOnDuty.Name + userOnDuty.Start.String()
+	this.lock.Lock()
+	alerted := this.lastAlert == key
+	this.lastAlert = key
+	this.lock.Unlock()
+
+	if alerted {
+		return
+	}
+
+	text := fmt.Sprintf(":warning: Can't update on-call user group for %s: %s", userOnDuty.Name, err)
+	if err := this.AlertSender.SendMessage(cfg.OnCallSync.AlertChannel, text); err != nil {
+		log.Printf("Error send slack message: %s", err)
+	}
+}
@@ -0,0 +1,169 @@
+package oncall
+
+import (
+	"testing"
+	"time"
+
+	"bobby/config"
+	"bobby/opsgenie"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+type fakeSlackClient struct {
+	userGroupID string
+	members     []string
+	topic       string
+	updates     int
+	messages    []string
+}
+
+func (this *fakeSlackClient) SendMessage(channelID, text string) error {
+	this.messages = append(this.messages, text)
+	return nil
+}
+
+func (this *fakeSlackClient) GetUserGroupMembers(userGroupID string) ([]string, error) {
+	return this.members, nil
+}
+
+func (this *fakeSlackClient) UpdateUserGroupMembers(userGroupID string, userIDs []string) error {
+	this.userGroupID = userGroupID
+	this.members = userIDs
+	this.updates++
+	return nil
+}
+
+func (this *fakeSlackClient) GetChannelTopic(channelID string) (string, error) {
+	return this.topic, nil
+}
+
+func (this *fakeSlackClient) SetChannelTopic(channelID, topic string) error {
+	this.topic = topic
+	return nil
+}
+
+type fakeDutyProvider []opsgenie.UserOnDuty
+
+func (this fakeDutyProvider) GetUsersOnDutyForDate(from, to time.Time, scheduleID string) ([]opsgenie.UserOnDuty, error) {
+	return this, nil
+}
+
+type fakeUserResolver map[string]string
+
+func (this fakeUserResolver) GetUserID(user config.User) (string, bool) {
+	id, found := this[user.Key()]
+	return id, found
+}
+
+type OnCallSyncerTestSuite struct {
+	slackClient *fakeSlackClient
+	syncer      *OnCallSyncer
+}
+
+var _ = Suite(&OnCallSyncerTestSuite{})
+
+func (suite *OnCallSyncerTestSuite) SetUpTest(c *C) {
+	cfg := &config.Config{}
+	cfg.OnCallSync.UserGroupID = "S1"
+	cfg.OnCallSync.TopicChannelID = "C1"
+	cfg.OnCallSync.RefreshInterval = 10 * time.Minute
+	cfg.TimelogsCommand.Team = []config.User{
+		{Name: "User1", SlackLogin: "user1"},
+		{Name: "User2", SlackLogin: "user2"},
+		{Name: "User3", SlackLogin: "user3"},
+	}
+
+	suite.slackClient = &fakeSlackClient{members: []string{"U0"}}
+	suite.syncer = &OnCallSyncer{
+		Config:      cfg,
+		SlackClient: suite.slackClient,
+		AlertSender: suite.slackClient,
+		DutyProvider: fakeDutyProvider{
+			{
+				Name:  "User1",
+				Start: time.Date(2016, time.May, 17, 0, 0, 0, 0, time.Local),
+				End:   time.Date(2016, time.May, 17, 9, 0, 0, 0, time.Local),
+			},
+			{
+				Name:  "User2",
+				Start: time.Date(2016, time.May, 17, 9, 0, 0, 0, time.Local),
+				End:   time.Date(2016, time.May, 17, 18, 0, 0, 0, time.Local),
+			},
+			{
+				Name:  "User3",
+				Start: time.Date(2016, time.May, 17, 18, 0, 0, 0, time.Local),
+				End:   time.Date(2016, time.May, 18, 9, 0, 0, 0, time.Local),
+			},
+		},
+		UserResolver: fakeUserResolver{"user1": "U1", "user2": "U2"},
+	}
+}
+
+func (suite *OnCallSyncerTestSuite) TestSync(c *C) {
+	now := time.Date(2016, time.May, 17, 8, 55, 0, 0, time.Local)
+	next, err := suite.syncer.Sync(now)
+	c.Assert(err, IsNil)
+	c.Assert(next, Equals, time.Date(2016, time.May, 17, 9, 0, 0, 0, time.Local))
+	c.Assert(suite.slackClient.members, DeepEquals, []string{"U1"})
+	c.Assert(suite.slackClient.topic, Equals, "On duty: User1 till 09:00")
+
+	// repeated sync doesn't update anything
+	next, err = suite.syncer.Sync(now.Add(time.Minute))
+	c.Assert(err, IsNil)
+	c.Assert(suite.slackClient.updates, Equals, 1)
+
+	next, err = suite.syncer.Sync(next)
+	c.Assert(err, IsNil)
+	c.Assert(next, Equals, time.Date(2016, time.May, 17, 9, 10, 0, 0, time.Local))
+	c.Assert(suite.slackClient.members, DeepEquals, []string{"U2"})
+	c.Assert(suite.slackClient.topic, Equals, "On duty: User2 till 18:00")
+	c.Assert(suite.slackClient.updates, Equals, 2)
+}
+
+func (suite *OnCallSyncerTestSuite) TestSync_UnresolvedUser(c *C) {
+	now := time.Date(2016, time.May, 17, 19, 0, 0, 0, time.Local)
+	_, err := suite.syncer.Sync(now)
+	c.Assert(err, NotNil)
+	_, err = suite.syncer.Sync(now.Add(time.Minute))
+	c.Assert(err, NotNil)
+
+	c.Assert(suite.slackClient.members, DeepEquals, []string{"U0"})
+	c.Assert(suite.slackClient.updates, Equals, 0)
+	c.Assert(len(suite.slackClient.messages), Equals, 1)
+}
+
+type fakeLeader bool
+
+func (this fakeLeader) IsLeader() bool {
+	return bool(this)
+}
+
+func (suite *OnCallSyncerTestSuite) TestRunOnLeaderOnly(c *C) {
+	now := time.Date(2016, time.May, 17, 19, 0, 0, 0, time.Local)
+	suite.syncer.Leader = fakeLeader(false)
+	c.Assert(suite.syncer.runOnce(now), Equals, now.Add(retryDelay))
+	c.Assert(suite.slackClient.messages, HasLen, 0)
+
+	suite.syncer.Leader = fakeLeader(true)
+	suite.syncer.runOnce(now)
+	c.Assert(suite.slackClient.messages, HasLen, 1)
+}
+
+func (suite *OnCallSyncerTestSuite) TestSetConfig(c *C) {
+	cfg := *suite.syncer.Config
+	cfg.OnCallSync.UserGroupID = "S2"
+	cfg.TimelogsCommand.Team = []config.User{{Name: "User3", SlackLogin: "user3"}}
+	suite.syncer.SetConfig(&cfg)
+	suite.syncer.UserResolver = fakeUserResolver{"user3": "U3"}
+
+	_, err := suite.syncer.Sync(time.Date(2016, time.May, 17, 19, 0, 0, 0, time.Local))
+	c.Assert(err, IsNil)
+	c.Assert(suite.slackClient.userGroupID, Equals, "S2")
+	c.Assert(suite.slackClient.members, DeepEquals, []string{"U3"})
+}
@@ -8,6 +8,7 @@ import (
 
 	"bobby/config"
 	"bobby/jira"
+	"bobby/notify"
 	"bobby/utils"
 	"fmt"
 )
@@ -16,18 +17,15 @@ const (
 	aproxMessageLength = 128
 )
 
-type ISlackClient interface {
-	SendMessage(channelID, text string) error
-}
-
 type IJiraClient interface {
 	GetUsersLoggedLessThenMin([]string, time.Time, time.Time, time.Duration) ([]jira.UserTimeLog, error)
 }
 
+// TimelogsDailyMessenger posts team members who didn't log enough time and reminds them personally
 type TimelogsDailyMessenger struct {
-	Config      *config.Config
-	SlackClient ISlackClient
-	JiraClient  IJiraClient
+	Team       config.Team
+	Notifiers  []notify.INotifier
+	JiraClient IJiraClient
 }
 
 type userTimeSpentItem struct {
@@ -35,16 +33,16 @@ type userTimeSpentItem struct {
 	timeSpent time.Duration
 }
 
-func (this *TimelogsDailyMessenger) Run(now time.Time) {
+func (this *TimelogsDailyMessenger) Run(now time.Time) error {
 	usersTimeLogs, err := this.getUsersTimeLogs(now)
 	if err != nil {
-		log.Printf("Error get users time logs: %s", err.Error())
+		return fmt.Errorf("error get users time logs: %s", err.Error())
 	}
 
 	log.Printf("usersTimeLogs: %+v", usersTimeLogs)
 
-	jiraLoginToUserMap := make(map[string]config.User, len(this.Config.TimelogsCommand.Team))
-	for _, user := range this.Config.TimelogsCommand.Team {
+	jiraLoginToUserMap := make(map[string]config.User, len(this.Team.Members))
+	for _, user := range this.Team.Members {
 		jiraLoginToUserMap[user.JiraLogin] = user
 	}
 
@@ -62,38 +60,62 @@ func (this *TimelogsDailyMessenger) Run(now time.Time) {
 
 	this.notifyUsers(userTimeSpentItems)
 
-	message := this.render(now, userTimeSpentItems)
-	log.Println(message)
-	if err := this.SlackClient.SendMessage(this.Config.Slack.Channel, message); err != nil {
-		log.Printf("Error send slack message: %s", err.Error())
+	if len(userTimeSpentItems) == 0 {
+		return nil
+	}
+
+	var sendErr error
+	for _, notifier := range this.Notifiers {
+		message := this.render(notifier, userTimeSpentItems)
+		log.Println(message)
+
+		if err := notifier.Post(&notify.Message{
+			Emoji: "alarm_clock",
+			Title: this.Team.Title("Time logs"),
+			Text:  message,
+		}); err != nil {
+			log.Printf("Error send message: %s", err.Error())
+			sendErr = fmt.Errorf("error send message: %s", err.Error())
+		}
 	}
+	return sendErr
+}
+
+// DryRun renders messages for every notifier without sending them
+func (this *TimelogsDailyMessenger) DryRun(now time.Time) (interface{}, error) {
+	return notify.DryRun(this.Notifiers, func(notifiers []notify.INotifier) error {
+		messenger := &TimelogsDailyMessenger{
+			Team:       this.Team,
+			Notifiers:  notifiers,
+			JiraClient: this.JiraClient,
+		}
+		return messenger.Run(now)
+	})
 }
 
 func (this *TimelogsDailyMessenger) getUsersTimeLogs(now time.Time) ([]jira.UserTimeLog, error) {
 	log.Printf("start time log\n")
 	from, to := utils.GetPreviousDateRange(now)
 
-	usersJiraLogins := make([]string, 0, len(this.Config.TimelogsCommand.Team))
-	for _, user := range this.Config.TimelogsCommand.Team {
+	usersJiraLogins := make([]string, 0, len(this.Team.Members))
+	for _, user := range this.Team.Members {
 		usersJiraLogins = append(usersJiraLogins, user.JiraLogin)
 	}
 
 	log.Printf("usersJiraLogins: %+v\n", usersJiraLogins)
 
-	return this.JiraClient.GetUsersLoggedLessThenMin(usersJiraLogins, from, to,
-		this.Config.TimelogsCommand.MinimumTimeSpent)
+	return this.JiraClient.GetUsersLoggedLessThenMin(usersJiraLogins, from, to, this.Team.MinimumTimeSpent)
 }
 
-func (this *TimelogsDailyMessenger) render(now time.Time, userTimeSpentItems []userTimeSpentItem) string {
+func (this *TimelogsDailyMessenger) render(notifier notify.INotifier, userTimeSpentItems []userTimeSpentItem) string {
 	var buf bytes.Buffer
 	buf.Grow(aproxMessageLength)
 
 	if len(userTimeSpentItems) > 0 {
 		rageNumber := 1
-		utils.LogIfErr(buf.WriteString("\n :alarm_clock: Time logs:\n"))
 		for _, item := range userTimeSpentItems {
 			utils.LogIfErr(buf.WriteString("\t "))
-			utils.LogIfErr(buf.WriteString(utils.ToSlackUserLogin(item.user.SlackLogin)))
+			utils.LogIfErr(buf.WriteString(notifier.Mention(item.user)))
 			if item.timeSpent > 0 {
 				utils.LogIfErr(buf.WriteString(" logged only "))
 				utils.LogIfErr(buf.WriteString(item.timeSpent.String()))
@@ -117,13 +139,15 @@ func (this *TimelogsDailyMessenger) notifyUsers(userTimeSpentItems []userTimeSpe
 	for _, item := range userTimeSpentItems {
 		message := this.renderPersonalMessage(utils.GetFirstName(item.user.Name), item.timeSpent)
 		log.Printf("notify user: %s => %s\n", item.user.SlackLogin, message)
-		go this.notifyUser(item.user.SlackLogin, message)
+		this.notifyUser(item.user, message)
 	}
 }
 
-func (this *TimelogsDailyMessenger) notifyUser(userSlackLogin, message string) {
-	if err := this.SlackClient.SendMessage(utils.ToSlackUserLogin(userSlackLogin), message); err != nil {
-		log.Printf("send private message error: %s", err.Error())
+func (this *TimelogsDailyMessenger) notifyUser(user config.User, message string) {
+	for _, notifier := range this.Notifiers {
+		if err := notifier.SendDirectMessage(user, &notify.Message{Text: message}); err != nil {
+			log.Printf("send private message error: %s", err.Error())
+		}
 	}
 }
 
@@ -136,5 +160,5 @@ func (this *TimelogsDailyMessenger) renderPersonalMessage(name string, timeSpent
 	}
 
 	return fmt.Sprintf("Hi, %s! %s for yesterday. Could you please log at least %d hours?",
-		name, subMessage, int(this.Config.TimelogsCommand.MinimumTimeSpent.Hours()))
+		name, subMessage, int(this.Team.MinimumTimeSpent.Hours()))
 }
@@ -0,0 +1,88 @@
+package timelogs
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"bobby/config"
+	"bobby/notify"
+	"bobby/utils"
+)
+
+const workDaysPerWeek = 5
+
+// TimelogsWeeklyMessenger emails team members who didn't log enough time during the previous week
+type TimelogsWeeklyMessenger struct {
+	Team       config.Team
+	Notifiers  []notify.INotifier
+	JiraClient IJiraClient
+}
+
+func (this *TimelogsWeeklyMessenger) Run(now time.Time) error {
+	from, to := utils.GetPreviousWeekRange(now)
+
+	usersJiraLogins := make([]string, 0, len(this.Team.Members))
+	jiraLoginToUserMap := make(map[string]config.User, len(this.Team.Members))
+	for _, user := range this.Team.Members {
+		usersJiraLogins = append(usersJiraLogins, user.JiraLogin)
+		jiraLoginToUserMap[user.JiraLogin] = user
+	}
+
+	minimum := this.Team.MinimumTimeSpent * workDaysPerWeek
+	usersTimeLogs, err := this.JiraClient.GetUsersLoggedLessThenMin(usersJiraLogins, from, to, minimum)
+	if err != nil {
+		return fmt.Errorf("error get users time logs: %s", err.Error())
+	}
+
+	userTimeSpentItems := make([]userTimeSpentItem, 0, len(usersTimeLogs))
+	for _, item := range usersTimeLogs {
+		if user, exists := jiraLoginToUserMap[item.Name]; exists {
+			userTimeSpentItems = append(userTimeSpentItems, userTimeSpentItem{
+				user:      user,
+				timeSpent: item.TimeSpent,
+			})
+		}
+	}
+
+	var sendErr error
+	for _, notifier := range this.Notifiers {
+		if err := notifier.Post(&notify.Message{
+			Emoji: "alarm_clock",
+			Title: this.Team.Title("Weekly time logs"),
+			Text:  this.render(notifier, from, to, minimum, userTimeSpentItems),
+		}); err != nil {
+			sendErr = fmt.Errorf("error send message: %s", err.Error())
+		}
+	}
+	return sendErr
+}
+
+// DryRun renders messages for every notifier without sending them
+func (this *TimelogsWeeklyMessenger) DryRun(now time.Time) (interface{}, error) {
+	return notify.DryRun(this.Notifiers, func(notifiers []notify.INotifier) error {
+		messenger := &TimelogsWeeklyMessenger{
+			Team:       this.Team,
+			Notifiers:  notifiers,
+			JiraClient: this.JiraClient,
+		}
+		return messenger.Run(now)
+	})
+}
+
+func (this *TimelogsWeeklyMessenger) render(notifier notify.INotifier, from, to time.Time, minimum time.Duration,
+	userTimeSpentItems []userTimeSpentItem) string {
+	var buf bytes.Buffer
+	buf.Grow(aproxMessageLength)
+
+	fmt.Fprintf(&buf, "%s - %s\n", from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if len(userTimeSpentItems) == 0 {
+		fmt.Fprintf(&buf, "Everyone logged at least %v\n", minimum)
+		return buf.String()
+	}
+
+	for _, item := range userTimeSpentItems {
+		fmt.Fprintf(&buf, "\t %s logged %v of %v\n", notifier.Mention(item.user), item.timeSpent, minimum)
+	}
+	return buf.String()
+}
@@ -0,0 +1,81 @@
+package timelogs
+
+import (
+	"mime"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"bobby/config"
+	"bobby/email"
+	"bobby/email/emailtest"
+	"bobby/jira"
+	"bobby/notify"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+type fakeJiraClient struct {
+	from, to time.Time
+	min      time.Duration
+	logs     []jira.UserTimeLog
+}
+
+func (this *fakeJiraClient) GetUsersLoggedLessThenMin(users []string, from, to time.Time, min time.Duration) ([]jira.UserTimeLog, error) {
+	this.from, this.to, this.min = from, to, min
+	return this.logs, nil
+}
+
+type TimelogsWeeklyMessengerTestSuite struct{}
+
+var _ = Suite(&TimelogsWeeklyMessengerTestSuite{})
+
+func (suite *TimelogsWeeklyMessengerTestSuite) TestRun(c *C) {
+	sink, err := emailtest.NewSink()
+	c.Assert(err, IsNil)
+	defer sink.Close()
+
+	host, port := sink.Addr()
+	client := email.NewClient(email.Options{
+		Host: host,
+		Port: port,
+		From: "bobby@example.com",
+	})
+
+	members := []config.User{
+		{Name: "John Doe", JiraLogin: "johndoe", Email: "john.doe@example.com", EmailDigest: true},
+		{Name: "Jane Roe", JiraLogin: "janeroe"},
+	}
+	jiraClient := &fakeJiraClient{logs: []jira.UserTimeLog{{Name: "janeroe", TimeSpent: 20 * time.Hour}}}
+	messenger := &TimelogsWeeklyMessenger{
+		Team: config.Team{
+			Name:             "team-x",
+			MinimumTimeSpent: 6 * time.Hour,
+			Members:          members,
+		},
+		Notifiers:  []notify.INotifier{notify.NewEmailNotifier(client, []string{"managers@example.com"}, members, "[bobby]")},
+		JiraClient: jiraClient,
+	}
+
+	c.Assert(messenger.Run(time.Date(2026, 10, 21, 9, 0, 0, 0, time.UTC)), IsNil)
+	c.Assert(jiraClient.from, Equals, time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC))
+	c.Assert(jiraClient.to, Equals, time.Date(2026, 10, 18, 23, 59, 59, 0, time.UTC))
+	c.Assert(jiraClient.min, Equals, 30*time.Hour)
+
+	msg := <-sink.Messages
+	c.Assert(msg.Recipients, DeepEquals, []string{"managers@example.com", "john.doe@example.com"})
+
+	parsed, err := mail.ReadMessage(strings.NewReader(msg.Data))
+	c.Assert(err, IsNil)
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	c.Assert(err, IsNil)
+	c.Assert(subject, Equals, "[bobby] Weekly time logs (team-x):")
+	c.Assert(strings.Contains(msg.Data, "2026-10-12 - 2026-10-18"), Equals, true)
+	c.Assert(strings.Contains(msg.Data, "Jane Roe logged 20h0m0s of 30h0m0s"), Equals, true)
+}
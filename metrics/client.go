@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// transport reports latency and status codes of requests to upstream api
+type transport struct {
+	upstream string
+	next     http.RoundTripper
+}
+
+func (this *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	startedAt := time.Now()
+	resp, err := this.next.RoundTrip(req)
+	UpstreamDuration.WithLabelValues(this.upstream).Observe(time.Since(startedAt).Seconds())
+
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	UpstreamRequests.WithLabelValues(this.upstream, code).Inc()
+	return resp, err
+}
+
+// NewHTTPClient returns http client shared by api clients of upstream, its requests are instrumented
+func NewHTTPClient(upstream string) *http.Client {
+	return &http.Client{
+		Transport: &transport{
+			upstream: upstream,
+			next:     http.DefaultTransport,
+		},
+	}
+}
@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "bobby"
+
+// upstream names of the shared http client
+const (
+	UpstreamSlack      = "slack"
+	UpstreamJira       = "jira"
+	UpstreamOpsgenie   = "opsgenie"
+	UpstreamMattermost = "mattermost"
+	UpstreamMSTeams    = "msteams"
+)
+
+var registry = prometheus.NewRegistry()
+
+var (
+	// Commands counts slash commands by outcome: answered, postponed or error
+	Commands = newCounterVec("commands_total", "Slash commands by command and outcome.", "command", "outcome")
+	// CommandDuration is the time to answer slash command, postponed results aren't included
+	CommandDuration = newHistogramVec("command_duration_seconds", "Time to answer slash command.", "command")
+	// QueryDuration is the time to compute command result, in background for postponed commands
+	QueryDuration = newHistogramVec("query_duration_seconds", "Time to compute command result.", "command")
+	// CacheRequests counts command result cache lookups by result: hit, stale or miss
+	CacheRequests = newCounterVec("cache_requests_total", "Command result cache lookups by result.", "command", "result")
+
+	UpstreamRequests = newCounterVec("upstream_requests_total", "Upstream api requests by status code.", "upstream", "code")
+	UpstreamDuration = newHistogramVec("upstream_request_duration_seconds", "Upstream api request latency.", "upstream")
+	UpstreamRetries  = newCounterVec("upstream_retries_total", "Retried upstream api requests.", "upstream")
+
+	// JobRuns counts cron job runs by result: success or failure
+	JobRuns     = newCounterVec("job_runs_total", "Cron job runs by result.", "job", "result")
+	JobDuration = newHistogramVec("job_duration_seconds", "Cron job run time.", "job")
+
+	// Messages counts outbound messages by channel and result: sent, retried or failed
+	Messages = newCounterVec("messages_total", "Outbound messages by channel and result.", "channel", "result")
+)
+
+func init() {
+	registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+}
+
+func newCounterVec(name, help string, labels ...string) *prometheus.CounterVec {
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, labels)
+	registry.MustRegister(counter)
+	return counter
+}
+
+func newHistogramVec(name, help string, labels ...string) *prometheus.HistogramVec {
+	histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, labels)
+	registry.MustRegister(histogram)
+	return histogram
+}
+
+// Handler serves metrics in prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+type MetricsTestSuite struct{}
+
+var _ = Suite(&MetricsTestSuite{})
+
+func (suite *MetricsTestSuite) TestHTTPClient(c *C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient("test")
+	for _, path := range []string{"/", "/", "/missing"} {
+		resp, err := client.Get(server.URL + path)
+		c.Assert(err, IsNil)
+		resp.Body.Close()
+	}
+	_, err := client.Get("http://127.0.0.1:0/")
+	c.Assert(err, NotNil)
+
+	c.Assert(testutil.ToFloat64(UpstreamRequests.WithLabelValues("test", "200")), Equals, float64(2))
+	c.Assert(testutil.ToFloat64(UpstreamRequests.WithLabelValues("test", "404")), Equals, float64(1))
+	c.Assert(testutil.ToFloat64(UpstreamRequests.WithLabelValues("test", "error")), Equals, float64(1))
+	c.Assert(testutil.CollectAndCount(UpstreamDuration, "bobby_upstream_request_duration_seconds"), Equals, 1)
+}
+
+func (suite *MetricsTestSuite) TestHandler(c *C) {
+	Commands.WithLabelValues("duty", "postponed").Inc()
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+
+	body, err := ioutil.ReadAll(recorder.Body)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(body), `bobby_commands_total{command="duty",outcome="postponed"} 1`), Equals, true)
+	c.Assert(strings.Contains(string(body), "go_goroutines"), Equals, true)
+}
@@ -0,0 +1,78 @@
+package msteams
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"bobby/metrics"
+)
+
+// unsupportedError is never retried by delivery queue
+type unsupportedError string
+
+func (this unsupportedError) Error() string {
+	return "msteams: " + string(this) + " are not supported by incoming webhooks"
+}
+
+func (this unsupportedError) Retryable() bool {
+	return false
+}
+
+const (
+	DirectMessagesNotSupportedError = unsupportedError("direct messages")
+	SlashCommandsNotSupportedError  = unsupportedError("slash commands")
+)
+
+// Client posts message cards to microsoft teams incoming webhook.
+// Webhook is bound to a single channel so channel argument is ignored.
+type Client struct {
+	webhookURL string
+	httpClient *http.Client
+}
+
+func NewClient(webhookURL string) *Client {
+	return &Client{
+		webhookURL: webhookURL,
+		httpClient: metrics.NewHTTPClient(metrics.UpstreamMSTeams),
+	}
+}
+
+type messageCard struct {
+	Type    string `json:"@type"`
+	Context string `json:"@context"`
+	Summary string `json:"summary"`
+	Text    string `json:"text"`
+}
+
+func (this *Client) SendMessage(channel, text string) error {
+	requestBody, err := json.Marshal(&messageCard{
+		Type:    "MessageCard",
+		Context: "http://schema.org/extensions",
+		Summary: "bobby",
+		Text:    text,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := this.httpClient.Post(this.webhookURL, "application/json", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("msteams: http status: %s", resp.Status)
+	}
+	return nil
+}
+
+func (this *Client) SendDirectMessage(userID, text string) error {
+	return DirectMessagesNotSupportedError
+}
+
+func (this *Client) SendPostponedMessage(responseURL, text string) error {
+	return SlashCommandsNotSupportedError
+}
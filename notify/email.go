@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"html"
+	"strings"
+
+	"bobby/config"
+)
+
+type IEmailClient interface {
+	Send(to []string, subject, plain, html string) error
+}
+
+// EmailNotifier sends messages to distribution lists and to roster users
+// who opted in with email-digest (managers and contractors outside of chat)
+type EmailNotifier struct {
+	client        IEmailClient
+	recipients    []string
+	subjectPrefix string
+}
+
+func NewEmailNotifier(client IEmailClient, distributionLists []string, users []config.User, subjectPrefix string) *EmailNotifier {
+	recipients := append([]string(nil), distributionLists...)
+	for _, user := range users {
+		if isEmailRecipient(user) {
+			recipients = append(recipients, user.Email)
+		}
+	}
+
+	return &EmailNotifier{
+		client:        client,
+		recipients:    recipients,
+		subjectPrefix: subjectPrefix,
+	}
+}
+
+func isEmailRecipient(user config.User) bool {
+	return user.EmailDigest && len(user.Email) > 0
+}
+
+func (this *EmailNotifier) Post(message *Message) error {
+	return this.send(this.recipients, message)
+}
+
+// SendDirectMessage emails user only if user receives digests, other users get their messages in chat
+func (this *EmailNotifier) SendDirectMessage(user config.User, message *Message) error {
+	if !isEmailRecipient(user) {
+		return nil
+	}
+	return this.send([]string{user.Email}, message)
+}
+
+func (this *EmailNotifier) Mention(user config.User) string {
+	return user.Name
+}
+
+func (this *EmailNotifier) send(to []string, message *Message) error {
+	subject := this.subjectPrefix + " " + message.Title
+	if len(message.Title) == 0 {
+		subject = this.subjectPrefix + " " + strings.SplitN(message.Text, "\n", 2)[0]
+	}
+
+	plain := message.Text
+	htmlText := strings.Replace(html.EscapeString(message.Text), "\n", "<br>\n", -1)
+	htmlText = strings.Replace(htmlText, "\t", "&nbsp;&nbsp;&nbsp;&nbsp;", -1)
+	if len(message.Title) > 0 {
+		plain = message.Title + "\n\n" + plain
+		htmlText = "<h3>" + html.EscapeString(message.Title) + "</h3>\n<p>" + htmlText + "</p>"
+	}
+
+	return this.client.Send(to, strings.TrimSpace(subject), plain, htmlText)
+}
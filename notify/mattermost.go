@@ -0,0 +1,36 @@
+package notify
+
+import (
+	"bobby/config"
+)
+
+type MattermostNotifier struct {
+	sender  ISender
+	channel string
+}
+
+func NewMattermostNotifier(sender ISender, channel string) *MattermostNotifier {
+	return &MattermostNotifier{
+		sender:  sender,
+		channel: channel,
+	}
+}
+
+func (this *MattermostNotifier) Post(message *Message) error {
+	return this.sender.SendMessage(this.channel, renderMarkdown(message, "**"))
+}
+
+func (this *MattermostNotifier) SendDirectMessage(user config.User, message *Message) error {
+	return this.sender.SendDirectMessage(getMattermostLogin(user), renderMarkdown(message, "**"))
+}
+
+func (this *MattermostNotifier) Mention(user config.User) string {
+	return "@" + getMattermostLogin(user)
+}
+
+func getMattermostLogin(user config.User) string {
+	if len(user.MattermostLogin) > 0 {
+		return user.MattermostLogin
+	}
+	return user.SlackLogin
+}
@@ -0,0 +1,39 @@
+package notify
+
+import (
+	"strings"
+
+	"bobby/config"
+	"bobby/msteams"
+)
+
+type MSTeamsNotifier struct {
+	sender ISender
+}
+
+func NewMSTeamsNotifier(sender ISender) *MSTeamsNotifier {
+	return &MSTeamsNotifier{
+		sender: sender,
+	}
+}
+
+// Post posts message card to the webhook channel.
+// Teams doesn't render emoji shortcodes and collapses single newlines, so they are dropped and replaced.
+func (this *MSTeamsNotifier) Post(message *Message) error {
+	text := renderMarkdown(&Message{
+		Title: message.Title,
+		Text:  message.Text,
+	}, "**")
+	text = strings.Replace(text, "\t", "&nbsp;&nbsp;&nbsp;&nbsp;", -1)
+	text = strings.Replace(text, "\n", "<br>", -1)
+	return this.sender.SendMessage("", text)
+}
+
+func (this *MSTeamsNotifier) SendDirectMessage(user config.User, message *Message) error {
+	return msteams.DirectMessagesNotSupportedError
+}
+
+// Mention renders plain user name: incoming webhooks can't mention users
+func (this *MSTeamsNotifier) Mention(user config.User) string {
+	return user.Name
+}
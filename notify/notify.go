@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"bobby/config"
+)
+
+// Message is a transport neutral message. Each notifier renders it with its own markup.
+type Message struct {
+	// Emoji is an emoji shortcode without colons shown before the title
+	Emoji string
+	// Title is rendered as a bold header line
+	Title string
+	// Text is a message body. Mentions must be rendered with INotifier.Mention
+	Text string
+}
+
+type INotifier interface {
+	// Post posts message to the team channel
+	Post(message *Message) error
+	// SendDirectMessage sends message to user privately
+	SendDirectMessage(user config.User, message *Message) error
+	// Mention renders user mention for message text
+	Mention(user config.User) string
+}
+
+// ISender is a low level message sender (usually delivery queue)
+type ISender interface {
+	SendMessage(channel, text string) error
+	SendDirectMessage(userID, text string) error
+}
+
+func renderMarkdown(message *Message, bold string) string {
+	if len(message.Title) == 0 {
+		return message.Text
+	}
+
+	title := bold + message.Title + bold
+	if len(message.Emoji) > 0 {
+		title = ":" + message.Emoji + ": " + title
+	}
+
+	if len(message.Text) == 0 {
+		return title
+	}
+	return title + "\n" + message.Text
+}
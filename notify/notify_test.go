@@ -0,0 +1,128 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bobby/config"
+	"bobby/mattermost"
+	"bobby/msteams"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+type NotifierTestSuite struct {
+	server   *httptest.Server
+	requests []map[string]string
+}
+
+var _ = Suite(&NotifierTestSuite{})
+
+var testUser = config.User{
+	Name:       "John Doe",
+	SlackLogin: "john.doe",
+}
+
+var testMessage = &Message{
+	Emoji: "phone",
+	Title: "On duty:",
+	Text:  "Now:\n\tJohn Doe",
+}
+
+func (suite *NotifierTestSuite) SetUpTest(c *C) {
+	suite.requests = nil
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]string
+		c.Check(json.NewDecoder(r.Body).Decode(&request), IsNil)
+		suite.requests = append(suite.requests, request)
+	}))
+}
+
+func (suite *NotifierTestSuite) TearDownTest(c *C) {
+	suite.server.Close()
+}
+
+func (suite *NotifierTestSuite) TestMattermost(c *C) {
+	notifier := NewMattermostNotifier(mattermost.NewClient(suite.server.URL), "town-square")
+
+	c.Assert(notifier.Mention(testUser), Equals, "@john.doe")
+	c.Assert(notifier.Post(testMessage), IsNil)
+	c.Assert(notifier.SendDirectMessage(testUser, &Message{Text: "Hello"}), IsNil)
+
+	c.Assert(len(suite.requests), Equals, 2)
+	c.Assert(suite.requests[0]["channel"], Equals, "town-square")
+	c.Assert(suite.requests[0]["text"], Equals, ":phone: **On duty:**\nNow:\n\tJohn Doe")
+	c.Assert(suite.requests[1]["channel"], Equals, "@john.doe")
+	c.Assert(suite.requests[1]["text"], Equals, "Hello")
+}
+
+func (suite *NotifierTestSuite) TestMSTeams(c *C) {
+	notifier := NewMSTeamsNotifier(msteams.NewClient(suite.server.URL))
+
+	c.Assert(notifier.Mention(testUser), Equals, "John Doe")
+	c.Assert(notifier.Post(testMessage), IsNil)
+	c.Assert(notifier.SendDirectMessage(testUser, testMessage), Equals, msteams.DirectMessagesNotSupportedError)
+
+	c.Assert(len(suite.requests), Equals, 1)
+	c.Assert(suite.requests[0]["@type"], Equals, "MessageCard")
+	c.Assert(suite.requests[0]["text"], Equals, "**On duty:**<br>Now:<br>&nbsp;&nbsp;&nbsp;&nbsp;John Doe")
+}
+
+type fakeSender map[string]string
+
+func (this fakeSender) SendMessage(channel, text string) error {
+	this[channel] = text
+	return nil
+}
+
+func (this fakeSender) SendDirectMessage(userID, text string) error {
+	this[userID] = text
+	return nil
+}
+
+type fakeUserResolver map[string]string
+
+func (this fakeUserResolver) GetUserID(user config.User) (string, bool) {
+	id, found := this[user.Key()]
+	return id, found
+}
+
+func (suite *NotifierTestSuite) TestSlack(c *C) {
+	sender := fakeSender{}
+	notifier := NewSlackNotifier(sender, fakeUserResolver{"john.doe": "U1"}, "C1")
+
+	c.Assert(notifier.Mention(testUser), Equals, "<@U1>")
+	c.Assert(notifier.Mention(config.User{Name: "Jane Roe", SlackLogin: "jane.roe"}), Equals, "Jane Roe")
+	c.Assert(notifier.Post(testMessage), IsNil)
+	c.Assert(notifier.SendDirectMessage(testUser, &Message{Text: "Hello"}), IsNil)
+	c.Assert(notifier.SendDirectMessage(config.User{SlackLogin: "jane.roe"}, &Message{Text: "Hello"}), NotNil)
+
+	c.Assert(sender, DeepEquals, fakeSender{
+		"C1": ":phone: *On duty:*\nNow:\n\tJohn Doe",
+		"U1": "Hello",
+	})
+}
+
+func (suite *NotifierTestSuite) TestDryRun(c *C) {
+	notifier := NewMattermostNotifier(mattermost.NewClient(suite.server.URL), "town-square")
+
+	messages, err := DryRun([]INotifier{notifier}, func(notifiers []INotifier) error {
+		if err := notifiers[0].Post(&Message{Title: "On duty:", Text: "Now:\n\t" + notifiers[0].Mention(testUser)}); err != nil {
+			return err
+		}
+		return notifiers[0].SendDirectMessage(testUser, &Message{Text: "Hello"})
+	})
+	c.Assert(err, IsNil)
+	c.Assert(messages, DeepEquals, [][]RecordedMessage{{
+		{Title: "On duty:", Text: "Now:\n\t@john.doe"},
+		{To: "John Doe", Text: "Hello"},
+	}})
+	c.Assert(suite.requests, HasLen, 0)
+}
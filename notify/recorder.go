@@ -0,0 +1,77 @@
+package notify
+
+import (
+	"sync"
+
+	"bobby/config"
+)
+
+// RecordedMessage is a message which would be sent by a notifier. To is empty for channel posts.
+type RecordedMessage struct {
+	To    string `json:"to,omitempty"`
+	Title string `json:"title,omitempty"`
+	Text  string `json:"text"`
+}
+
+// Recorder records messages instead of sending them, mentions are rendered by the wrapped notifier.
+// It is used to preview daily messages.
+type Recorder struct {
+	notifier INotifier
+
+	lock     sync.Mutex
+	messages []RecordedMessage
+}
+
+func NewRecorder(notifier INotifier) *Recorder {
+	return &Recorder{notifier: notifier}
+}
+
+func (this *Recorder) Post(message *Message) error {
+	this.record("", message)
+	return nil
+}
+
+func (this *Recorder) SendDirectMessage(user config.User, message *Message) error {
+	this.record(user.Name, message)
+	return nil
+}
+
+func (this *Recorder) Mention(user config.User) string {
+	return this.notifier.Mention(user)
+}
+
+// Messages returns recorded messages in order they were sent
+func (this *Recorder) Messages() []RecordedMessage {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return append([]RecordedMessage(nil), this.messages...)
+}
+
+func (this *Recorder) record(to string, message *Message) {
+	this.lock.Lock()
+	this.messages = append(this.messages, RecordedMessage{
+		To:    to,
+		Title: message.Title,
+		Text:  message.Text,
+	})
+	this.lock.Unlock()
+}
+
+// DryRun runs send with notifiers replaced by recorders and returns messages recorded by each of them
+func DryRun(notifiers []INotifier, send func(notifiers []INotifier) error) ([][]RecordedMessage, error) {
+	recorders := make([]*Recorder, 0, len(notifiers))
+	replaced := make([]INotifier, 0, len(notifiers))
+	for _, notifier := range notifiers {
+		recorder := NewRecorder(notifier)
+		recorders = append(recorders, recorder)
+		replaced = append(replaced, recorder)
+	}
+
+	err := send(replaced)
+
+	messages := make([][]RecordedMessage, 0, len(recorders))
+	for _, recorder := range recorders {
+		messages = append(messages, recorder.Messages())
+	}
+	return messages, err
+}
@@ -0,0 +1,45 @@
+package notify
+
+import (
+	"fmt"
+
+	"bobby/config"
+	"bobby/utils"
+)
+
+type IUserResolver interface {
+	GetUserID(user config.User) (string, bool)
+}
+
+type SlackNotifier struct {
+	sender       ISender
+	userResolver IUserResolver
+	channel      string
+}
+
+func NewSlackNotifier(sender ISender, userResolver IUserResolver, channel string) *SlackNotifier {
+	return &SlackNotifier{
+		sender:       sender,
+		userResolver: userResolver,
+		channel:      channel,
+	}
+}
+
+func (this *SlackNotifier) Post(message *Message) error {
+	return this.sender.SendMessage(this.channel, renderMarkdown(message, "*"))
+}
+
+func (this *SlackNotifier) SendDirectMessage(user config.User, message *Message) error {
+	userID, found := this.userResolver.GetUserID(user)
+	if !found {
+		return fmt.Errorf("can't find slack user id for %q", user.Name)
+	}
+	return this.sender.SendDirectMessage(userID, renderMarkdown(message, "*"))
+}
+
+func (this *SlackNotifier) Mention(user config.User) string {
+	if userID, found := this.userResolver.GetUserID(user); found {
+		return utils.ToSlackMention(userID)
+	}
+	return user.Name
+}
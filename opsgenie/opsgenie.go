@@ -12,6 +12,8 @@ import (
 	"strconv"
 	"time"
 
+	"bobby/metrics"
+
 	"github.com/codeship/go-retro"
 )
 
@@ -21,6 +23,8 @@ const (
 	opsgenieHost   = "api.opsgenie.com"
 	opsgeniePath   = "/v1/json/schedule/timeline"
 
+	opsgenieAccountPath = "/v2/account"
+
 	maxRetryAttempts = 3
 )
 
@@ -58,12 +62,14 @@ type scheduleTimeline struct {
 }
 
 type OpsgenieClient struct {
-	apiKey string
+	apiKey     string
+	httpClient *http.Client
 }
 
 func NewOpsgenieClient(apiKey string) *OpsgenieClient {
 	return &OpsgenieClient{
-		apiKey: apiKey,
+		apiKey:     apiKey,
+		httpClient: metrics.NewHTTPClient(metrics.UpstreamOpsgenie),
 	}
 }
 
@@ -89,7 +95,7 @@ func (this *OpsgenieClient) GetUsersOnDutyForDate(from, to time.Time, scheduleID
 		RawQuery: values.Encode(),
 	}
 
-	log.Printf("url: %s\n", opsgenieURL.String())
+	log.Printf("url: %s\n", redactURL(opsgenieURL.String()))
 
 	req := &http.Request{
 		Method:     "GET",
@@ -101,8 +107,13 @@ func (this *OpsgenieClient) GetUsersOnDutyForDate(from, to time.Time, scheduleID
 	}
 
 	var timeline *scheduleTimeline
+	attempts := 0
 	makeRequstError := retro.DoWithRetry(func() error {
-		result, err := makeRequst(req)
+		if attempts++; attempts > 1 {
+			metrics.UpstreamRetries.WithLabelValues(metrics.UpstreamOpsgenie).Inc()
+		}
+
+		result, err := this.makeRequst(req)
 		if err != nil {
 			return retro.NewBackoffRetryableError(err, maxRetryAttempts)
 		}
@@ -116,9 +127,39 @@ func (this *OpsgenieClient) GetUsersOnDutyForDate(from, to time.Time, scheduleID
 	return convertScheduleTimelineToUserOnDuty(timeline), nil
 }
 
-func makeRequst(req *http.Request) (*scheduleTimeline, error) {
-	resp, err := http.DefaultClient.Do(req)
+// CheckAuth requests account info, it is a cheap check of the api key and opsgenie availability
+func (this *OpsgenieClient) CheckAuth() error {
+	opsgenieURL := url.URL{
+		Scheme: opsgenieSchema,
+		Host:   opsgenieHost,
+		Path:   opsgenieAccountPath,
+	}
+
+	req, err := http.NewRequest("GET", opsgenieURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "GenieKey "+this.apiKey)
+
+	resp, err := this.httpClient.Do(req)
 	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error check opsgenie auth: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (this *OpsgenieClient) makeRequst(req *http.Request) (*scheduleTimeline, error) {
+	resp, err := this.httpClient.Do(req)
+	if err != nil {
+		// request errors include url with api key
+		if urlErr, ok := err.(*url.Error); ok {
+			urlErr.URL = redactURL(urlErr.URL)
+		}
 		return nil, err
 	}
 	defer resp.Body.Close()
@@ -136,6 +177,21 @@ func makeRequst(req *http.Request) (*scheduleTimeline, error) {
 	return &timeline, nil
 }
 
+// redactURL hides api key in url for logs and errors
+func redactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "<invalid url>"
+	}
+
+	values := parsed.Query()
+	if len(values.Get("apiKey")) > 0 {
+		values.Set("apiKey", "redacted")
+		parsed.RawQuery = values.Encode()
+	}
+	return parsed.String()
+}
+
 func convertScheduleTimelineToUserOnDuty(timeline *scheduleTimeline) []UserOnDuty {
 	usersOnDuty := make([]UserOnDuty, 0, len(timeline.Timeline.FinalSchedule.Rotations))
 
@@ -116,3 +116,8 @@ func (suite *DailyMessengerTestSuite) TestJoinDutiesByUserName_Overlapped(c *C)
 	c.Assert(duties[0].Start, Equals, time.Date(2016, time.May, 17, 9, 0, 0, 0, time.Local))
 	c.Assert(duties[0].End, Equals, time.Date(2016, time.May, 18, 9, 0, 0, 0, time.Local))
 }
+
+func (suite *DailyMessengerTestSuite) TestRedactURL(c *C) {
+	c.Assert(redactURL("https://api.opsgenie.com/v1/json/schedule/timeline?apiKey=secret&name=ops"), Equals,
+		"https://api.opsgenie.com/v1/json/schedule/timeline?apiKey=redacted&name=ops")
+}
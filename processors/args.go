@@ -0,0 +1,404 @@
+package processors
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"bobby/utils"
+)
+
+type ArgType string
+
+const (
+	ArgDate     ArgType = "date"
+	ArgDuration ArgType = "duration"
+	ArgUser     ArgType = "user"
+	ArgEnum     ArgType = "enum"
+
+	HelpSubcommand = "help"
+	// CurrentUser stands for the user who sent the command in user arguments
+	CurrentUser = "me"
+
+	maxSuggestionDistance = 2
+)
+
+var (
+	dateKeywords = []string{"now", "today", "yesterday", "tomorrow"}
+
+	userMentionRegexp = regexp.MustCompile(`^<@([A-Z0-9]+)(?:\|([^>]+))?>$`)
+)
+
+// Arg describes positional argument or --flag of a subcommand
+type Arg struct {
+	Name     string
+	Type     ArgType
+	Help     string
+	Values   []string
+	Default  string
+	Optional bool
+}
+
+type Subcommand struct {
+	Name  string
+	Help  string
+	Args  []Arg
+	Flags []Arg
+}
+
+// CommandSpec declares subcommands of a slash command. Text that doesn't start with
+// a subcommand name is parsed as arguments of DefaultSubcommand.
+type CommandSpec struct {
+	Help              string
+	DefaultSubcommand string
+	Subcommands       []*Subcommand
+}
+
+// UserArg is a user mention: <@U123|login>, <@U123> or @login
+type UserArg struct {
+	ID   string
+	Name string
+}
+
+// Args holds parsed and typed argument values by name
+type Args struct {
+	Subcommand string
+	// Requester is the user who sent the command
+	Requester UserArg
+	values    map[string]interface{}
+}
+
+func (this *Args) Date(name string) (time.Time, bool) {
+	value, found := this.values[name].(time.Time)
+	return value, found
+}
+
+func (this *Args) Duration(name string) (time.Duration, bool) {
+	value, found := this.values[name].(time.Duration)
+	return value, found
+}
+
+func (this *Args) User(name string) (UserArg, bool) {
+	value, found := this.values[name].(UserArg)
+	return value, found
+}
+
+func (this *Args) String(name string) (string, bool) {
+	value, found := this.values[name].(string)
+	return value, found
+}
+
+// Tokenize splits text by whitespace. Double quotes group words into one token, single quotes do it
+// only at the start of a token or of a flag value, so apostrophes in words (O'Brien) are kept.
+func Tokenize(text string) ([]string, error) {
+	var tokens []string
+	var token strings.Builder
+	var quote, prev rune
+	inToken := false
+
+	for _, r := range text {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			token.WriteRune(r)
+		case r == '"', r == '\'' && (!inToken || prev == '='):
+			quote = r
+			inToken = true
+		case unicode.IsSpace(r):
+			if inToken {
+				tokens = append(tokens, token.String())
+				token.Reset()
+				inToken = false
+			}
+		default:
+			token.WriteRune(r)
+			inToken = true
+		}
+		prev = r
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote %c", quote)
+	}
+
+	if inToken {
+		tokens = append(tokens, token.String())
+	}
+	return tokens, nil
+}
+
+func (this *CommandSpec) getSubcommand(name string) *Subcommand {
+	for _, subcommand := range this.Subcommands {
+		if subcommand.Name == name {
+			return subcommand
+		}
+	}
+	return nil
+}
+
+// Parse parses command text. Errors contain usage of the command.
+func (this *CommandSpec) Parse(commandName, text string, now time.Time) (*Args, error) {
+	tokens, err := Tokenize(text)
+	if err != nil {
+		return nil, this.usageError(commandName, nil, err.Error())
+	}
+
+	for _, token := range tokens {
+		if token == "--help" || token == "-h" {
+			return &Args{Subcommand: HelpSubcommand}, nil
+		}
+	}
+
+	if len(tokens) > 0 && tokens[0] == HelpSubcommand {
+		return &Args{Subcommand: HelpSubcommand}, nil
+	}
+
+	subcommand := this.getSubcommand(this.DefaultSubcommand)
+	if len(tokens) > 0 {
+		if found := this.getSubcommand(tokens[0]); found != nil {
+			subcommand = found
+			tokens = tokens[1:]
+		}
+	}
+
+	if subcommand == nil {
+		message := "unknown subcommand"
+		if len(tokens) > 0 {
+			message = fmt.Sprintf("unknown subcommand %q%s", tokens[0], suggest(tokens[0], this.subcommandNames()))
+		}
+		return nil, this.usageError(commandName, nil, message)
+	}
+
+	args, err := subcommand.parse(tokens, now)
+	if err != nil {
+		return nil, this.usageError(commandName, subcommand, err.Error())
+	}
+	return args, nil
+}
+
+func (this *CommandSpec) subcommandNames() []string {
+	names := make([]string, 0, len(this.Subcommands)+1)
+	for _, subcommand := range this.Subcommands {
+		names = append(names, subcommand.Name)
+	}
+	return append(names, HelpSubcommand)
+}
+
+func (this *CommandSpec) usageError(commandName string, subcommand *Subcommand, message string) error {
+	usage := "See /" + commandName + " help"
+	if subcommand != nil {
+		usage = "Usage: " + subcommand.usage(commandName, this.DefaultSubcommand) + "\n" + usage
+	}
+	return fmt.Errorf("%s\n%s", message, usage)
+}
+
+func (this *Subcommand) getFlag(name string) *Arg {
+	for i := range this.Flags {
+		if this.Flags[i].Name == name {
+			return &this.Flags[i]
+		}
+	}
+	return nil
+}
+
+func (this *Subcommand) flagNames() []string {
+	names := make([]string, 0, len(this.Flags))
+	for _, flag := range this.Flags {
+		names = append(names, "--"+flag.Name)
+	}
+	return names
+}
+
+func (this *Subcommand) parse(tokens []string, now time.Time) (*Args, error) {
+	args := &Args{
+		Subcommand: this.Name,
+		values:     make(map[string]interface{}, len(this.Args)+len(this.Flags)),
+	}
+
+	position := 0
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+
+		if !strings.HasPrefix(token, "--") {
+			if position >= len(this.Args) {
+				return nil, fmt.Errorf("unexpected argument %q", token)
+			}
+
+			arg := &this.Args[position]
+			value, err := arg.parse(token, now)
+			if err != nil {
+				return nil, err
+			}
+			args.values[arg.Name] = value
+			position++
+			continue
+		}
+
+		name, value := token[2:], ""
+		hasValue := false
+		if index := strings.Index(name, "="); index >= 0 {
+			name, value, hasValue = name[:index], name[index+1:], true
+		}
+
+		flag := this.getFlag(name)
+		if flag == nil {
+			return nil, fmt.Errorf("unknown flag %q%s", token, suggest("--"+name, this.flagNames()))
+		}
+
+		if !hasValue {
+			if i+1 >= len(tokens) {
+				return nil, fmt.Errorf("flag --%s requires a %s value", flag.Name, flag.Type)
+			}
+			i++
+			value = tokens[i]
+		}
+
+		parsed, err := flag.parse(value, now)
+		if err != nil {
+			return nil, err
+		}
+		args.values[flag.Name] = parsed
+	}
+
+	for _, arg := range append(append([]Arg(nil), this.Args...), this.Flags...) {
+		if _, found := args.values[arg.Name]; found {
+			continue
+		}
+
+		if len(arg.Default) > 0 {
+			value, err := arg.parse(arg.Default, now)
+			if err != nil {
+				return nil, err
+			}
+			args.values[arg.Name] = value
+			continue
+		}
+
+		if !arg.Optional {
+			return nil, fmt.Errorf("missing required argument <%s>", arg.Name)
+		}
+	}
+	return args, nil
+}
+
+func (this *Arg) parse(value string, now time.Time) (interface{}, error) {
+	switch this.Type {
+	case ArgDate:
+		date, err := utils.GetDateFromArgs(value, now)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: expected %s or YYYY-MM-DD%s",
+				this.Name, value, strings.Join(dateKeywords, ", "), suggest(value, dateKeywords))
+		}
+		return date, nil
+	case ArgDuration:
+		duration, err := ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: expected duration like 6h, 30m or 7d", this.Name, value)
+		}
+		return duration, nil
+	case ArgUser:
+		return ParseUserMention(value)
+	case ArgEnum:
+		for _, allowed := range this.Values {
+			if value == allowed {
+				return value, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid %s %q: expected one of %s%s",
+			this.Name, value, strings.Join(this.Values, ", "), suggest(value, this.Values))
+	}
+	return value, nil
+}
+
+// ParseDuration parses go durations and additionally days (7d) and weeks (2w)
+func ParseDuration(value string) (time.Duration, error) {
+	if len(value) > 1 {
+		unit := time.Duration(0)
+		switch value[len(value)-1] {
+		case 'd':
+			unit = 24 * time.Hour
+		case 'w':
+			unit = 7 * 24 * time.Hour
+		}
+
+		if unit > 0 {
+			count, err := strconv.Atoi(value[:len(value)-1])
+			if err != nil || count < 0 {
+				return 0, fmt.Errorf("invalid duration %q", value)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return duration, nil
+}
+
+func ParseUserMention(value string) (UserArg, error) {
+	if match := userMentionRegexp.FindStringSubmatch(value); match != nil {
+		return UserArg{ID: match[1], Name: match[2]}, nil
+	}
+
+	if value == CurrentUser {
+		return UserArg{Name: CurrentUser}, nil
+	}
+
+	if strings.HasPrefix(value, "@") && len(value) > 1 {
+		return UserArg{Name: value[1:]}, nil
+	}
+	return UserArg{}, fmt.Errorf("invalid user %q: expected @mention or %s", value, CurrentUser)
+}
+
+// suggest returns " (did you mean X?)" for the closest candidate
+func suggest(value string, candidates []string) string {
+	best, bestDistance := "", maxSuggestionDistance+1
+	for _, candidate := range candidates {
+		if distance := levenshtein(value, candidate); distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+
+	if len(best) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %q?)", best)
+}
+
+func levenshtein(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	curr := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ar); i++ {
+		curr[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(br)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,82 @@
+package processors
+
+import (
+	"bytes"
+	"strings"
+
+	"bobby/utils"
+)
+
+func (this *Arg) placeholder() string {
+	return "<" + this.Name + ">"
+}
+
+func (this *Arg) describe() string {
+	description := this.Help
+	switch this.Type {
+	case ArgDate:
+		description += " (" + strings.Join(dateKeywords, ", ") + " or YYYY-MM-DD)"
+	case ArgDuration:
+		description += " (like 6h, 30m or 7d)"
+	case ArgUser:
+		description += " (@mention or " + CurrentUser + ")"
+	case ArgEnum:
+		description += " (" + strings.Join(this.Values, ", ") + ")"
+	}
+
+	if len(this.Default) > 0 {
+		description += ", default: " + this.Default
+	}
+	return description
+}
+
+func (this *Subcommand) usage(commandName, defaultSubcommand string) string {
+	parts := []string{"/" + commandName}
+	if this.Name == defaultSubcommand {
+		parts = append(parts, "["+this.Name+"]")
+	} else {
+		parts = append(parts, this.Name)
+	}
+
+	for _, arg := range this.Args {
+		if arg.Optional || len(arg.Default) > 0 {
+			parts = append(parts, "["+arg.placeholder()+"]")
+		} else {
+			parts = append(parts, arg.placeholder())
+		}
+	}
+
+	for _, flag := range this.Flags {
+		parts = append(parts, "[--"+flag.Name+" "+flag.placeholder()+"]")
+	}
+	return strings.Join(parts, " ")
+}
+
+// RenderHelp renders help text for all subcommands
+func (this *CommandSpec) RenderHelp(commandName string) string {
+	var buf bytes.Buffer
+	if len(this.Help) > 0 {
+		utils.LogIfErr(buf.WriteString(this.Help))
+		utils.LogIfErr(buf.WriteString("\n"))
+	}
+
+	utils.LogIfErr(buf.WriteString("Usage:\n"))
+	for _, subcommand := range this.Subcommands {
+		utils.LogIfErr(buf.WriteString("\t"))
+		utils.LogIfErr(buf.WriteString(subcommand.usage(commandName, this.DefaultSubcommand)))
+		utils.LogIfErr(buf.WriteString("\n\t\t"))
+		utils.LogIfErr(buf.WriteString(subcommand.Help))
+		utils.LogIfErr(buf.WriteString("\n"))
+
+		for _, arg := range subcommand.Args {
+			utils.LogIfErr(buf.WriteString("\t\t" + arg.placeholder() + " - " + arg.describe() + "\n"))
+		}
+
+		for _, flag := range subcommand.Flags {
+			utils.LogIfErr(buf.WriteString("\t\t--" + flag.Name + " - " + flag.describe() + "\n"))
+		}
+	}
+
+	utils.LogIfErr(buf.WriteString("\t/" + commandName + " help\n\t\tShow this help\n"))
+	return buf.String()
+}
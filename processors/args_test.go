@@ -0,0 +1,132 @@
+package processors
+
+import (
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+type ArgsTestSuite struct {
+	now time.Time
+}
+
+var _ = Suite(&ArgsTestSuite{})
+
+func (suite *ArgsTestSuite) SetUpSuite(c *C) {
+	suite.now = time.Date(2020, 3, 10, 12, 0, 0, 0, time.Local)
+}
+
+func (suite *ArgsTestSuite) TestTokenize(c *C) {
+	tokens, err := Tokenize(`  show  "last week"  --user='John Doe' `)
+	c.Assert(err, IsNil)
+	c.Assert(tokens, DeepEquals, []string{"show", "last week", "--user=John Doe"})
+
+	_, err = Tokenize(`show "today`)
+	c.Assert(err, ErrorMatches, "unterminated quote \"")
+}
+
+func (suite *ArgsTestSuite) TestTokenizeApostrophe(c *C) {
+	tokens, err := Tokenize(`show --user=O'Brien "Jane O'Neil" O'Hara`)
+	c.Assert(err, IsNil)
+	c.Assert(tokens, DeepEquals, []string{"show", "--user=O'Brien", "Jane O'Neil", "O'Hara"})
+}
+
+func (suite *ArgsTestSuite) TestParseDefaults(c *C) {
+	args, err := timeLogsCommandSpec.Parse("timelogs", "", suite.now)
+	c.Assert(err, IsNil)
+	c.Assert(args.Subcommand, Equals, "show")
+
+	date, found := args.Date("date")
+	c.Assert(found, Equals, true)
+	c.Assert(date, Equals, suite.now)
+
+	status, _ := args.String("status")
+	c.Assert(status, Equals, statusAll)
+
+	_, found = args.Duration("min")
+	c.Assert(found, Equals, false)
+}
+
+func (suite *ArgsTestSuite) TestParseFlags(c *C) {
+	args, err := timeLogsCommandSpec.Parse("timelogs", "2020-03-02 --min=6h --user <@U123|john.doe> --status missing", suite.now)
+	c.Assert(err, IsNil)
+
+	date, _ := args.Date("date")
+	c.Assert(date, Equals, time.Date(2020, 3, 2, 0, 0, 0, 0, time.Local))
+
+	min, _ := args.Duration("min")
+	c.Assert(min, Equals, 6*time.Hour)
+
+	user, _ := args.User("user")
+	c.Assert(user, Equals, UserArg{ID: "U123", Name: "john.doe"})
+
+	status, _ := args.String("status")
+	c.Assert(status, Equals, statusMissing)
+}
+
+func (suite *ArgsTestSuite) TestParseErrors(c *C) {
+	_, err := timeLogsCommandSpec.Parse("timelogs", "yesterdy", suite.now)
+	c.Assert(err, ErrorMatches, `(?s)invalid date "yesterdy".*\(did you mean "yesterday"\?\)\nUsage: /timelogs \[show\] .*\nSee /timelogs help`)
+
+	_, err = timeLogsCommandSpec.Parse("timelogs", "--status=mising", suite.now)
+	c.Assert(err, ErrorMatches, `(?s)invalid status "mising": expected one of all, missing, short \(did you mean "missing"\?\).*`)
+
+	_, err = timeLogsCommandSpec.Parse("timelogs", "--mim 6h", suite.now)
+	c.Assert(err, ErrorMatches, `(?s)unknown flag "--mim" \(did you mean "--min"\?\).*`)
+
+	_, err = timeLogsCommandSpec.Parse("timelogs", "--min", suite.now)
+	c.Assert(err, ErrorMatches, `(?s)flag --min requires a duration value.*`)
+
+	_, err = timeLogsCommandSpec.Parse("timelogs", "today tomorrow", suite.now)
+	c.Assert(err, ErrorMatches, `(?s)unexpected argument "tomorrow".*`)
+}
+
+func (suite *ArgsTestSuite) TestHelp(c *C) {
+	for _, text := range []string{"help", "--help", "today -h"} {
+		args, err := dutyCommandSpec.Parse("duty", text, suite.now)
+		c.Assert(err, IsNil)
+		c.Assert(args.Subcommand, Equals, HelpSubcommand)
+	}
+
+	help := timeLogsCommandSpec.RenderHelp("timelogs")
+	c.Assert(help, Matches, `(?s).*/timelogs \[show\] \[<date>\] \[--min <min>\] \[--user <user>\] \[--status <status>\].*`)
+	c.Assert(help, Matches, `(?s).*--status - which users to show \(all, missing, short\), default: all.*`)
+}
+
+func (suite *ArgsTestSuite) TestParseDuration(c *C) {
+	for value, expected := range map[string]time.Duration{
+		"30m": 30 * time.Minute,
+		"2d":  48 * time.Hour,
+		"1w":  7 * 24 * time.Hour,
+	} {
+		duration, err := ParseDuration(value)
+		c.Assert(err, IsNil)
+		c.Assert(duration, Equals, expected)
+	}
+
+	_, err := ParseDuration("-1d")
+	c.Assert(err, NotNil)
+}
+
+func (suite *ArgsTestSuite) TestParseUserMention(c *C) {
+	user, err := ParseUserMention("<@U123>")
+	c.Assert(err, IsNil)
+	c.Assert(user, Equals, UserArg{ID: "U123"})
+
+	user, err = ParseUserMention("@john.doe")
+	c.Assert(err, IsNil)
+	c.Assert(user, Equals, UserArg{Name: "john.doe"})
+
+	user, err = ParseUserMention("me")
+	c.Assert(err, IsNil)
+	c.Assert(user, Equals, UserArg{Name: CurrentUser})
+
+	_, err = ParseUserMention("john.doe")
+	c.Assert(err, ErrorMatches, `invalid user "john.doe": expected @mention or me`)
+}
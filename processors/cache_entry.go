@@ -0,0 +1,65 @@
+package processors
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+const staleTimeFormat = "15:04"
+
+// cacheEntry is a command result stored in cache. FailedAt marks failed refresh of a good entry,
+// it isn't refreshed again until error cache duration passes.
+type cacheEntry struct {
+	Text      string    `json:"text"`
+	Failed    bool      `json:"failed,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	FailedAt  time.Time `json:"failed_at,omitempty"`
+}
+
+func (this *cacheEntry) isFresh(now time.Time, duration time.Duration) bool {
+	return now.Before(this.CreatedAt.Add(duration))
+}
+
+func (this *cacheEntry) failedRecently(now time.Time, duration time.Duration) bool {
+	return !this.FailedAt.IsZero() && now.Before(this.FailedAt.Add(duration))
+}
+
+// staleText is entry text marked with the time it was computed at
+func (this *cacheEntry) staleText() string {
+	return this.Text + "\n_as of " + this.CreatedAt.Format(staleTimeFormat) + "_"
+}
+
+func (this *PostponedCommandProcessor) staleCacheDuration() time.Duration {
+	if this.StaleCacheDuration < this.CacheDuration {
+		return this.CacheDuration
+	}
+	return this.StaleCacheDuration
+}
+
+func (this *PostponedCommandProcessor) getCacheEntry(cacheKey string) (*cacheEntry, bool) {
+	data, found := this.Cache.Get(cacheKey)
+	if !found {
+		return nil, false
+	}
+
+	entry := &cacheEntry{}
+	if err := json.Unmarshal([]byte(data), entry); err != nil {
+		log.Printf("error decode cache entry %q: %s", cacheKey, err)
+		return nil, false
+	}
+	return entry, true
+}
+
+func (this *PostponedCommandProcessor) setCacheEntry(cacheKey string, entry *cacheEntry, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("error encode cache entry %q: %s", cacheKey, err)
+		return
+	}
+	this.Cache.Set(cacheKey, string(data), ttl)
+}
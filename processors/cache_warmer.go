@@ -0,0 +1,59 @@
+package processors
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// IWarmer is a command processor able to compute its default result ahead of requests
+type IWarmer interface {
+	Warm(commandName string, now time.Time) error
+}
+
+// ITeamWarmer is a command processor able to compute default result of a single team
+type ITeamWarmer interface {
+	WarmTeam(commandName, teamName string, now time.Time) error
+}
+
+// CacheWarmer precomputes default results of commands, so the first requests
+// after startup or a daily message are served from cache
+type CacheWarmer struct {
+	Manager *CommandProcessManager
+	// Command limits warming to a single command, all commands are warmed if it is empty
+	Command string
+	// Team limits warming to a single team, all teams are warmed if it is empty
+	Team string
+}
+
+func (this *CacheWarmer) Run(now time.Time) error {
+	this.Manager.lock.RLock()
+	warmers := make(map[string]IWarmer, len(this.Manager.processors))
+	for commandName, processor := range this.Manager.processors {
+		if len(this.Command) > 0 && commandName != this.Command {
+			continue
+		}
+		if warmer, ok := processor.(IWarmer); ok {
+			warmers[commandName] = warmer
+		}
+	}
+	this.Manager.lock.RUnlock()
+
+	var warmErr error
+	for commandName, warmer := range warmers {
+		if err := this.warm(warmer, commandName, now); err != nil {
+			log.Printf("error warm /%s cache: %s", commandName, err)
+			warmErr = fmt.Errorf("error warm /%s cache: %s", commandName, err)
+			continue
+		}
+		log.Printf("warmed /%s cache", commandName)
+	}
+	return warmErr
+}
+
+func (this *CacheWarmer) warm(warmer IWarmer, commandName string, now time.Time) error {
+	if teamWarmer, ok := warmer.(ITeamWarmer); ok && len(this.Team) > 0 {
+		return teamWarmer.WarmTeam(commandName, this.Team, now)
+	}
+	return warmer.Warm(commandName, now)
+}
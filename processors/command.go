@@ -1,21 +1,39 @@
 package processors
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 )
 
 // SlackCommand is a struct holding the values that slack will post to our bot
 type SlackCommand struct {
-	ChannelId   string
-	ChannelName string
-	UserId      string
-	UserName    string
-	Command     string
-	TeamId      string
-	TeamDomain  string
-	Text        string
-	Token       string
-	ResponseURL string
+	ChannelId   string `json:"channel_id"`
+	ChannelName string `json:"channel_name"`
+	UserId      string `json:"user_id"`
+	UserName    string `json:"user_name"`
+	Command     string `json:"command"`
+	TeamId      string `json:"team_id"`
+	TeamDomain  string `json:"team_domain"`
+	Text        string `json:"text"`
+	Token       string `json:"token"`
+	ResponseURL string `json:"response_url"`
+}
+
+// String formats command for logs without the verification token and the response url,
+// which allows anyone to post to the channel. Value receiver makes it work for both commands
+// and command pointers.
+func (this SlackCommand) String() string {
+	return fmt.Sprintf("{ChannelId:%s ChannelName:%s UserId:%s UserName:%s Command:%s TeamId:%s TeamDomain:%s Text:%s Token:%s ResponseURL:%s}",
+		this.ChannelId, this.ChannelName, this.UserId, this.UserName, this.Command, this.TeamId, this.TeamDomain,
+		this.Text, redact(this.Token), redact(this.ResponseURL))
+}
+
+func redact(value string) string {
+	if len(value) == 0 {
+		return ""
+	}
+	return "<redacted>"
 }
 
 // UnmarshalCommand takes the request from slack, returns a SlackCommand object
@@ -33,3 +51,16 @@ func UnmarshalCommand(r *http.Request) *SlackCommand {
 		ResponseURL: r.FormValue("response_url"),
 	}
 }
+
+// ParseCommandText makes a SlackCommand from free text like "duty tomorrow"
+// received in app mentions and interactive actions
+func ParseCommandText(text string) *SlackCommand {
+	parts := strings.SplitN(strings.TrimSpace(text), " ", 2)
+	command := &SlackCommand{
+		Command: "/" + strings.TrimPrefix(parts[0], "/"),
+	}
+	if len(parts) > 1 {
+		command.Text = parts[1]
+	}
+	return command
+}
@@ -6,15 +6,26 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"bobby/metrics"
+)
+
+// cache lookup results of postponed commands
+const (
+	CacheHit   = "hit"
+	CacheStale = "stale"
+	CacheMiss  = "miss"
 )
 
 type CommandResult struct {
 	Text      string
 	Postponed bool
+	// Cache is the result of cache lookup, it is empty if the command isn't cached (help, invalid arguments)
+	Cache string
 }
 
 type ICommandProcessor interface {
-	ProcessCommand(command *SlackCommand, now time.Time, args []string) CommandResult
+	ProcessCommand(command *SlackCommand, now time.Time) CommandResult
 	GetAuthToken() string
 }
 
@@ -35,10 +46,50 @@ func (this *CommandProcessManager) AddCommandProcessor(commandName string, proce
 	this.lock.Unlock()
 }
 
-func (this *CommandProcessManager) ProcessCommand(command *SlackCommand) (result CommandResult, err error) {
+// SetCommandProcessors replaces all command processors at once (on config reload).
+// Commands being processed are finished by the old processors.
+func (this *CommandProcessManager) SetCommandProcessors(processors map[string]ICommandProcessor) {
+	this.lock.Lock()
+	this.processors = processors
+	this.lock.Unlock()
+}
+
+func (this *CommandProcessManager) ProcessCommand(command *SlackCommand) (CommandResult, error) {
+	return this.processCommand(command, true)
+}
+
+// ProcessAuthenticatedCommand processes command received over an already authenticated connection
+// (socket mode) so command token isn't checked
+func (this *CommandProcessManager) ProcessAuthenticatedCommand(command *SlackCommand) (CommandResult, error) {
+	return this.processCommand(command, false)
+}
+
+// processCommand reports command outcome and cache lookup result to metrics
+func (this *CommandProcessManager) processCommand(command *SlackCommand, checkToken bool) (CommandResult, error) {
+	startedAt := time.Now()
+	commandName, result, err := this.dispatch(command, checkToken)
+
+	outcome := "answered"
+	switch {
+	case err != nil:
+		outcome = "error"
+	case result.Postponed:
+		outcome = "postponed"
+	}
+	metrics.Commands.WithLabelValues(commandName, outcome).Inc()
+	metrics.CommandDuration.WithLabelValues(commandName).Observe(time.Since(startedAt).Seconds())
+	if len(result.Cache) > 0 {
+		metrics.CacheRequests.WithLabelValues(commandName, result.Cache).Inc()
+	}
+	return result, err
+}
+
+// dispatch routes command to its processor. Command name is "unknown" if there is no such processor.
+func (this *CommandProcessManager) dispatch(command *SlackCommand, checkToken bool) (string, CommandResult, error) {
+	var result CommandResult
 	commandName := strings.Trim(command.Command, "/ ")
 	if len(commandName) == 0 {
-		return result, fmt.Errorf("empty command")
+		return "unknown", result, fmt.Errorf("empty command")
 	}
 
 	this.lock.RLock()
@@ -46,20 +97,15 @@ func (this *CommandProcessManager) ProcessCommand(command *SlackCommand) (result
 	this.lock.RUnlock()
 
 	if !found {
-		return result, fmt.Errorf("unknown command %q", commandName)
+		return "unknown", result, fmt.Errorf("unknown command %q", commandName)
 	}
 
-	if command.Token != commandProcessor.GetAuthToken() {
-		return result, fmt.Errorf("validation failed: invalid token %q", command.Token)
+	if checkToken && command.Token != commandProcessor.GetAuthToken() {
+		return commandName, result, fmt.Errorf("validation failed: invalid token %q", command.Token)
 	}
 
-	var args []string
 	command.Text = strings.Trim(command.Text, "/ ")
-	if len(command.Text) != 0 {
-		args = strings.Split(command.Text, " ")
-	}
-
-	log.Printf("args: %+v len(%d)\n", args, len(args))
+	log.Printf("text: %q\n", command.Text)
 
-	return commandProcessor.ProcessCommand(command, time.Now(), args), nil
+	return commandName, commandProcessor.ProcessCommand(command, time.Now()), nil
 }
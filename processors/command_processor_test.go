@@ -0,0 +1,49 @@
+package processors
+
+import (
+	"fmt"
+
+	"bobby/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	. "gopkg.in/check.v1"
+)
+
+type CommandProcessManagerTestSuite struct{}
+
+var _ = Suite(&CommandProcessManagerTestSuite{})
+
+func (suite *CommandProcessManagerTestSuite) TestMetrics(c *C) {
+	manager := NewCommandProcessManager()
+	manager.AddCommandProcessor("metrics-test", &fakeCommandProcessor{name: "x"})
+
+	answered := metrics.Commands.WithLabelValues("metrics-test", "answered")
+	invalidToken := metrics.Commands.WithLabelValues("metrics-test", "error")
+	unknown := metrics.Commands.WithLabelValues("unknown", "error")
+	answeredBefore, invalidTokenBefore, unknownBefore := testutil.ToFloat64(answered), testutil.ToFloat64(invalidToken), testutil.ToFloat64(unknown)
+
+	result, err := manager.ProcessCommand(&SlackCommand{Command: "/metrics-test", Text: "tomorrow"})
+	c.Assert(err, IsNil)
+	c.Assert(result.Text, Equals, "x:tomorrow")
+
+	_, err = manager.ProcessCommand(&SlackCommand{Command: "/metrics-test", Token: "wrong"})
+	c.Assert(err, ErrorMatches, "validation failed: .*")
+
+	_, err = manager.ProcessCommand(&SlackCommand{Command: "/nope"})
+	c.Assert(err, ErrorMatches, `unknown command "nope"`)
+
+	c.Assert(testutil.ToFloat64(answered)-answeredBefore, Equals, float64(1))
+	c.Assert(testutil.ToFloat64(invalidToken)-invalidTokenBefore, Equals, float64(1))
+	c.Assert(testutil.ToFloat64(unknown)-unknownBefore, Equals, float64(1))
+}
+
+func (suite *CommandProcessManagerTestSuite) TestCommandString(c *C) {
+	command := &SlackCommand{
+		Command:     "/duty",
+		Text:        "tomorrow",
+		Token:       "verification-secret",
+		ResponseURL: "https://hooks.slack.com/commands/T1/1/secret",
+	}
+	c.Assert(fmt.Sprintf("%+v", command), Equals, "{ChannelId: ChannelName: UserId: UserName: Command:/duty "+
+		"TeamId: TeamDomain: Text:tomorrow Token:<redacted> ResponseURL:<redacted>}")
+}
@@ -17,30 +17,58 @@ type IDutyProvider interface {
 	GetUsersOnDutyForDate(from, to time.Time, scheduleID string) ([]opsgenie.UserOnDuty, error)
 }
 
+var dutyCommandSpec = &CommandSpec{
+	Help:              "Shows who is on duty",
+	DefaultSubcommand: "show",
+	Subcommands: []*Subcommand{
+		{
+			Name: "show",
+			Help: "Show who is on duty now and next",
+			Args: []Arg{
+				{Name: "date", Type: ArgDate, Help: "day to show duties from", Default: "now"},
+			},
+			Flags: []Arg{
+				{Name: "period", Type: ArgDuration, Help: "how far ahead to show duties", Default: "24h"},
+			},
+		},
+	},
+}
+
 type DutyCommandProcessor struct {
-	DutyProvider  IDutyProvider
-	ScheduleID    string
+	DutyProvider IDutyProvider
+	ScheduleID   string
+}
+
+// dutyQuery is a single /duty request. It is never modified after creation
+// so concurrent requests don't share any state.
+type dutyQuery struct {
+	dutyProvider  IDutyProvider
+	scheduleID    string
 	from, to, now time.Time
 }
 
-func (this *DutyCommandProcessor) Init(args []string, now time.Time) (err error) {
-	this.now = now
-	if len(args) == 0 {
-		this.from = now
-		this.to = now.Add(24 * time.Hour)
-		return
-	}
-	this.from, err = utils.GetDateFromArgs(args[0], now)
-	this.to = this.from.Add(24 * time.Hour)
-	return
+func (this *DutyCommandProcessor) GetCommandSpec() *CommandSpec {
+	return dutyCommandSpec
+}
+
+func (this *DutyCommandProcessor) NewQuery(args *Args, now time.Time) (IQuery, error) {
+	from, _ := args.Date("date")
+	period, _ := args.Duration("period")
+	return &dutyQuery{
+		dutyProvider: this.DutyProvider,
+		scheduleID:   this.ScheduleID,
+		from:         from,
+		to:           from.Add(period),
+		now:          now,
+	}, nil
 }
 
-func (this *DutyCommandProcessor) GetCacheKey() string {
-	return strings.Join([]string{this.from.Format(dateFormatText), this.to.Format(dateFormatText)}, "_")
+func (this *dutyQuery) GetCacheKey() string {
+	return strings.Join([]string{this.from.Format(time.RFC3339), this.to.Sub(this.from).String(), this.scheduleID}, "_")
 }
 
-func (this *DutyCommandProcessor) Process() (string, error) {
-	usersOnDuty, err := this.DutyProvider.GetUsersOnDutyForDate(this.from, this.to, this.ScheduleID)
+func (this *dutyQuery) Execute() (string, error) {
+	usersOnDuty, err := this.dutyProvider.GetUsersOnDutyForDate(this.from, this.to, this.scheduleID)
 	if err != nil {
 		return "", err
 	}
@@ -49,10 +77,10 @@ func (this *DutyCommandProcessor) Process() (string, error) {
 
 	currentUserOnDuty, nextUsersOnDuty := opsgenie.SplitCurrentAndNextUsersOnDuty(this.now, usersOnDuty)
 
-	return this.renderText(currentUserOnDuty, nextUsersOnDuty), nil
+	return renderDutyText(currentUserOnDuty, nextUsersOnDuty), nil
 }
 
-func (this *DutyCommandProcessor) renderText(userOnDutyNow opsgenie.UserOnDuty, usersOnDuty []opsgenie.UserOnDuty) string {
+func renderDutyText(userOnDutyNow opsgenie.UserOnDuty, usersOnDuty []opsgenie.UserOnDuty) string {
 	var buf bytes.Buffer
 	buf.Grow(aproxMessageLength)
 	utils.LogIfErr(buf.WriteString(":phone: On duty:\nNow:\n\t"))
@@ -2,17 +2,47 @@ package processors
 
 import (
 	"log"
+	"strings"
+	"sync"
 	"time"
+
+	"bobby/metrics"
+	"bobby/utils"
 )
 
+// ResultProcessor builds a query per command request. Processors are shared between
+// requests, so everything request specific lives in the query.
 type ResultProcessor interface {
-	Init(args []string, now time.Time) error
+	GetCommandSpec() *CommandSpec
+	NewQuery(args *Args, now time.Time) (IQuery, error)
+}
+
+// IQuery is an immutable request built from parsed command arguments.
+// GetCacheKey identifies query arguments, command and subcommand names are added by the caller.
+type IQuery interface {
 	GetCacheKey() string
-	Process() (string, error)
+	Execute() (string, error)
 }
 
-type ISlackPostponedClient interface {
+// IPersonalQuery is a query whose result depends on the user who sent the command,
+// its results are cached per user
+type IPersonalQuery interface {
+	IsPersonal() bool
+}
+
+// IPostponedClient delivers postponed command results to slack or mattermost response url
+type IPostponedClient interface {
 	SendPostponedMessage(string, string) error
+	SendMessage(string, string) error
+}
+
+// background counts queries computed in background by all processors,
+// so shutdown can wait for their results to be posted
+var background utils.Inflight
+
+// Wait waits for queries being computed in background and reports if they finished within timeout
+func Wait(timeout time.Duration) bool {
+	return background.Wait(timeout)
 }
 
 type ICache interface {
@@ -20,50 +50,174 @@ type ICache interface {
 	Set(string, string, time.Duration)
 }
 
+// PostponedCommandProcessor replies with cached result or computes it in background
+// and posts to command response url. Results are fresh for CacheDuration, stale results
+// are served until StaleCacheDuration while being refreshed. Failures are cached
+// for ErrorCacheDuration and never replace a good result.
 type PostponedCommandProcessor struct {
-	SlackClient   ISlackPostponedClient
-	Cache         ICache
-	CacheDuration time.Duration
-	Processor     ResultProcessor
-	Token         string
+	Client             IPostponedClient
+	Cache              ICache
+	CacheDuration      time.Duration
+	StaleCacheDuration time.Duration
+	ErrorCacheDuration time.Duration
+	Processor          ResultProcessor
+	Token              string
+
+	lock     sync.Mutex
+	inflight map[string][]*SlackCommand
 }
 
 func (this *PostponedCommandProcessor) GetAuthToken() string {
 	return this.Token
 }
 
-func (this *PostponedCommandProcessor) ProcessCommand(command *SlackCommand, now time.Time, args []string) CommandResult {
-	var text string
-	if err := this.Processor.Init(args, now); err != nil {
-		text += err.Error()
+func (this *PostponedCommandProcessor) ProcessCommand(command *SlackCommand, now time.Time) CommandResult {
+	commandName := strings.Trim(command.Command, "/ ")
+	spec := this.Processor.GetCommandSpec()
+
+	args, err := spec.Parse(commandName, command.Text, now)
+	if err != nil {
+		return CommandResult{
+			Text: err.Error(),
+		}
+	}
+	args.Requester = UserArg{ID: command.UserId, Name: command.UserName}
+
+	if args.Subcommand == HelpSubcommand {
+		return CommandResult{
+			Text: spec.RenderHelp(commandName),
+		}
 	}
 
-	cacheKey := this.Processor.GetCacheKey()
-	log.Printf("cache key: %s\n", cacheKey)
-	if cachedText, found := this.Cache.Get(cacheKey); found {
-		log.Printf("cachedText: %q, found: %v\n", cachedText, found)
+	query, err := this.Processor.NewQuery(args, now)
+	if err != nil {
 		return CommandResult{
-			Text: cachedText,
+			Text: err.Error(),
 		}
 	}
 
-	go this.process(command, cacheKey, text)
+	cacheKey := getCacheKey(commandName, args.Subcommand, command.UserId, query)
+	log.Printf("cache key: %s\n", cacheKey)
+	if entry, found := this.getCacheEntry(cacheKey); found {
+		log.Printf("cached entry: %+v\n", entry)
+		if entry.Failed || entry.isFresh(now, this.CacheDuration) {
+			return CommandResult{
+				Text:  entry.Text,
+				Cache: CacheHit,
+			}
+		}
+
+		if !entry.failedRecently(now, this.ErrorCacheDuration) {
+			this.refresh(query, cacheKey, now)
+		}
+		return CommandResult{
+			Text:  entry.staleText(),
+			Cache: CacheStale,
+		}
+	}
 
+	this.refresh(query, cacheKey, now, command)
 	return CommandResult{
 		Postponed: true,
+		Cache:     CacheMiss,
 	}
 }
 
-func (this *PostponedCommandProcessor) process(command *SlackCommand, cacheKey, text string) {
-	processedText, err := this.Processor.Process()
-	this.Cache.Set(cacheKey, processedText, this.CacheDuration)
+// refresh starts query in background, unless the same query is already in-flight.
+// Commands get the result when it's ready.
+func (this *PostponedCommandProcessor) refresh(query IQuery, cacheKey string, now time.Time, commands ...*SlackCommand) {
+	if this.join(cacheKey, commands...) {
+		log.Printf("joined in-flight query: %s\n", cacheKey)
+		return
+	}
+
+	background.Add()
+	go func() {
+		defer background.Done()
+		if err := this.process(query, cacheKey, now); err != nil {
+			log.Printf("error process command %q: %s\n", cacheKey, err)
+		}
+	}()
+}
+
+// getCacheKey namespaces query cache key by command and subcommand, so different
+// commands with the same arguments don't share cached results
+func getCacheKey(commandName, subcommand, userID string, query IQuery) string {
+	parts := []string{commandName, subcommand, query.GetCacheKey()}
+	if personal, ok := query.(IPersonalQuery); ok && personal.IsPersonal() {
+		parts = append(parts, userID)
+	}
+	return strings.Join(parts, ":")
+}
+
+// join adds commands to waiters of the in-flight query with the same cache key.
+// It returns false if there is no such query, the caller has to start it then.
+func (this *PostponedCommandProcessor) join(cacheKey string, commands ...*SlackCommand) bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.inflight == nil {
+		this.inflight = make(map[string][]*SlackCommand)
+	}
+
+	waiters, found := this.inflight[cacheKey]
+	this.inflight[cacheKey] = append(waiters, commands...)
+	return found
+}
+
+// Warm computes and caches the result of the command without arguments
+func (this *PostponedCommandProcessor) Warm(commandName string, now time.Time) error {
+	args, err := this.Processor.GetCommandSpec().Parse(commandName, "", now)
 	if err != nil {
-		text += err.Error()
+		return err
 	}
 
-	text += processedText
+	query, err := this.Processor.NewQuery(args, now)
+	if err != nil {
+		return err
+	}
+
+	cacheKey := getCacheKey(commandName, args.Subcommand, "", query)
+	if this.join(cacheKey) {
+		return nil
+	}
+	return this.process(query, cacheKey, now)
+}
+
+func (this *PostponedCommandProcessor) process(query IQuery, cacheKey string, now time.Time) error {
+	startedAt := time.Now()
+	text, err := query.Execute()
+	metrics.QueryDuration.WithLabelValues(strings.SplitN(cacheKey, ":", 2)[0]).Observe(time.Since(startedAt).Seconds())
+	if err == nil {
+		this.setCacheEntry(cacheKey, &cacheEntry{Text: text, CreatedAt: now}, this.staleCacheDuration())
+	} else if entry, found := this.getCacheEntry(cacheKey); found && !entry.Failed {
+		log.Printf("keep last good result of %q: %s\n", cacheKey, err)
+		text = entry.staleText()
+		entry.FailedAt = now
+		this.setCacheEntry(cacheKey, entry, this.staleCacheDuration()-now.Sub(entry.CreatedAt))
+	} else {
+		text = err.Error() + text
+		this.setCacheEntry(cacheKey, &cacheEntry{Text: text, Failed: true, CreatedAt: now}, this.ErrorCacheDuration)
+	}
+
+	this.lock.Lock()
+	waiters := this.inflight[cacheKey]
+	delete(this.inflight, cacheKey)
+	this.lock.Unlock()
+
+	for _, command := range waiters {
+		if err := this.reply(command, text); err != nil {
+			log.Printf("%s\n", err)
+		}
+	}
+	return err
+}
 
-	if err := this.SlackClient.SendPostponedMessage(command.ResponseURL, text); err != nil {
-		log.Printf("%s\n", err)
+// reply sends text to command response url. Commands from app mentions have no response url
+// so text is posted to the channel instead.
+func (this *PostponedCommandProcessor) reply(command *SlackCommand, text string) error {
+	if len(command.ResponseURL) == 0 {
+		return this.Client.SendMessage(command.ChannelId, text)
 	}
+	return this.Client.SendPostponedMessage(command.ResponseURL, text)
 }
@@ -0,0 +1,368 @@
+package processors
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"bobby/config"
+	"bobby/opsgenie"
+
+	. "gopkg.in/check.v1"
+)
+
+// fakeDutyProvider returns a single duty named after the requested day
+type fakeDutyProvider struct{}
+
+func (this *fakeDutyProvider) GetUsersOnDutyForDate(from, to time.Time, scheduleID string) ([]opsgenie.UserOnDuty, error) {
+	time.Sleep(time.Millisecond)
+	return []opsgenie.UserOnDuty{
+		{Name: "user-" + from.Format(dateFormatText), Start: from, End: to},
+	}, nil
+}
+
+type fakeCache struct {
+	lock  sync.Mutex
+	items map[string]string
+}
+
+func (this *fakeCache) Get(key string) (string, bool) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	value, found := this.items[key]
+	return value, found
+}
+
+func (this *fakeCache) Set(key, value string, duration time.Duration) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.items[key] = value
+}
+
+type fakePostponedClient struct {
+	lock    sync.Mutex
+	wg      sync.WaitGroup
+	replies map[string]string
+}
+
+func (this *fakePostponedClient) SendPostponedMessage(responseURL, text string) error {
+	this.lock.Lock()
+	this.replies[responseURL] = text
+	this.lock.Unlock()
+	this.wg.Done()
+	return nil
+}
+
+func (this *fakePostponedClient) SendMessage(channelID, text string) error {
+	return this.SendPostponedMessage(channelID, text)
+}
+
+type PostponedCommandProcessorTestSuite struct{}
+
+var _ = Suite(&PostponedCommandProcessorTestSuite{})
+
+func (suite *PostponedCommandProcessorTestSuite) TestConcurrentRequests(c *C) {
+	const requests = 50
+
+	client := &fakePostponedClient{replies: make(map[string]string)}
+	processor := &PostponedCommandProcessor{
+		Client:        client,
+		Cache:         &fakeCache{items: make(map[string]string)},
+		CacheDuration: time.Minute,
+		Processor:     &DutyCommandProcessor{DutyProvider: &fakeDutyProvider{}},
+	}
+
+	now := time.Date(2020, 3, 10, 12, 0, 0, 0, time.Local)
+	texts := []string{"", "tomorrow", "yesterday"}
+	dates := []time.Time{now, now.Add(24 * time.Hour), now.Add(-24 * time.Hour)}
+	expected := make(map[string]string, requests)
+
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		text := texts[i%len(texts)]
+		responseURL := fmt.Sprintf("https://hooks.slack.com/commands/%d", i)
+		expected[responseURL] = "user-" + dates[i%len(dates)].Format(dateFormatText)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.wg.Add(1)
+			result := processor.ProcessCommand(&SlackCommand{
+				Command:     "/duty",
+				Text:        text,
+				ResponseURL: responseURL,
+			}, now)
+
+			if !result.Postponed {
+				c.Check(client.SendPostponedMessage(responseURL, result.Text), IsNil)
+			}
+		}()
+	}
+
+	wg.Wait()
+	client.wg.Wait()
+
+	c.Assert(len(client.replies), Equals, requests)
+	for responseURL, name := range expected {
+		c.Check(client.replies[responseURL], Matches, "(?s).*Now:\n\t"+name+" till .*")
+	}
+}
+
+// blockingDutyProvider counts calls and blocks them until release is closed
+type blockingDutyProvider struct {
+	lock    sync.Mutex
+	calls   int
+	release chan struct{}
+}
+
+func (this *blockingDutyProvider) GetUsersOnDutyForDate(from, to time.Time, scheduleID string) ([]opsgenie.UserOnDuty, error) {
+	this.lock.Lock()
+	this.calls++
+	this.lock.Unlock()
+
+	<-this.release
+	return []opsgenie.UserOnDuty{{Name: "John Doe", Start: from, End: to}}, nil
+}
+
+func (suite *PostponedCommandProcessorTestSuite) TestCoalesceRequests(c *C) {
+	const requests = 5
+
+	client := &fakePostponedClient{replies: make(map[string]string)}
+	provider := &blockingDutyProvider{release: make(chan struct{})}
+	processor := &PostponedCommandProcessor{
+		Client:        client,
+		Cache:         &fakeCache{items: make(map[string]string)},
+		CacheDuration: time.Minute,
+		Processor:     &DutyCommandProcessor{DutyProvider: provider},
+	}
+
+	now := time.Date(2020, 3, 10, 12, 0, 0, 0, time.Local)
+	client.wg.Add(requests)
+	for i := 0; i < requests; i++ {
+		result := processor.ProcessCommand(&SlackCommand{
+			Command:     "/duty",
+			Text:        "tomorrow",
+			ResponseURL: fmt.Sprintf("https://hooks.slack.com/commands/%d", i),
+		}, now)
+		c.Assert(result.Postponed, Equals, true)
+	}
+
+	close(provider.release)
+	client.wg.Wait()
+
+	c.Assert(provider.calls, Equals, 1)
+	c.Assert(len(client.replies), Equals, requests)
+	for _, text := range client.replies {
+		c.Check(text, Matches, "(?s).*John Doe.*")
+	}
+}
+
+func (suite *PostponedCommandProcessorTestSuite) TestWait(c *C) {
+	client := &fakePostponedClient{replies: make(map[string]string)}
+	provider := &blockingDutyProvider{release: make(chan struct{})}
+	processor := &PostponedCommandProcessor{
+		Client:        client,
+		Cache:         &fakeCache{items: make(map[string]string)},
+		CacheDuration: time.Minute,
+		Processor:     &DutyCommandProcessor{DutyProvider: provider},
+	}
+
+	client.wg.Add(1)
+	result := processor.ProcessCommand(&SlackCommand{
+		Command:     "/duty",
+		ResponseURL: "https://hooks.slack.com/commands/1",
+	}, time.Date(2020, 3, 10, 12, 0, 0, 0, time.Local))
+	c.Assert(result.Postponed, Equals, true)
+	c.Assert(Wait(10*time.Millisecond), Equals, false)
+
+	close(provider.release)
+	c.Assert(Wait(time.Second), Equals, true)
+	c.Assert(client.replies["https://hooks.slack.com/commands/1"], Matches, "(?s).*John Doe.*")
+}
+
+func (suite *PostponedCommandProcessorTestSuite) TestCacheKeys(c *C) {
+	now := time.Date(2020, 3, 10, 12, 0, 0, 0, time.Local)
+	team := []config.User{
+		{Name: "John Doe", JiraLogin: "john.doe", SlackLogin: "@john"},
+		{Name: "Jane Roe", JiraLogin: "jane.roe", SlackLogin: "@jane"},
+	}
+	timelogs := &TimeLogsCommandProcessor{Team: team, MinimumTimeSpent: 6 * time.Hour}
+	duty := &DutyCommandProcessor{}
+
+	getKey := func(processor ResultProcessor, commandName, text string, requester UserArg) string {
+		args, err := processor.GetCommandSpec().Parse(commandName, text, now)
+		c.Assert(err, IsNil)
+		args.Requester = requester
+
+		query, err := processor.NewQuery(args, now)
+		c.Assert(err, IsNil)
+		return getCacheKey(commandName, args.Subcommand, requester.ID, query)
+	}
+
+	john := UserArg{ID: "U1", Name: "john"}
+	jane := UserArg{ID: "U2", Name: "jane"}
+	c.Assert(getKey(duty, "duty", "2020-03-10", john), Not(Equals), getKey(timelogs, "timelogs", "2020-03-10", john))
+	c.Assert(getKey(timelogs, "timelogs", "", john), Equals, getKey(timelogs, "timelogs", "", jane))
+	c.Assert(getKey(timelogs, "timelogs", "--user me", john), Not(Equals), getKey(timelogs, "timelogs", "--user me", jane))
+	c.Assert(getKey(timelogs, "timelogs", "--user me", john), Matches, "timelogs:show:.*john.doe:U1")
+	c.Assert(getKey(duty, "duty", "2020-03-10", john), Not(Equals), getKey(duty, "duty", "2021-03-10", john))
+	c.Assert(getKey(duty, "duty", "2020-03-10 --period 24h", john), Not(Equals), getKey(duty, "duty", "2020-03-10 --period 48h", john))
+	c.Assert(getKey(timelogs, "timelogs", "2020-03-10", john), Not(Equals), getKey(timelogs, "timelogs", "2021-03-10", john))
+}
+
+// fakeUserResolver resolves users by slack login
+type fakeUserResolver map[string]string
+
+func (this fakeUserResolver) GetUserID(user config.User) (string, bool) {
+	userID, found := this[user.SlackLogin]
+	return userID, found
+}
+
+func (suite *PostponedCommandProcessorTestSuite) TestUserMention(c *C) {
+	now := time.Date(2020, 3, 10, 12, 0, 0, 0, time.Local)
+	team := []config.User{
+		{Name: "John Doe", JiraLogin: "john.doe", SlackLogin: "@john"},
+		{Name: "Jane Roe", JiraLogin: "jane.roe", SlackLogin: "@jane"},
+	}
+	timelogs := &TimeLogsCommandProcessor{
+		Team:         team,
+		UserResolver: fakeUserResolver{"@john": "U1", "@jane": "U2"},
+	}
+
+	getUsers := func(text string) ([]string, error) {
+		args, err := timelogs.GetCommandSpec().Parse("timelogs", text, now)
+		c.Assert(err, IsNil)
+		args.Requester = UserArg{ID: "U2", Name: "jane"}
+
+		query, err := timelogs.NewQuery(args, now)
+		if err != nil {
+			return nil, err
+		}
+		return query.(*timeLogsQuery).users, nil
+	}
+
+	users, err := getUsers("--user <@U1>")
+	c.Assert(err, IsNil)
+	c.Assert(users, DeepEquals, []string{"john.doe"})
+
+	users, err = getUsers("--user <@U2|jane>")
+	c.Assert(err, IsNil)
+	c.Assert(users, DeepEquals, []string{"jane.roe"})
+
+	users, err = getUsers("--user @john")
+	c.Assert(err, IsNil)
+	c.Assert(users, DeepEquals, []string{"john.doe"})
+
+	users, err = getUsers("--user me")
+	c.Assert(err, IsNil)
+	c.Assert(users, DeepEquals, []string{"jane.roe"})
+
+	_, err = getUsers("--user <@U3>")
+	c.Assert(err, NotNil)
+}
+
+func (suite *PostponedCommandProcessorTestSuite) TestCacheWarmer(c *C) {
+	cache := &fakeCache{items: make(map[string]string)}
+	manager := NewCommandProcessManager()
+	manager.AddCommandProcessor("duty", &PostponedCommandProcessor{
+		Client:        &fakePostponedClient{replies: make(map[string]string)},
+		Cache:         cache,
+		CacheDuration: time.Minute,
+		Processor:     &DutyCommandProcessor{DutyProvider: &fakeDutyProvider{}},
+	})
+
+	now := time.Date(2020, 3, 10, 12, 0, 0, 0, time.Local)
+	(&CacheWarmer{Manager: manager}).Run(now)
+
+	c.Assert(cache.items, HasLen, 1)
+	for key, text := range cache.items {
+		c.Check(key, Matches, "duty:show:.*")
+		c.Check(text, Matches, "(?s).*user-"+now.Format(dateFormatText)+".*")
+	}
+}
+
+// failingDutyProvider fails when err is set and counts calls
+type failingDutyProvider struct {
+	lock  sync.Mutex
+	calls int
+	err   error
+}
+
+func (this *failingDutyProvider) GetUsersOnDutyForDate(from, to time.Time, scheduleID string) ([]opsgenie.UserOnDuty, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.calls++
+	if this.err != nil {
+		return nil, this.err
+	}
+	return []opsgenie.UserOnDuty{{Name: "John Doe", Start: from, End: to}}, nil
+}
+
+func waitIdle(processor *PostponedCommandProcessor) {
+	for {
+		processor.lock.Lock()
+		idle := len(processor.inflight) == 0
+		processor.lock.Unlock()
+		if idle {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (suite *PostponedCommandProcessorTestSuite) TestStaleWhileRevalidate(c *C) {
+	client := &fakePostponedClient{replies: make(map[string]string)}
+	provider := &failingDutyProvider{}
+	processor := &PostponedCommandProcessor{
+		Client:             client,
+		Cache:              &fakeCache{items: make(map[string]string)},
+		CacheDuration:      time.Minute,
+		StaleCacheDuration: time.Hour,
+		ErrorCacheDuration: 30 * time.Second,
+		Processor:          &DutyCommandProcessor{DutyProvider: provider},
+	}
+
+	now := time.Date(2020, 3, 10, 12, 0, 0, 0, time.Local)
+	command := &SlackCommand{Command: "/duty", Text: "2020-03-10", ResponseURL: "https://hooks.slack.com/commands/1"}
+
+	client.wg.Add(1)
+	c.Assert(processor.ProcessCommand(command, now).Postponed, Equals, true)
+	client.wg.Wait()
+	waitIdle(processor)
+	good := client.replies[command.ResponseURL]
+	c.Assert(good, Matches, "(?s).*John Doe.*")
+
+	result := processor.ProcessCommand(command, now.Add(30*time.Second))
+	c.Assert(result, Equals, CommandResult{Text: good, Cache: CacheHit})
+	c.Assert(provider.calls, Equals, 1)
+
+	provider.err = fmt.Errorf("opsgenie is down")
+	result = processor.ProcessCommand(command, now.Add(2*time.Minute))
+	c.Assert(result, Equals, CommandResult{Text: good + "\n_as of 12:00_", Cache: CacheStale})
+	waitIdle(processor)
+	c.Assert(provider.calls, Equals, 2)
+
+	// failed refresh isn't retried until error cache duration passes
+	result = processor.ProcessCommand(command, now.Add(2*time.Minute+10*time.Second))
+	c.Assert(result, Equals, CommandResult{Text: good + "\n_as of 12:00_", Cache: CacheStale})
+	waitIdle(processor)
+	c.Assert(provider.calls, Equals, 2)
+
+	result = processor.ProcessCommand(command, now.Add(3*time.Minute))
+	c.Assert(result, Equals, CommandResult{Text: good + "\n_as of 12:00_", Cache: CacheStale})
+	waitIdle(processor)
+	c.Assert(provider.calls, Equals, 3)
+
+	command = &SlackCommand{Command: "/duty", Text: "2020-03-11", ResponseURL: "https://hooks.slack.com/commands/2"}
+	client.wg.Add(1)
+	c.Assert(processor.ProcessCommand(command, now).Postponed, Equals, true)
+	client.wg.Wait()
+	waitIdle(processor)
+	c.Assert(client.replies[command.ResponseURL], Equals, "opsgenie is down")
+
+	calls := provider.calls
+	result = processor.ProcessCommand(command, now.Add(time.Second))
+	c.Assert(result, Equals, CommandResult{Text: "opsgenie is down", Cache: CacheHit})
+	c.Assert(provider.calls, Equals, calls)
+}
@@ -0,0 +1,117 @@
+package processors
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// TeamProcessor processes command for a single team
+type TeamProcessor struct {
+	Name string
+	// Channel is id or name of the team channel, commands sent from it are processed for the team
+	Channel   string
+	Processor ICommandProcessor
+}
+
+// TeamCommandProcessor routes command to the team processor. The team is taken from the leading
+// team name argument (/duty team-x tomorrow), otherwise from the channel the command is sent from.
+// A single team gets all commands.
+type TeamCommandProcessor struct {
+	Token string
+	Teams []TeamProcessor
+}
+
+func (this *TeamCommandProcessor) GetAuthToken() string {
+	return this.Token
+}
+
+func (this *TeamCommandProcessor) ProcessCommand(command *SlackCommand, now time.Time) CommandResult {
+	team, text, err := this.resolveTeam(command)
+	if err != nil {
+		return CommandResult{
+			Text: err.Error(),
+		}
+	}
+
+	// command is shared with in-flight queries, so it isn't modified
+	teamCommand := *command
+	teamCommand.Text = text
+	return team.Processor.ProcessCommand(&teamCommand, now)
+}
+
+// resolveTeam returns team processor and command text without the team name
+func (this *TeamCommandProcessor) resolveTeam(command *SlackCommand) (*TeamProcessor, string, error) {
+	text := strings.TrimSpace(command.Text)
+	tokens := strings.Fields(text)
+	if len(tokens) > 0 {
+		for i := range this.Teams {
+			if len(this.Teams[i].Name) > 0 && this.Teams[i].Name == tokens[0] {
+				return &this.Teams[i], strings.TrimSpace(text[len(tokens[0]):]), nil
+			}
+		}
+	}
+
+	if len(this.Teams) == 1 || (len(tokens) > 0 && tokens[0] == HelpSubcommand) {
+		return &this.Teams[0], text, nil
+	}
+
+	for i := range this.Teams {
+		if isTeamChannel(this.Teams[i].Channel, command) {
+			return &this.Teams[i], text, nil
+		}
+	}
+
+	commandName := strings.Trim(command.Command, "/ ")
+	return nil, "", fmt.Errorf("can't find team of this channel, use /%s <team> ...\nTeams: %s",
+		commandName, strings.Join(this.teamNames(), ", "))
+}
+
+func isTeamChannel(channel string, command *SlackCommand) bool {
+	if len(channel) == 0 {
+		return false
+	}
+	return channel == command.ChannelId || strings.TrimPrefix(channel, "#") == command.ChannelName
+}
+
+func (this *TeamCommandProcessor) teamNames() []string {
+	names := make([]string, 0, len(this.Teams))
+	for _, team := range this.Teams {
+		names = append(names, team.Name)
+	}
+	return names
+}
+
+// Warm computes default results of all teams
+func (this *TeamCommandProcessor) Warm(commandName string, now time.Time) error {
+	var warmErr error
+	for _, team := range this.Teams {
+		warmer, ok := team.Processor.(IWarmer)
+		if !ok {
+			continue
+		}
+
+		if err := warmer.Warm(commandName, now); err != nil {
+			log.Printf("error warm /%s cache of team %q: %s", commandName, team.Name, err)
+			warmErr = fmt.Errorf("error warm /%s cache of team %q: %s", commandName, team.Name, err)
+		}
+	}
+	return warmErr
+}
+
+// WarmTeam computes default result of the named team
+func (this *TeamCommandProcessor) WarmTeam(commandName, teamName string, now time.Time) error {
+	for _, team := range this.Teams {
+		if team.Name != teamName {
+			continue
+		}
+
+		warmer, ok := team.Processor.(IWarmer)
+		if !ok {
+			return nil
+		}
+		return warmer.Warm(commandName, now)
+	}
+	return fmt.Errorf("unknown team %q", teamName)
+}
@@ -0,0 +1,98 @@
+package processors
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// fakeCommandProcessor replies with its name and the command text
+type fakeCommandProcessor struct {
+	name string
+}
+
+func (this *fakeCommandProcessor) ProcessCommand(command *SlackCommand, now time.Time) CommandResult {
+	return CommandResult{Text: this.name + ":" + command.Text}
+}
+
+func (this *fakeCommandProcessor) GetAuthToken() string {
+	return ""
+}
+
+type TeamCommandProcessorTestSuite struct{}
+
+var _ = Suite(&TeamCommandProcessorTestSuite{})
+
+func (suite *TeamCommandProcessorTestSuite) TestResolveTeam(c *C) {
+	processor := &TeamCommandProcessor{
+		Teams: []TeamProcessor{
+			{Name: "team-x", Channel: "#team-x", Processor: &fakeCommandProcessor{name: "x"}},
+			{Name: "team-y", Channel: "C2", Processor: &fakeCommandProcessor{name: "y"}},
+		},
+	}
+
+	now := time.Date(2020, 3, 10, 12, 0, 0, 0, time.Local)
+	for _, test := range []struct {
+		command  SlackCommand
+		expected string
+	}{
+		{SlackCommand{ChannelName: "team-x", Text: "tomorrow"}, "x:tomorrow"},
+		{SlackCommand{ChannelId: "C2", Text: "tomorrow"}, "y:tomorrow"},
+		{SlackCommand{ChannelId: "C2", Text: "team-x  tomorrow"}, "x:tomorrow"},
+		{SlackCommand{ChannelId: "C3", Text: "team-y"}, "y:"},
+		{SlackCommand{ChannelId: "C3", Text: "help"}, "x:help"},
+	} {
+		command := test.command
+		command.Command = "/duty"
+		c.Check(processor.ProcessCommand(&command, now).Text, Equals, test.expected)
+		c.Check(command.Text, Equals, test.command.Text)
+	}
+
+	result := processor.ProcessCommand(&SlackCommand{Command: "/duty", ChannelId: "C3", Text: "tomorrow"}, now)
+	c.Assert(result.Text, Equals, "can't find team of this channel, use /duty <team> ...\nTeams: team-x, team-y")
+}
+
+func (suite *TeamCommandProcessorTestSuite) TestSingleTeam(c *C) {
+	processor := &TeamCommandProcessor{
+		Teams: []TeamProcessor{{Processor: &fakeCommandProcessor{name: "default"}}},
+	}
+
+	now := time.Date(2020, 3, 10, 12, 0, 0, 0, time.Local)
+	result := processor.ProcessCommand(&SlackCommand{Command: "/duty", ChannelId: "C3", Text: "tomorrow"}, now)
+	c.Assert(result.Text, Equals, "default:tomorrow")
+}
+
+func (suite *TeamCommandProcessorTestSuite) TestWarmTeams(c *C) {
+	cache := &fakeCache{items: make(map[string]string)}
+	newProcessor := func(scheduleID string) *PostponedCommandProcessor {
+		return &PostponedCommandProcessor{
+			Client:        &fakePostponedClient{replies: make(map[string]string)},
+			Cache:         cache,
+			CacheDuration: time.Minute,
+			Processor:     &DutyCommandProcessor{DutyProvider: &fakeDutyProvider{}, ScheduleID: scheduleID},
+		}
+	}
+
+	manager := NewCommandProcessManager()
+	manager.AddCommandProcessor("duty", &TeamCommandProcessor{
+		Teams: []TeamProcessor{
+			{Name: "team-x", Processor: newProcessor("schedule-x")},
+			{Name: "team-y", Processor: newProcessor("schedule-y")},
+		},
+	})
+
+	now := time.Date(2020, 3, 10, 12, 0, 0, 0, time.Local)
+	c.Assert((&CacheWarmer{Manager: manager, Command: "duty", Team: "team-y"}).Run(now), IsNil)
+	c.Assert(cache.items, HasLen, 1)
+	for key := range cache.items {
+		c.Check(key, Matches, "duty:show:.*_schedule-y")
+	}
+
+	c.Assert((&CacheWarmer{Manager: manager, Command: "timelogs"}).Run(now), IsNil)
+	c.Assert(cache.items, HasLen, 1)
+
+	c.Assert((&CacheWarmer{Manager: manager, Command: "duty", Team: "team-z"}).Run(now), ErrorMatches, `.*unknown team "team-z"`)
+
+	c.Assert((&CacheWarmer{Manager: manager}).Run(now), IsNil)
+	c.Assert(cache.items, HasLen, 2)
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	"bobby/config"
 	"bobby/jira"
 	"bobby/utils"
 )
@@ -13,42 +14,139 @@ type IJiraClient interface {
 	GetUsersLoggedLessThenMin([]string, time.Time, time.Time, time.Duration) ([]jira.UserTimeLog, error)
 }
 
+type IUserResolver interface {
+	GetUserID(user config.User) (string, bool)
+}
+
+const (
+	statusAll     = "all"
+	statusMissing = "missing"
+	statusShort   = "short"
+)
+
+var timeLogsCommandSpec = &CommandSpec{
+	Help:              "Shows who didn't log enough work time",
+	DefaultSubcommand: "show",
+	Subcommands: []*Subcommand{
+		{
+			Name: "show",
+			Help: "Show users who logged less than minimum on the previous working day before date",
+			Args: []Arg{
+				{Name: "date", Type: ArgDate, Help: "day after the checked one", Default: "today"},
+			},
+			Flags: []Arg{
+				{Name: "min", Type: ArgDuration, Help: "minimum time to log", Optional: true},
+				{Name: "user", Type: ArgUser, Help: "check only this team member", Optional: true},
+				{Name: "status", Type: ArgEnum, Help: "which users to show", Values: []string{statusAll, statusMissing, statusShort}, Default: statusAll},
+			},
+		},
+	},
+}
+
 type TimeLogsCommandProcessor struct {
 	JiraClient       IJiraClient
-	Users            []string
+	Team             []config.User
 	MinimumTimeSpent time.Duration
+	// UserResolver matches <@U123> mentions to team members, optional
+	UserResolver IUserResolver
+}
+
+// timeLogsQuery is a single /timelogs request. It is never modified after creation
+// so concurrent requests don't share any state.
+type timeLogsQuery struct {
+	jiraClient       IJiraClient
 	from, to         time.Time
+	users            []string
+	minimumTimeSpent time.Duration
+	status           string
+	personal         bool
 }
 
-func (this *TimeLogsCommandProcessor) Init(args []string, now time.Time) error {
-	if len(args) == 0 {
-		this.from, this.to = utils.GetPreviousDateRange(now)
-		return nil
+func (this *TimeLogsCommandProcessor) GetCommandSpec() *CommandSpec {
+	return timeLogsCommandSpec
+}
+
+func (this *TimeLogsCommandProcessor) NewQuery(args *Args, now time.Time) (IQuery, error) {
+	query := &timeLogsQuery{
+		jiraClient:       this.JiraClient,
+		minimumTimeSpent: this.MinimumTimeSpent,
+		users:            make([]string, 0, len(this.Team)),
 	}
 
-	date, err := utils.GetDateFromArgs(args[0], now)
-	if err != nil {
-		this.from, this.to = utils.GetPreviousDateRange(now)
-		return err
+	date, _ := args.Date("date")
+	query.from, query.to = utils.GetPreviousDateRange(date)
+	query.status, _ = args.String("status")
+
+	if min, found := args.Duration("min"); found {
+		query.minimumTimeSpent = min
+	}
+
+	user, found := args.User("user")
+	if found && user.Name == CurrentUser {
+		user, query.personal = args.Requester, true
+	}
+
+	for _, member := range this.Team {
+		if !found || this.isSameUser(user, member) {
+			query.users = append(query.users, member.JiraLogin)
+		}
+	}
+
+	if len(query.users) == 0 {
+		name := user.Name
+		if len(name) == 0 {
+			name = user.ID
+		}
+		return nil, fmt.Errorf("user %q is not a team member", name)
+	}
+	return query, nil
+}
+
+// isSameUser matches mention by slack ID when member is resolved, by login otherwise.
+// Mention <@U123> has no name and matches by ID only.
+func (this *TimeLogsCommandProcessor) isSameUser(user UserArg, member config.User) bool {
+	if len(user.ID) > 0 && this.UserResolver != nil {
+		if userID, found := this.UserResolver.GetUserID(member); found {
+			return userID == user.ID
+		}
 	}
 
-	this.from, this.to = utils.GetPreviousDateRange(date)
-	return nil
+	login := strings.TrimPrefix(member.SlackLogin, "@")
+	return len(user.Name) > 0 && (user.Name == login || user.Name == member.JiraLogin)
 }
 
-func (this *TimeLogsCommandProcessor) GetCacheKey() string {
-	return strings.Join([]string{this.from.Format(dateFormatText), this.to.Format(dateFormatText)}, "_")
+func (this *timeLogsQuery) GetCacheKey() string {
+	return strings.Join([]string{this.from.Format(time.RFC3339), this.to.Format(time.RFC3339),
+		this.minimumTimeSpent.String(), this.status, strings.Join(this.users, ",")}, "_")
 }
 
-func (this *TimeLogsCommandProcessor) Process() (string, error) {
-	usersLogs, err := this.JiraClient.GetUsersLoggedLessThenMin(this.Users, this.from, this.to, this.MinimumTimeSpent)
+func (this *timeLogsQuery) IsPersonal() bool {
+	return this.personal
+}
+
+func (this *timeLogsQuery) Execute() (string, error) {
+	usersLogs, err := this.jiraClient.GetUsersLoggedLessThenMin(this.users, this.from, this.to, this.minimumTimeSpent)
 	if err != nil {
 		return "", err
 	}
-	return this.renderText(usersLogs), nil
+	return this.renderText(filterUsersTimeLogs(usersLogs, this.status)), nil
+}
+
+func filterUsersTimeLogs(usersTimeLogs []jira.UserTimeLog, status string) []jira.UserTimeLog {
+	if status == statusAll {
+		return usersTimeLogs
+	}
+
+	result := make([]jira.UserTimeLog, 0, len(usersTimeLogs))
+	for _, usersLog := range usersTimeLogs {
+		if (usersLog.TimeSpent == 0) == (status == statusMissing) {
+			result = append(result, usersLog)
+		}
+	}
+	return result
 }
 
-func (this *TimeLogsCommandProcessor) renderText(usersTimeLogs []jira.UserTimeLog) string {
+func (this *timeLogsQuery) renderText(usersTimeLogs []jira.UserTimeLog) string {
 	var text string
 	if len(usersTimeLogs) > 0 {
 		rageNumber := 1
@@ -65,7 +163,7 @@ func (this *TimeLogsCommandProcessor) renderText(usersTimeLogs []jira.UserTimeLo
 			}
 		}
 	} else {
-		text = fmt.Sprintf("\n :simple_smile: No users with logged time less then %v\n", this.MinimumTimeSpent)
+		text = fmt.Sprintf("\n :simple_smile: No users with logged time less then %v\n", this.minimumTimeSpent)
 	}
 	return text
 }
@@ -0,0 +1,205 @@
+package roster
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"bobby/config"
+	"bobby/opsgenie"
+)
+
+const (
+	SourceConfig = "config"
+	SourceSlack  = "slack"
+	SourceJira   = "jira"
+	SourceLDAP   = "ldap"
+
+	dutyLookahead = 14 * 24 * time.Hour
+)
+
+// IDirectory lists group members as roster users with the fields known to the directory
+type IDirectory interface {
+	GetGroupMembers(group string) ([]config.User, error)
+}
+
+type IDutyProvider interface {
+	GetUsersOnDutyForDate(from, to time.Time, scheduleID string) ([]opsgenie.UserOnDuty, error)
+}
+
+// Source is a directory group of team members
+type Source struct {
+	Name      string
+	Group     string
+	Directory IDirectory
+}
+
+// Team is synced from its sources. Configured members are joined with synced ones,
+// their fields take precedence.
+type Team struct {
+	Name       string
+	Members    []config.User
+	Sources    []Source
+	ScheduleID string
+}
+
+// Report is the result of team sync
+type Report struct {
+	Team       string        `json:"team"`
+	SyncedAt   time.Time     `json:"synced_at"`
+	Members    []config.User `json:"members"`
+	Mismatches []string      `json:"mismatches"`
+	Error      string        `json:"error,omitempty"`
+}
+
+type joinedUser struct {
+	user    config.User
+	sources []string
+}
+
+// SyncTeam joins members of all team sources by email and reports users missing in some of them
+// and duty schedule names which map to nobody. Members aren't returned if any source fails,
+// so a directory outage doesn't empty the roster.
+func SyncTeam(team *Team, dutyProvider IDutyProvider, now time.Time) (*Report, error) {
+	report := &Report{
+		Team:     team.Name,
+		SyncedAt: now,
+	}
+
+	byEmail := make(map[string]*joinedUser)
+	var unjoined []config.User
+	add := func(source string, user config.User) {
+		email := strings.ToLower(strings.TrimSpace(user.Email))
+		if len(email) == 0 {
+			if source == SourceConfig {
+				unjoined = append(unjoined, user)
+			} else {
+				report.Mismatches = append(report.Mismatches, fmt.Sprintf("%s user %q has no email", source, displayName(user)))
+			}
+			return
+		}
+
+		joined, found := byEmail[email]
+		if !found {
+			joined = &joinedUser{}
+			byEmail[email] = joined
+		}
+		joined.user = merge(joined.user, user)
+		if len(joined.sources) == 0 || joined.sources[len(joined.sources)-1] != source {
+			joined.sources = append(joined.sources, source)
+		}
+	}
+
+	for _, user := range team.Members {
+		add(SourceConfig, user)
+	}
+
+	for _, source := range team.Sources {
+		users, err := source.Directory.GetGroupMembers(source.Group)
+		if err != nil {
+			return nil, fmt.Errorf("error get %s group %q members: %s", source.Name, source.Group, err)
+		}
+
+		for _, user := range users {
+			add(source.Name, user)
+		}
+	}
+
+	report.Members = append(report.Members, unjoined...)
+	for _, joined := range byEmail {
+		report.Members = append(report.Members, joined.user)
+		if missing := missingSources(team.Sources, joined.sources); len(missing) > 0 {
+			report.Mismatches = append(report.Mismatches, fmt.Sprintf("%s is in %s but not in %s",
+				displayName(joined.user), strings.Join(joined.sources, ", "), strings.Join(missing, ", ")))
+		}
+	}
+
+	sort.Slice(report.Members, func(i, j int) bool {
+		if report.Members[i].Name != report.Members[j].Name {
+			return report.Members[i].Name < report.Members[j].Name
+		}
+		return report.Members[i].Email < report.Members[j].Email
+	})
+
+	if len(team.ScheduleID) > 0 && dutyProvider != nil {
+		if err := checkDutyNames(report, dutyProvider, team.ScheduleID, now); err != nil {
+			report.Error = err.Error()
+		}
+	}
+
+	sort.Strings(report.Mismatches)
+	return report, nil
+}
+
+// merge fills empty fields of user with fields of other
+func merge(user, other config.User) config.User {
+	fields := []struct {
+		value *string
+		other string
+	}{
+		{&user.Name, other.Name},
+		{&user.Email, other.Email},
+		{&user.JiraLogin, other.JiraLogin},
+		{&user.SlackLogin, other.SlackLogin},
+		{&user.MattermostLogin, other.MattermostLogin},
+	}
+
+	for _, field := range fields {
+		if len(*field.value) == 0 {
+			*field.value = field.other
+		}
+	}
+	user.EmailDigest = user.EmailDigest || other.EmailDigest
+	return user
+}
+
+func missingSources(sources []Source, found []string) []string {
+	var missing []string
+	for _, source := range sources {
+		isFound := false
+		for _, name := range found {
+			isFound = isFound || name == source.Name
+		}
+
+		if !isFound {
+			missing = append(missing, source.Name)
+		}
+	}
+	return missing
+}
+
+// checkDutyNames reports names of upcoming duties which aren't names of any member,
+// daily messages can't mention such users
+func checkDutyNames(report *Report, dutyProvider IDutyProvider, scheduleID string, now time.Time) error {
+	usersOnDuty, err := dutyProvider.GetUsersOnDutyForDate(now, now.Add(dutyLookahead), scheduleID)
+	if err != nil {
+		return fmt.Errorf("error get users on duty: %s", err)
+	}
+
+	names := make(map[string]bool, len(report.Members))
+	for _, member := range report.Members {
+		names[member.Name] = true
+	}
+
+	reported := make(map[string]bool)
+	for _, userOnDuty := range usersOnDuty {
+		if !names[userOnDuty.Name] && !reported[userOnDuty.Name] {
+			reported[userOnDuty.Name] = true
+			report.Mismatches = append(report.Mismatches, fmt.Sprintf("opsgenie user %q maps to nobody", userOnDuty.Name))
+		}
+	}
+	return nil
+}
+
+func displayName(user config.User) string {
+	switch {
+	case len(user.Name) > 0 && len(user.Email) > 0:
+		return user.Name + " <" + user.Email + ">"
+	case len(user.Email) > 0:
+		return user.Email
+	case len(user.Name) > 0:
+		return user.Name
+	}
+	return user.JiraLogin + user.SlackLogin
+}
@@ -0,0 +1,132 @@
+package roster
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"bobby/config"
+	"bobby/opsgenie"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+type fakeDirectory struct {
+	groups map[string][]config.User
+	err    error
+}
+
+func (this *fakeDirectory) GetGroupMembers(group string) ([]config.User, error) {
+	if this.err != nil {
+		return nil, this.err
+	}
+	return this.groups[group], nil
+}
+
+type fakeDutyProvider []opsgenie.UserOnDuty
+
+func (this fakeDutyProvider) GetUsersOnDutyForDate(from, to time.Time, scheduleID string) ([]opsgenie.UserOnDuty, error) {
+	return this, nil
+}
+
+type RosterTestSuite struct {
+	ldap  *fakeDirectory
+	jira  *fakeDirectory
+	slack *fakeDirectory
+	team  *Team
+	now   time.Time
+}
+
+var _ = Suite(&RosterTestSuite{})
+
+func (suite *RosterTestSuite) SetUpTest(c *C) {
+	suite.ldap = &fakeDirectory{groups: map[string][]config.User{
+		"cn=team-x": {
+			{Name: "John Doe", Email: "John.Doe@example.com"},
+			{Name: "Jane Roe", Email: "jane.roe@example.com"},
+		},
+	}}
+	suite.jira = &fakeDirectory{groups: map[string][]config.User{
+		"team-x": {
+			{Name: "Doe, John", Email: "john.doe@example.com", JiraLogin: "jdoe"},
+		},
+	}}
+	suite.slack = &fakeDirectory{groups: map[string][]config.User{
+		"S1": {
+			{Name: "John", Email: "john.doe@example.com", SlackLogin: "john.doe"},
+			{Name: "Jane", Email: "jane.roe@example.com", SlackLogin: "jane"},
+			{Name: "Bot", SlackLogin: "bot"},
+		},
+	}}
+
+	suite.team = &Team{
+		Name:    "team-x",
+		Members: []config.User{{Email: "jane.roe@example.com", JiraLogin: "jane.roe", EmailDigest: true}},
+		Sources: []Source{
+			{Name: SourceLDAP, Group: "cn=team-x", Directory: suite.ldap},
+			{Name: SourceJira, Group: "team-x", Directory: suite.jira},
+			{Name: SourceSlack, Group: "S1", Directory: suite.slack},
+		},
+		ScheduleID: "schedule-x",
+	}
+	suite.now = time.Date(2020, 3, 10, 12, 0, 0, 0, time.Local)
+}
+
+func (suite *RosterTestSuite) TestSyncTeam(c *C) {
+	report, err := SyncTeam(suite.team, fakeDutyProvider{{Name: "John Doe"}, {Name: "Johnny"}, {Name: "Johnny"}}, suite.now)
+	c.Assert(err, IsNil)
+	c.Assert(report.Members, DeepEquals, []config.User{
+		{Name: "Jane Roe", Email: "jane.roe@example.com", JiraLogin: "jane.roe", SlackLogin: "jane", EmailDigest: true},
+		{Name: "John Doe", Email: "John.Doe@example.com", JiraLogin: "jdoe", SlackLogin: "john.doe"},
+	})
+	c.Assert(report.Mismatches, DeepEquals, []string{
+		"Jane Roe <jane.roe@example.com> is in config, ldap, slack but not in jira",
+		"opsgenie user \"Johnny\" maps to nobody",
+		"slack user \"Bot\" has no email",
+	})
+}
+
+func (suite *RosterTestSuite) TestSourceError(c *C) {
+	suite.jira.err = fmt.Errorf("jira is down")
+	_, err := SyncTeam(suite.team, nil, suite.now)
+	c.Assert(err, ErrorMatches, `error get jira group "team-x" members: jira is down`)
+}
+
+func (suite *RosterTestSuite) TestSyncer(c *C) {
+	syncer := NewSyncer(nil)
+	syncer.SetTeams([]Team{*suite.team})
+
+	changes := 0
+	syncer.OnChange(func() {
+		changes++
+	})
+
+	syncer.Sync(suite.now)
+	c.Assert(changes, Equals, 1)
+	c.Assert(syncer.Members()["team-x"], HasLen, 2)
+
+	syncer.Sync(suite.now.Add(time.Hour))
+	c.Assert(changes, Equals, 1)
+
+	suite.ldap.err = fmt.Errorf("ldap is down")
+	syncer.Sync(suite.now.Add(2 * time.Hour))
+	c.Assert(changes, Equals, 1)
+	c.Assert(syncer.Members()["team-x"], HasLen, 2)
+
+	suite.ldap.err = nil
+	suite.ldap.groups["cn=team-x"] = suite.ldap.groups["cn=team-x"][:1]
+	suite.slack.groups["S1"] = suite.slack.groups["S1"][:1]
+	suite.team.Members = nil
+	syncer.SetTeams([]Team{*suite.team})
+	syncer.Sync(suite.now.Add(3 * time.Hour))
+	c.Assert(changes, Equals, 2)
+	c.Assert(syncer.Members()["team-x"], HasLen, 1)
+
+	syncer.SetTeams(nil)
+	c.Assert(syncer.Members(), HasLen, 0)
+}
@@ -0,0 +1,154 @@
+package roster
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"bobby/config"
+)
+
+// Syncer periodically syncs team rosters and notifies when members change
+type Syncer struct {
+	dutyProvider IDutyProvider
+
+	lock      sync.Mutex
+	teams     []Team
+	reports   map[string]*Report
+	members   map[string][]config.User
+	callbacks []func()
+}
+
+func NewSyncer(dutyProvider IDutyProvider) *Syncer {
+	return &Syncer{
+		dutyProvider: dutyProvider,
+		reports:      make(map[string]*Report),
+		members:      make(map[string][]config.User),
+	}
+}
+
+// SetTeams replaces synced teams (on config reload). Members of removed teams are forgotten.
+func (this *Syncer) SetTeams(teams []Team) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.teams = teams
+	names := make(map[string]bool, len(teams))
+	for _, team := range teams {
+		names[team.Name] = true
+	}
+
+	for name := range this.members {
+		if !names[name] {
+			delete(this.members, name)
+			delete(this.reports, name)
+		}
+	}
+}
+
+// OnChange adds callback called after members of any team change
+func (this *Syncer) OnChange(callback func()) {
+	this.lock.Lock()
+	this.callbacks = append(this.callbacks, callback)
+	this.lock.Unlock()
+}
+
+// Members returns synced members by team name
+func (this *Syncer) Members() map[string][]config.User {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	members := make(map[string][]config.User, len(this.members))
+	for name, teamMembers := range this.members {
+		members[name] = teamMembers
+	}
+	return members
+}
+
+// Sync syncs all teams. A team whose sources fail keeps its previous members.
+func (this *Syncer) Sync(now time.Time) {
+	this.lock.Lock()
+	teams := this.teams
+	this.lock.Unlock()
+
+	changed := false
+	for i := range teams {
+		team := &teams[i]
+		report, err := SyncTeam(team, this.dutyProvider, now)
+		if err != nil {
+			log.Printf("error sync team %q roster: %s", team.Name, err)
+			this.lock.Lock()
+			if previous, found := this.reports[team.Name]; found {
+				previous.Error = err.Error()
+			} else {
+				this.reports[team.Name] = &Report{Team: team.Name, SyncedAt: now, Error: err.Error()}
+			}
+			this.lock.Unlock()
+			continue
+		}
+
+		for _, mismatch := range report.Mismatches {
+			log.Printf("team %q roster mismatch: %s", team.Name, mismatch)
+		}
+
+		this.lock.Lock()
+		this.reports[team.Name] = report
+		if !reflect.DeepEqual(this.members[team.Name], report.Members) {
+			this.members[team.Name] = report.Members
+			changed = true
+			log.Printf("team %q roster is updated: %d members", team.Name, len(report.Members))
+		}
+		this.lock.Unlock()
+	}
+
+	if !changed {
+		return
+	}
+
+	this.lock.Lock()
+	callbacks := append([]func(){}, this.callbacks...)
+	this.lock.Unlock()
+
+	for _, callback := range callbacks {
+		callback()
+	}
+}
+
+// Run syncs teams now and then every interval. It never returns.
+func (this *Syncer) Run(interval time.Duration) {
+	this.Sync(time.Now())
+	for now := range time.Tick(interval) {
+		this.Sync(now)
+	}
+}
+
+// ServeHTTP shows last sync report of every team on GET /admin/roster
+func (this *Syncer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	this.lock.Lock()
+	reports := make([]*Report, 0, len(this.reports))
+	for _, report := range this.reports {
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Team < reports[j].Team
+	})
+	data, err := json.Marshal(reports)
+	this.lock.Unlock()
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
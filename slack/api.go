@@ -0,0 +1,149 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	slackAPIURL = "https://slack.com/api/"
+)
+
+type apiResponse struct {
+	Ok    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+func (this *apiResponse) err(method string) error {
+	if this.Ok {
+		return nil
+	}
+	return &APIError{Method: method, Code: this.Error}
+}
+
+type apiResult interface {
+	err(method string) error
+}
+
+// callMethod calls slack web api method and unmarshals response into result
+func (this *Client) callMethod(method string, values url.Values, result apiResult) error {
+	return callAPI(context.Background(), this.httpClient, this.apiURL, this.token, method, values, result)
+}
+
+// CheckAuth calls auth.test, it is a cheap check of the token and slack availability
+func (this *Client) CheckAuth() error {
+	var response apiResponse
+	return this.callMethod("auth.test", url.Values{}, &response)
+}
+
+func callAPI(ctx context.Context, httpClient *http.Client, apiURL, token, method string, values url.Values, result apiResult) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL+method, strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponseStatus(method, resp); err != nil {
+		return err
+	}
+
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(responseBody, result); err != nil {
+		return fmt.Errorf("%s: error parse response: %s", method, err)
+	}
+
+	return result.err(method)
+}
+
+type slackUser struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Deleted bool   `json:"deleted"`
+	IsBot   bool   `json:"is_bot"`
+	Profile struct {
+		Email       string `json:"email"`
+		DisplayName string `json:"display_name"`
+		RealName    string `json:"real_name"`
+	} `json:"profile"`
+}
+
+type lookupByEmailResponse struct {
+	apiResponse
+	User slackUser `json:"user"`
+}
+
+func (this *Client) lookupUserByEmail(email string) (*slackUser, error) {
+	values := url.Values{}
+	values.Add("email", email)
+
+	var response lookupByEmailResponse
+	if err := this.callMethod("users.lookupByEmail", values, &response); err != nil {
+		return nil, err
+	}
+	return &response.User, nil
+}
+
+type usersListResponse struct {
+	apiResponse
+	Members          []slackUser `json:"members"`
+	ResponseMetadata struct {
+		NextCursor string `json:"next_cursor"`
+	} `json:"response_metadata"`
+}
+
+func (this *Client) listUsers() ([]slackUser, error) {
+	var users []slackUser
+	var cursor string
+	for {
+		values := url.Values{}
+		values.Add("limit", "200")
+		if len(cursor) > 0 {
+			values.Add("cursor", cursor)
+		}
+
+		var response usersListResponse
+		if err := this.callMethod("users.list", values, &response); err != nil {
+			return nil, err
+		}
+		users = append(users, response.Members...)
+
+		cursor = response.ResponseMetadata.NextCursor
+		if len(cursor) == 0 {
+			return users, nil
+		}
+	}
+}
+
+type conversationsOpenResponse struct {
+	apiResponse
+	Channel struct {
+		ID string `json:"id"`
+	} `json:"channel"`
+}
+
+func (this *Client) openConversation(userID string) (string, error) {
+	values := url.Values{}
+	values.Add("users", userID)
+
+	var response conversationsOpenResponse
+	if err := this.callMethod("conversations.open", values, &response); err != nil {
+		return "", err
+	}
+	return response.Channel.ID, nil
+}
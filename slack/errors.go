@@ -0,0 +1,77 @@
+package slack
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultRetryAfter = 30 * time.Second
+)
+
+// APIError is an error returned by slack web api in response body ("ok": false)
+type APIError struct {
+	Method string
+	Code   string
+}
+
+func (this *APIError) Error() string {
+	return fmt.Sprintf("%s: slack api error: %s", this.Method, this.Code)
+}
+
+func (this *APIError) Retryable() bool {
+	switch this.Code {
+	case "ratelimited", "internal_error", "fatal_error", "service_unavailable", "request_timeout":
+		return true
+	}
+	return false
+}
+
+// HTTPStatusError is returned when slack responds with unexpected http status
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (this *HTTPStatusError) Error() string {
+	return fmt.Sprintf("%s: http status: %s", this.URL, this.Status)
+}
+
+func (this *HTTPStatusError) Retryable() bool {
+	return this.StatusCode >= http.StatusInternalServerError
+}
+
+// RateLimitedError is returned when slack responds with 429 Too Many Requests
+type RateLimitedError struct {
+	URL   string
+	Delay time.Duration
+}
+
+func (this *RateLimitedError) Error() string {
+	return fmt.Sprintf("%s: rate limited, retry after %v", this.URL, this.Delay)
+}
+
+func (this *RateLimitedError) Retryable() bool {
+	return true
+}
+
+func (this *RateLimitedError) RetryAfter() time.Duration {
+	return this.Delay
+}
+
+func checkResponseStatus(url string, resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusTooManyRequests:
+		delay := defaultRetryAfter
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			delay = time.Duration(seconds) * time.Second
+		}
+		return &RateLimitedError{URL: url, Delay: delay}
+	}
+	return &HTTPStatusError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status}
+}
@@ -3,10 +3,11 @@ package slack
 import (
 	"bytes"
 	"encoding/json"
-	"fmt"
 	"net/http"
+	"net/url"
+	"sync"
 
-	sc "github.com/nlopes/slack"
+	"bobby/metrics"
 )
 
 const (
@@ -14,50 +15,84 @@ const (
 )
 
 type Client struct {
-	cli *sc.Client
+	token      string
+	apiURL     string
+	httpClient *http.Client
+
+	lock       sync.RWMutex
+	dmChannels map[string]string
 }
 
 func NewClient(token string) *Client {
 	return &Client{
-		cli: sc.New(token),
+		token:      token,
+		apiURL:     slackAPIURL,
+		httpClient: metrics.NewHTTPClient(metrics.UpstreamSlack),
+		dmChannels: make(map[string]string),
 	}
 }
 
 func (this *Client) SendMessage(channelID, text string) error {
-	_, _, err := this.cli.PostMessage(channelID, text, sc.PostMessageParameters{
-		Username: botUsername,
-		AsUser:   true,
-	})
-	return fmt.Errorf("fail send message to %q: %s", channelID, err)
+	return this.SendMessageWithEmoji(channelID, text, "")
 }
 
 func (this *Client) SendMessageWithEmoji(channelID, text, emoji string) error {
-	_, _, err := this.cli.PostMessage(channelID, text, sc.PostMessageParameters{
-		Username:  botUsername,
-		IconEmoji: emoji,
-		AsUser:    true,
-	})
-	return fmt.Errorf("fail send message to %q: %s", channelID, err)
+	values := url.Values{}
+	values.Add("channel", channelID)
+	values.Add("text", text)
+	values.Add("username", botUsername)
+	values.Add("as_user", "true")
+	if len(emoji) > 0 {
+		values.Add("icon_emoji", emoji)
+	}
+
+	var response apiResponse
+	return this.callMethod("chat.postMessage", values, &response)
 }
 
-func (this *Client) SendPostponedMessage(responseURL, message string) error {
-	fmt.Printf("responseURL: %s message: %s\n", responseURL, message)
+// SendDirectMessage opens (or reuses) a direct message channel with user and posts text to it
+func (this *Client) SendDirectMessage(userID, text string) error {
+	channelID, err := this.getDirectMessageChannel(userID)
+	if err != nil {
+		return err
+	}
+
+	return this.SendMessage(channelID, text)
+}
+
+func (this *Client) getDirectMessageChannel(userID string) (string, error) {
+	this.lock.RLock()
+	channelID, found := this.dmChannels[userID]
+	this.lock.RUnlock()
 
+	if found {
+		return channelID, nil
+	}
+
+	channelID, err := this.openConversation(userID)
+	if err != nil {
+		return "", err
+	}
+
+	this.lock.Lock()
+	this.dmChannels[userID] = channelID
+	this.lock.Unlock()
+	return channelID, nil
+}
+
+func (this *Client) SendPostponedMessage(responseURL, message string) error {
 	requestBody, err := json.Marshal(&SlackResult{Text: message})
 	if err != nil {
 		return err
 	}
 
-	resp, err := http.Post(responseURL, "application/json", bytes.NewBuffer(requestBody))
+	resp, err := this.httpClient.Post(responseURL, "application/json", bytes.NewBuffer(requestBody))
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("http status: %s", resp.Status)
-	}
-	return nil
+	return checkResponseStatus("response_url", resp)
 }
 
 // SlackResult holds the result of processing the command.  json encoding is the `payload`
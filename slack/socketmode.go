@@ -0,0 +1,252 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"bobby/metrics"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	envelopeTypeHello         = "hello"
+	envelopeTypeDisconnect    = "disconnect"
+	envelopeTypeSlashCommands = "slash_commands"
+	envelopeTypeEventsAPI     = "events_api"
+	envelopeTypeInteractive   = "interactive"
+
+	minReconnectDelay = 1 * time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// ISocketModeHandler handles payloads received over socket mode connection.
+// HandleSlashCommand result is sent back to slack within acknowledgement.
+type ISocketModeHandler interface {
+	HandleSlashCommand(payload json.RawMessage) (string, error)
+	HandleEvent(event *Event)
+	HandleInteraction(interaction *Interaction)
+}
+
+// Event is an events api event (app_mention, message.im)
+type Event struct {
+	Type        string `json:"type"`
+	User        string `json:"user"`
+	BotID       string `json:"bot_id"`
+	Text        string `json:"text"`
+	Channel     string `json:"channel"`
+	ChannelType string `json:"channel_type"`
+}
+
+// Interaction is a block_actions interactive payload
+type Interaction struct {
+	Type string `json:"type"`
+	User struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	Team struct {
+		ID     string `json:"id"`
+		Domain string `json:"domain"`
+	} `json:"team"`
+	Channel struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"channel"`
+	ResponseURL string `json:"response_url"`
+	Actions     []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+}
+
+type envelope struct {
+	EnvelopeID             string          `json:"envelope_id"`
+	Type                   string          `json:"type"`
+	Payload                json.RawMessage `json:"payload"`
+	AcceptsResponsePayload bool            `json:"accepts_response_payload"`
+	Reason                 string          `json:"reason"`
+}
+
+type acknowledgement struct {
+	EnvelopeID string      `json:"envelope_id"`
+	Payload    interface{} `json:"payload,omitempty"`
+}
+
+type eventCallback struct {
+	Type  string `json:"type"`
+	Event Event  `json:"event"`
+}
+
+type connectionsOpenResponse struct {
+	apiResponse
+	URL string `json:"url"`
+}
+
+// SocketModeClient receives slash commands, events and interactive payloads over websocket
+// so the bot doesn't need a public http endpoint
+type SocketModeClient struct {
+	appToken   string
+	apiURL     string
+	httpClient *http.Client
+	dialer     *websocket.Dialer
+	handler    ISocketModeHandler
+
+	lock sync.Mutex
+	conn *websocket.Conn
+	// ctx is cancelled on Stop, it interrupts connecting and reconnect delay
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewSocketModeClient(appToken string, handler ISocketModeHandler) *SocketModeClient {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &SocketModeClient{
+		appToken:   appToken,
+		apiURL:     slackAPIURL,
+		httpClient: metrics.NewHTTPClient(metrics.UpstreamSlack),
+		dialer:     websocket.DefaultDialer,
+		handler:    handler,
+		ctx:        ctx,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+}
+
+// Run connects to slack and serves connection. It reconnects when connection is closed until Stop is called.
+func (this *SocketModeClient) Run() {
+	defer close(this.done)
+
+	delay := minReconnectDelay
+	for {
+		conn, err := this.connect()
+		if err != nil {
+			log.Printf("socket mode: error connect: %s", err)
+			select {
+			case <-time.After(delay):
+			case <-this.ctx.Done():
+				return
+			}
+			if delay *= 2; delay > maxReconnectDelay {
+				delay = maxReconnectDelay
+			}
+			continue
+		}
+		delay = minReconnectDelay
+
+		if !this.setConn(conn) {
+			conn.Close()
+			return
+		}
+		if err := this.serve(conn); err != nil && !this.isStopped() {
+			log.Printf("socket mode: %s", err)
+		}
+		this.setConn(nil)
+		conn.Close()
+	}
+}
+
+// Stop closes connection, so no more payloads are received, and waits until Run returns within timeout
+func (this *SocketModeClient) Stop(timeout time.Duration) error {
+	this.lock.Lock()
+	this.cancel()
+	if this.conn != nil {
+		this.conn.Close()
+	}
+	this.lock.Unlock()
+
+	select {
+	case <-this.done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("socket mode connection isn't closed in %s", timeout)
+	}
+}
+
+// setConn remembers connection being served, so Stop can close it. It returns false if stopped.
+func (this *SocketModeClient) setConn(conn *websocket.Conn) bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.isStopped() {
+		return false
+	}
+	this.conn = conn
+	return true
+}
+
+func (this *SocketModeClient) isStopped() bool {
+	return this.ctx.Err() != nil
+}
+
+func (this *SocketModeClient) connect() (*websocket.Conn, error) {
+	var response connectionsOpenResponse
+	if err := callAPI(this.ctx, this.httpClient, this.apiURL, this.appToken, "apps.connections.open", url.Values{}, &response); err != nil {
+		return nil, err
+	}
+
+	conn, _, err := this.dialer.DialContext(this.ctx, response.URL, nil)
+	return conn, err
+}
+
+func (this *SocketModeClient) serve(conn *websocket.Conn) error {
+	for {
+		var env envelope
+		if err := conn.ReadJSON(&env); err != nil {
+			return fmt.Errorf("error read envelope: %s", err)
+		}
+
+		switch env.Type {
+		case envelopeTypeHello:
+			log.Printf("socket mode: connected")
+			continue
+		case envelopeTypeDisconnect:
+			return fmt.Errorf("disconnect requested: %s", env.Reason)
+		}
+
+		if err := conn.WriteJSON(this.handle(&env)); err != nil {
+			return fmt.Errorf("error write acknowledgement: %s", err)
+		}
+	}
+}
+
+func (this *SocketModeClient) handle(env *envelope) *acknowledgement {
+	ack := &acknowledgement{
+		EnvelopeID: env.EnvelopeID,
+	}
+
+	switch env.Type {
+	case envelopeTypeSlashCommands:
+		text, err := this.handler.HandleSlashCommand(env.Payload)
+		if err != nil {
+			text = fmt.Sprintf("Error: %q", err.Error())
+		}
+		if env.AcceptsResponsePayload && len(text) > 0 {
+			ack.Payload = &SlackResult{Text: text}
+		}
+	case envelopeTypeEventsAPI:
+		var callback eventCallback
+		if err := json.Unmarshal(env.Payload, &callback); err != nil {
+			log.Printf("socket mode: error parse event: %s", err)
+			break
+		}
+		go this.handler.HandleEvent(&callback.Event)
+	case envelopeTypeInteractive:
+		var interaction Interaction
+		if err := json.Unmarshal(env.Payload, &interaction); err != nil {
+			log.Printf("socket mode: error parse interactive payload: %s", err)
+			break
+		}
+		go this.handler.HandleInteraction(&interaction)
+	default:
+		log.Printf("socket mode: unknown envelope type %q", env.Type)
+	}
+	return ack
+}
@@ -0,0 +1,159 @@
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	. "gopkg.in/check.v1"
+)
+
+type testSocketModeHandler struct {
+	events chan *Event
+}
+
+func (this *testSocketModeHandler) HandleSlashCommand(payload json.RawMessage) (string, error) {
+	var command struct {
+		Command string `json:"command"`
+		Text    string `json:"text"`
+	}
+	if err := json.Unmarshal(payload, &command); err != nil {
+		return "", err
+	}
+	return command.Command + " " + command.Text, nil
+}
+
+func (this *testSocketModeHandler) HandleEvent(event *Event) {
+	this.events <- event
+}
+
+func (this *testSocketModeHandler) HandleInteraction(interaction *Interaction) {}
+
+type SocketModeTestSuite struct{}
+
+var _ = Suite(&SocketModeTestSuite{})
+
+func (suite *SocketModeTestSuite) TestServe(c *C) {
+	acks := make(chan acknowledgement, 2)
+	upgrader := websocket.Upgrader{}
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("/apps.connections.open", func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Authorization"), Equals, "Bearer xapp-token")
+		fmt.Fprintf(w, `{"ok":true,"url":"ws%s/link"}`, strings.TrimPrefix(server.URL, "http"))
+	})
+	mux.HandleFunc("/link", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		c.Assert(err, IsNil)
+		defer conn.Close()
+
+		conn.WriteJSON(map[string]interface{}{"type": "hello"})
+		conn.WriteJSON(map[string]interface{}{
+			"envelope_id":              "1",
+			"type":                     "slash_commands",
+			"accepts_response_payload": true,
+			"payload":                  map[string]string{"command": "/duty", "text": "tomorrow"},
+		})
+		conn.WriteJSON(map[string]interface{}{
+			"envelope_id": "2",
+			"type":        "events_api",
+			"payload": map[string]interface{}{
+				"type":  "event_callback",
+				"event": map[string]string{"type": "app_mention", "text": "<@U0BOT> duty", "channel": "C1"},
+			},
+		})
+		for i := 0; i < 2; i++ {
+			var ack acknowledgement
+			c.Check(conn.ReadJSON(&ack), IsNil)
+			acks <- ack
+		}
+		conn.WriteJSON(map[string]interface{}{"type": "disconnect", "reason": "refresh_requested"})
+	})
+
+	handler := &testSocketModeHandler{events: make(chan *Event, 1)}
+	client := NewSocketModeClient("xapp-token", handler)
+	client.apiURL = server.URL + "/"
+
+	conn, err := client.connect()
+	c.Assert(err, IsNil)
+	defer conn.Close()
+
+	err = client.serve(conn)
+	c.Assert(err, ErrorMatches, "disconnect requested: refresh_requested")
+
+	ack := <-acks
+	c.Assert(ack.EnvelopeID, Equals, "1")
+	c.Assert(ack.Payload, DeepEquals, map[string]interface{}{"text": "/duty tomorrow"})
+	ack = <-acks
+	c.Assert(ack.EnvelopeID, Equals, "2")
+	c.Assert(ack.Payload, IsNil)
+
+	event := <-handler.events
+	c.Assert(event.Type, Equals, "app_mention")
+	c.Assert(event.Channel, Equals, "C1")
+}
+
+func (suite *SocketModeTestSuite) TestStop(c *C) {
+	connected := make(chan struct{})
+	upgrader := websocket.Upgrader{}
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("/apps.connections.open", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"ok":true,"url":"ws%s/link"}`, strings.TrimPrefix(server.URL, "http"))
+	})
+	mux.HandleFunc("/link", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		c.Assert(err, IsNil)
+		defer conn.Close()
+
+		conn.WriteJSON(map[string]interface{}{"type": "hello"})
+		close(connected)
+		var ack acknowledgement
+		conn.ReadJSON(&ack)
+	})
+
+	client := NewSocketModeClient("xapp-token", &testSocketModeHandler{})
+	client.apiURL = server.URL + "/"
+
+	stopped := make(chan struct{})
+	go func() {
+		client.Run()
+		close(stopped)
+	}()
+
+	<-connected
+	c.Assert(client.Stop(time.Second), IsNil)
+	<-stopped
+}
+
+func (suite *SocketModeTestSuite) TestStopWhileConnecting(c *C) {
+	requested := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(requested)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	client := NewSocketModeClient("xapp-token", &testSocketModeHandler{})
+	client.apiURL = server.URL + "/"
+	go client.Run()
+
+	<-requested
+	c.Assert(client.Stop(time.Second), IsNil)
+}
@@ -0,0 +1,99 @@
+package slack
+
+import (
+	"net/url"
+	"strings"
+
+	"bobby/config"
+)
+
+type userGroupUsersResponse struct {
+	apiResponse
+	Users []string `json:"users"`
+}
+
+// GetUserGroupMembers returns IDs of users in user group
+func (this *Client) GetUserGroupMembers(userGroupID string) ([]string, error) {
+	values := url.Values{}
+	values.Add("usergroup", userGroupID)
+
+	var response userGroupUsersResponse
+	if err := this.callMethod("usergroups.users.list", values, &response); err != nil {
+		return nil, err
+	}
+	return response.Users, nil
+}
+
+// GetGroupMembers returns user group members as roster users with name, email and slack login.
+// Deactivated users and bots are skipped.
+func (this *Client) GetGroupMembers(userGroupID string) ([]config.User, error) {
+	ids, err := this.GetUserGroupMembers(userGroupID)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := this.listUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	membersByID := make(map[string]*slackUser, len(members))
+	for i := range members {
+		membersByID[members[i].ID] = &members[i]
+	}
+
+	users := make([]config.User, 0, len(ids))
+	for _, id := range ids {
+		member, found := membersByID[id]
+		if !found || member.Deleted || member.IsBot {
+			continue
+		}
+
+		users = append(users, config.User{
+			Name:       member.Profile.RealName,
+			Email:      member.Profile.Email,
+			SlackLogin: member.Name,
+		})
+	}
+	return users, nil
+}
+
+// UpdateUserGroupMembers replaces user group members with userIDs
+func (this *Client) UpdateUserGroupMembers(userGroupID string, userIDs []string) error {
+	values := url.Values{}
+	values.Add("usergroup", userGroupID)
+	values.Add("users", strings.Join(userIDs, ","))
+
+	var response apiResponse
+	return this.callMethod("usergroups.users.update", values, &response)
+}
+
+type conversationsInfoResponse struct {
+	apiResponse
+	Channel struct {
+		ID    string `json:"id"`
+		Topic struct {
+			Value string `json:"value"`
+		} `json:"topic"`
+	} `json:"channel"`
+}
+
+func (this *Client) GetChannelTopic(channelID string) (string, error) {
+	values := url.Values{}
+	values.Add("channel", channelID)
+
+	var response conversationsInfoResponse
+	if err := this.callMethod("conversations.info", values, &response); err != nil {
+		return "", err
+	}
+	return response.Channel.Topic.Value, nil
+}
+
+func (this *Client) SetChannelTopic(channelID, topic string) error {
+	values := url.Values{}
+	values.Add("channel", channelID)
+	values.Add("topic", topic)
+
+	var response apiResponse
+	return this.callMethod("conversations.setTopic", values, &response)
+}
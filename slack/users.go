@@ -0,0 +1,127 @@
+package slack
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"bobby/config"
+)
+
+// UserResolver maps roster users to slack user IDs.
+// Slack doesn't support @login addressing anymore so every DM and mention must use user ID.
+type UserResolver struct {
+	client *Client
+	users  []config.User
+
+	lock       sync.RWMutex
+	ids        map[string]string
+	unresolved []config.User
+}
+
+func NewUserResolver(client *Client, users []config.User) *UserResolver {
+	return &UserResolver{
+		client: client,
+		users:  users,
+		ids:    make(map[string]string, len(users)),
+	}
+}
+
+// SetUsers replaces roster users (on config reload) and resolves them
+func (this *UserResolver) SetUsers(users []config.User) error {
+	this.lock.Lock()
+	this.users = users
+	this.lock.Unlock()
+	return this.Refresh()
+}
+
+// Refresh resolves all roster users. Users with email are resolved with users.lookupByEmail,
+// the rest are matched against users.list by slack login.
+func (this *UserResolver) Refresh() error {
+	this.lock.RLock()
+	users := this.users
+	this.lock.RUnlock()
+
+	ids := make(map[string]string, len(users))
+	var unresolved []config.User
+	var members []slackUser
+
+	for _, user := range users {
+		if len(user.Email) > 0 {
+			slackUser, err := this.client.lookupUserByEmail(user.Email)
+			if err == nil {
+				ids[user.Key()] = slackUser.ID
+				continue
+			}
+			log.Printf("can't lookup slack user by email %q: %s", user.Email, err)
+		}
+
+		if members == nil {
+			var err error
+			if members, err = this.client.listUsers(); err != nil {
+				return err
+			}
+		}
+
+		if member := findMember(members, user); member != nil {
+			ids[user.Key()] = member.ID
+			continue
+		}
+
+		unresolved = append(unresolved, user)
+	}
+
+	this.lock.Lock()
+	this.ids = ids
+	this.unresolved = unresolved
+	this.lock.Unlock()
+
+	for _, user := range unresolved {
+		log.Printf("unresolved slack user: name: %q slack-login: %q email: %q", user.Name, user.SlackLogin, user.Email)
+	}
+	return nil
+}
+
+func findMember(members []slackUser, user config.User) *slackUser {
+	login := strings.TrimPrefix(user.SlackLogin, "@")
+	for i := range members {
+		member := &members[i]
+		if member.Deleted || member.IsBot {
+			continue
+		}
+
+		if len(user.Email) > 0 && strings.EqualFold(member.Profile.Email, user.Email) {
+			return member
+		}
+
+		if len(login) > 0 && (member.Name == login || member.Profile.DisplayName == login) {
+			return member
+		}
+	}
+	return nil
+}
+
+// Run refreshes users every interval. It never returns.
+func (this *UserResolver) Run(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := this.Refresh(); err != nil {
+			log.Printf("error refresh slack users: %s", err)
+		}
+	}
+}
+
+// GetUserID returns slack user ID of roster user
+func (this *UserResolver) GetUserID(user config.User) (string, bool) {
+	this.lock.RLock()
+	id, found := this.ids[user.Key()]
+	this.lock.RUnlock()
+	return id, found
+}
+
+// Unresolved returns roster users which have no slack account
+func (this *UserResolver) Unresolved() []config.User {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	return append([]config.User(nil), this.unresolved...)
+}
@@ -0,0 +1,133 @@
+package slack
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bobby/config"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+type UserResolverTestSuite struct {
+	server *httptest.Server
+	client *Client
+}
+
+var _ = Suite(&UserResolverTestSuite{})
+
+func (suite *UserResolverTestSuite) SetUpTest(c *C) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users.lookupByEmail", func(w http.ResponseWriter, r *http.Request) {
+		switch r.FormValue("email") {
+		case "john.doe@example.com":
+			fmt.Fprint(w, `{"ok":true,"user":{"id":"U001","name":"john.doe"}}`)
+		case "anna.lee@example.com":
+			fmt.Fprint(w, `{"ok":true,"user":{"id":"U005","name":"anna.lee"}}`)
+		case "mark.hill@example.com":
+			fmt.Fprint(w, `{"ok":true,"user":{"id":"U006","name":"mark.hill"}}`)
+		default:
+			fmt.Fprint(w, `{"ok":false,"error":"users_not_found"}`)
+		}
+	})
+	mux.HandleFunc("/users.list", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("cursor") == "" {
+			fmt.Fprint(w, `{"ok":true,"members":[{"id":"U002","name":"jane.roe"}],"response_metadata":{"next_cursor":"page2"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"ok":true,"members":[{"id":"U003","name":"old.user","deleted":true},{"id":"U004","name":"x","profile":{"display_name":"bob"}}]}`)
+	})
+	mux.HandleFunc("/usergroups.users.list", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ok":true,"users":["U002","U003","U004"]}`)
+	})
+	mux.HandleFunc("/conversations.open", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"ok":true,"channel":{"id":"D%s"}}`, r.FormValue("users"))
+	})
+
+	mux.HandleFunc("/auth.test", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			fmt.Fprint(w, `{"ok":false,"error":"invalid_auth"}`)
+			return
+		}
+		fmt.Fprint(w, `{"ok":true,"user_id":"U0BOT"}`)
+	})
+
+	suite.server = httptest.NewServer(mux)
+	suite.client = NewClient("token")
+	suite.client.apiURL = suite.server.URL + "/"
+}
+
+func (suite *UserResolverTestSuite) TearDownTest(c *C) {
+	suite.server.Close()
+}
+
+func (suite *UserResolverTestSuite) TestCheckAuth(c *C) {
+	c.Assert(suite.client.CheckAuth(), IsNil)
+
+	suite.client.token = "wrong"
+	c.Assert(suite.client.CheckAuth(), ErrorMatches, ".*invalid_auth.*")
+}
+
+func (suite *UserResolverTestSuite) TestRefresh(c *C) {
+	resolver := NewUserResolver(suite.client, []config.User{
+		{Name: "John Doe", SlackLogin: "john.doe", Email: "john.doe@example.com"},
+		{Name: "Jane Roe", SlackLogin: "@jane.roe", Email: "unknown@example.com"},
+		{Name: "Bob", SlackLogin: "bob"},
+		{Name: "Old User", SlackLogin: "old.user"},
+	})
+
+	c.Assert(resolver.Refresh(), IsNil)
+
+	id, found := resolver.GetUserID(config.User{SlackLogin: "john.doe", Email: "john.doe@example.com"})
+	c.Assert(found, Equals, true)
+	c.Assert(id, Equals, "U001")
+	id, found = resolver.GetUserID(config.User{SlackLogin: "@jane.roe", Email: "unknown@example.com"})
+	c.Assert(found, Equals, true)
+	c.Assert(id, Equals, "U002")
+	id, found = resolver.GetUserID(config.User{SlackLogin: "bob"})
+	c.Assert(found, Equals, true)
+	c.Assert(id, Equals, "U004")
+	_, found = resolver.GetUserID(config.User{SlackLogin: "old.user"})
+	c.Assert(found, Equals, false)
+
+	unresolved := resolver.Unresolved()
+	c.Assert(len(unresolved), Equals, 1)
+	c.Assert(unresolved[0].Name, Equals, "Old User")
+}
+
+func (suite *UserResolverTestSuite) TestRefreshEmailOnlyUsers(c *C) {
+	// synced users have email but no slack login
+	anna := config.User{Name: "Anna Lee", Email: "anna.lee@example.com"}
+	mark := config.User{Name: "Mark Hill", Email: "mark.hill@example.com"}
+	resolver := NewUserResolver(suite.client, []config.User{anna, mark})
+	c.Assert(resolver.Refresh(), IsNil)
+
+	id, found := resolver.GetUserID(anna)
+	c.Assert(found, Equals, true)
+	c.Assert(id, Equals, "U005")
+	id, found = resolver.GetUserID(mark)
+	c.Assert(found, Equals, true)
+	c.Assert(id, Equals, "U006")
+}
+
+func (suite *UserResolverTestSuite) TestGetDirectMessageChannel(c *C) {
+	channelID, err := suite.client.getDirectMessageChannel("U001")
+	c.Assert(err, IsNil)
+	c.Assert(channelID, Equals, "DU001")
+}
+
+func (suite *UserResolverTestSuite) TestGetGroupMembers(c *C) {
+	users, err := suite.client.GetGroupMembers("S1")
+	c.Assert(err, IsNil)
+	c.Assert(users, DeepEquals, []config.User{
+		{SlackLogin: "jane.roe"},
+		{SlackLogin: "x"},
+	})
+}
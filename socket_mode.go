@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+
+	"bobby/processors"
+	"bobby/slack"
+)
+
+var mentionRegexp = regexp.MustCompile(`^\s*<@[A-Z0-9]+>\s*`)
+
+// socketModeHandler feeds socket mode payloads into command process manager
+type socketModeHandler struct {
+	slackClient           processors.IPostponedClient
+	commandProcessManager *processors.CommandProcessManager
+}
+
+func (this *socketModeHandler) HandleSlashCommand(payload json.RawMessage) (string, error) {
+	var command processors.SlackCommand
+	if err := json.Unmarshal(payload, &command); err != nil {
+		return "", err
+	}
+	log.Printf("command: %s\n", command)
+
+	result, err := this.commandProcessManager.ProcessAuthenticatedCommand(&command)
+	if err != nil {
+		return "", err
+	}
+
+	if result.Postponed {
+		return "", nil
+	}
+	return result.Text, nil
+}
+
+// HandleEvent treats mentions of the bot and direct messages to it as commands.
+// Other messages in channels the bot is a member of are ignored.
+func (this *socketModeHandler) HandleEvent(event *slack.Event) {
+	switch {
+	case event.Type == "app_mention":
+	case event.Type == "message" && event.ChannelType == "im":
+	default:
+		return
+	}
+
+	if len(event.BotID) > 0 || len(event.User) == 0 {
+		return
+	}
+
+	command := processors.ParseCommandText(mentionRegexp.ReplaceAllString(event.Text, ""))
+	command.UserId = event.User
+	command.ChannelId = event.Channel
+	log.Printf("event command: %s\n", command)
+
+	result, err := this.commandProcessManager.ProcessAuthenticatedCommand(command)
+	if err != nil {
+		result.Text = fmt.Sprintf("Error: %q", err.Error())
+	} else if result.Postponed {
+		return
+	}
+
+	if err := this.slackClient.SendMessage(event.Channel, result.Text); err != nil {
+		log.Printf("Error send slack message: %s", err)
+	}
+}
+
+func (this *socketModeHandler) HandleInteraction(interaction *slack.Interaction) {
+	for _, action := range interaction.Actions {
+		if len(action.Value) == 0 {
+			continue
+		}
+
+		command := processors.ParseCommandText(action.Value)
+		command.UserId = interaction.User.ID
+		command.UserName = interaction.User.Username
+		command.ChannelId = interaction.Channel.ID
+		command.ChannelName = interaction.Channel.Name
+		command.TeamId = interaction.Team.ID
+		command.TeamDomain = interaction.Team.Domain
+		command.ResponseURL = interaction.ResponseURL
+		log.Printf("interaction command: %s\n", command)
+
+		result, err := this.commandProcessManager.ProcessAuthenticatedCommand(command)
+		if err != nil {
+			result.Text = fmt.Sprintf("Error: %q", err.Error())
+		} else if result.Postponed {
+			continue
+		}
+
+		if err := this.slackClient.SendPostponedMessage(interaction.ResponseURL, result.Text); err != nil {
+			log.Printf("Error send slack message: %s", err)
+		}
+	}
+}
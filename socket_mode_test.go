@@ -0,0 +1,75 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"bobby/processors"
+	"bobby/slack"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func TestStart(t *testing.T) {
+	TestingT(t)
+}
+
+// echoCommandProcessor replies with the command text
+type echoCommandProcessor struct{}
+
+func (this *echoCommandProcessor) ProcessCommand(command *processors.SlackCommand, now time.Time) processors.CommandResult {
+	return processors.CommandResult{Text: "duty " + command.Text}
+}
+
+func (this *echoCommandProcessor) GetAuthToken() string {
+	return ""
+}
+
+type fakeSlackClient struct {
+	lock     sync.Mutex
+	messages map[string][]string
+}
+
+func (this *fakeSlackClient) SendMessage(channelID, text string) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.messages[channelID] = append(this.messages[channelID], text)
+	return nil
+}
+
+func (this *fakeSlackClient) SendPostponedMessage(responseURL, text string) error {
+	return this.SendMessage(responseURL, text)
+}
+
+type SocketModeHandlerTestSuite struct {
+	client  *fakeSlackClient
+	handler *socketModeHandler
+}
+
+var _ = Suite(&SocketModeHandlerTestSuite{})
+
+func (suite *SocketModeHandlerTestSuite) SetUpTest(c *C) {
+	manager := processors.NewCommandProcessManager()
+	manager.AddCommandProcessor("duty", &echoCommandProcessor{})
+
+	suite.client = &fakeSlackClient{messages: make(map[string][]string)}
+	suite.handler = &socketModeHandler{
+		slackClient:           suite.client,
+		commandProcessManager: manager,
+	}
+}
+
+func (suite *SocketModeHandlerTestSuite) TestHandleEvent(c *C) {
+	suite.handler.HandleEvent(&slack.Event{Type: "message", ChannelType: "channel", User: "U1", Channel: "C1", Text: "duty tomorrow"})
+	suite.handler.HandleEvent(&slack.Event{Type: "message", ChannelType: "im", User: "U1", BotID: "B1", Channel: "D1", Text: "duty"})
+	c.Assert(suite.client.messages, HasLen, 0)
+
+	suite.handler.HandleEvent(&slack.Event{Type: "app_mention", ChannelType: "channel", User: "U1", Channel: "C1", Text: "<@U0BOT> duty tomorrow"})
+	suite.handler.HandleEvent(&slack.Event{Type: "message", ChannelType: "im", User: "U1", Channel: "D1", Text: "duty today"})
+	c.Assert(suite.client.messages, DeepEquals, map[string][]string{
+		"C1": {"duty tomorrow"},
+		"D1": {"duty today"},
+	})
+}
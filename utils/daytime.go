@@ -7,13 +7,23 @@ type DayTime struct {
 }
 
 func ParseDayTime(dayTime string) (result DayTime, err error) {
-	if len(dayTime) < 5 {
-		err = fmt.Errorf("time format error: param length must be 5")
+	if len(dayTime) != 5 || dayTime[2] != ':' || !isDigits(dayTime[:2]) || !isDigits(dayTime[3:]) {
+		return result, fmt.Errorf("time format error: %q must be HH:MM", dayTime)
 	}
+
 	result.Hour = int((dayTime[0]-'0')*10 + (dayTime[1] - '0'))
 	result.Minute = int((dayTime[3]-'0')*10 + (dayTime[4] - '0'))
-	if result.Hour < 0 || result.Hour > 23 || result.Minute < 0 || result.Minute > 59 {
+	if result.Hour > 23 || result.Minute > 59 {
 		err = fmt.Errorf("time format error: %q", dayTime)
 	}
 	return
 }
+
+func isDigits(value string) bool {
+	for _, char := range value {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+	return true
+}
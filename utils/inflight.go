@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// Inflight counts work in progress. Unlike sync.WaitGroup it may be waited with timeout
+// and reused while a timed out wait is still pending.
+type Inflight struct {
+	lock    sync.Mutex
+	count   int
+	waiters []chan struct{}
+}
+
+func (this *Inflight) Add() {
+	this.lock.Lock()
+	this.count++
+	this.lock.Unlock()
+}
+
+func (this *Inflight) Done() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.count--; this.count > 0 {
+		return
+	}
+	for _, waiter := range this.waiters {
+		close(waiter)
+	}
+	this.waiters = nil
+}
+
+// Wait waits until no work is in progress and reports if it happened within timeout
+func (this *Inflight) Wait(timeout time.Duration) bool {
+	this.lock.Lock()
+	if this.count == 0 {
+		this.lock.Unlock()
+		return true
+	}
+	done := make(chan struct{})
+	this.waiters = append(this.waiters, done)
+	this.lock.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
@@ -22,6 +22,16 @@ func GetPreviousDateRange(date time.Time) (time.Time, time.Time) {
 	return from, to
 }
 
+// GetPreviousWeekRange returns range from monday to sunday of the week before date
+func GetPreviousWeekRange(date time.Time) (time.Time, time.Time) {
+	from := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	daysSinceMonday := (int(date.Weekday()) + 6) % 7
+	from = from.AddDate(0, 0, -daysSinceMonday-7)
+
+	to := from.AddDate(0, 0, 7).Add(-1 * time.Second)
+	return from, to
+}
+
 func GetDateFromArgs(arg string, now time.Time) (time.Time, error) {
 	switch arg {
 	case "now", "today":
@@ -39,11 +49,8 @@ func GetDateFromArgs(arg string, now time.Time) (time.Time, error) {
 	return now, fmt.Errorf("Unknown date format: %q\n", arg)
 }
 
-func ToSlackUserLogin(name string) string {
-	if strings.HasPrefix(name, "@") {
-		return name
-	}
-	return "@" + name
+func ToSlackMention(userID string) string {
+	return "<@" + userID + ">"
 }
 
 func GetFirstName(fullName string) string {